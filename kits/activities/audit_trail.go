@@ -0,0 +1,156 @@
+package activities
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// AuditSink receives every broker mutation. Sinks must be append-only.
+type AuditSink interface {
+	Append(ctx context.Context, rec models.AuditRecord) error
+}
+
+// AuditQuerier is implemented by sinks that can read their records back.
+type AuditQuerier interface {
+	Query(ctx context.Context, q models.AuditQuery) ([]models.AuditRecord, error)
+}
+
+// JSONLAuditSink appends one JSON record per line to a file.
+type JSONLAuditSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewJSONLAuditSink(path string) *JSONLAuditSink {
+	return &JSONLAuditSink{path: path}
+}
+
+func (s *JSONLAuditSink) Path() string { return s.path }
+
+func (s *JSONLAuditSink) Append(ctx context.Context, rec models.AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *JSONLAuditSink) Query(ctx context.Context, q models.AuditQuery) ([]models.AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []models.AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec models.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// a torn last line must not hide the rest of the trail
+			continue
+		}
+		if q.Matches(rec) {
+			out = append(out, rec)
+		}
+	}
+	return out, scanner.Err()
+}
+
+// the default sink under os.TempDir() serves tests and simulator runs, main.go
+// replaces it with config.AUDIT_LOG_ENV
+var (
+	auditMu    sync.RWMutex
+	auditSinks = []AuditSink{
+		NewJSONLAuditSink(filepath.Join(os.TempDir(), config.AUDIT_LOG_DEFAULT_FILE)),
+	}
+)
+
+// SetAuditSinks replaces the sinks broker mutations are recorded to. The
+// first sink implementing AuditQuerier answers audit queries. It returns the
+// previous sinks.
+func SetAuditSinks(sinks ...AuditSink) []AuditSink {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	prev := auditSinks
+	auditSinks = sinks
+	return prev
+}
+
+func currentAuditSinks() []AuditSink {
+	auditMu.RLock()
+	defer auditMu.RUnlock()
+	return auditSinks
+}
+
+// recordAudit stamps the record with the calling workflow and activity and
+// appends it to every sink. A sink failure is logged, the mutation it
+// describes has already happened.
+func recordAudit(ctx context.Context, rec models.AuditRecord) {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	if activity.IsActivity(ctx) {
+		info := activity.GetInfo(ctx)
+		rec.WorkflowType = info.WorkflowType.Name
+		rec.WorkflowID = info.WorkflowExecution.ID
+		rec.RunID = info.WorkflowExecution.RunID
+		rec.ActivityType = info.ActivityType.Name
+		rec.Attempt = info.Attempt
+	}
+
+	for _, sink := range currentAuditSinks() {
+		if err := sink.Append(ctx, rec); err != nil && activity.IsActivity(ctx) {
+			activity.GetLogger(ctx).Error("audit record lost", "action", rec.Action, "vpn", rec.VPN, "error", err)
+		}
+	}
+}
+
+// QueryAuditTrail returns the recorded broker mutations matching the query,
+// oldest first.
+func QueryAuditTrail(ctx context.Context, q models.AuditQuery) ([]models.AuditRecord, error) {
+	for _, sink := range currentAuditSinks() {
+		if querier, ok := sink.(AuditQuerier); ok {
+			return querier.Query(ctx, q)
+		}
+	}
+	return nil, errors.New("no queryable audit sink configured")
+}
+
+// QueryAuditTrailActivity exposes QueryAuditTrail to workflows. It runs on
+// config.AUDIT_TASK_QUEUE, polled by the workers reading the shared log.
+func QueryAuditTrailActivity(ctx context.Context, q models.AuditQuery) ([]models.AuditRecord, error) {
+	return QueryAuditTrail(ctx, q)
+}
@@ -0,0 +1,80 @@
+package activities
+
+import (
+	"context"
+	"io"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestSetVPNReplicationRoleIsAudited(t *testing.T) {
+	sink := NewJSONLAuditSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	prev := currentAuditSinks()
+	SetAuditSinks(sink)
+	defer SetAuditSinks(prev...)
+
+	role := "active"
+	var patched string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/SEMP/v2/monitor/msgVpns/orders":
+			w.Write([]byte(`{"data":{"replicationRole":"` + role + `"}}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/SEMP/v2/config/msgVpns/orders":
+			body, _ := io.ReadAll(r.Body)
+			patched = string(body)
+			role = "standby"
+			w.Write([]byte(`{"meta":{"responseCode":200}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(SetVPNReplicationRoleActivity)
+
+	start := time.Now().UTC().Add(-time.Second)
+	if _, err := env.ExecuteActivity(
+		SetVPNReplicationRoleActivity, u.Hostname(), port, "orders", "standby", models.BasicAuth{Username: "admin"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if patched != `{"replicationRole":"standby"}` {
+		t.Fatalf("unexpected PATCH body %s", patched)
+	}
+
+	records, err := QueryAuditTrail(context.Background(), models.AuditQuery{VPN: "orders", From: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 audit record, got %d", len(records))
+	}
+	rec := records[0]
+	if rec.Action != "SetVPNReplicationRole" || rec.SEMPUser != "admin" || rec.StatusCode != http.StatusOK ||
+		rec.RoleBefore != "active" || rec.RoleAfter != "standby" || rec.RequestBody != patched {
+		t.Errorf("unexpected audit record %+v", rec)
+	}
+	if rec.ActivityType != "SetVPNReplicationRoleActivity" {
+		t.Errorf("expected activity info in audit record, got %+v", rec)
+	}
+
+	// other VPNs and time ranges are filtered out
+	if records, _ := QueryAuditTrail(context.Background(), models.AuditQuery{VPN: "payments"}); len(records) != 0 {
+		t.Errorf("expected no record for payments, got %d", len(records))
+	}
+	if records, _ := QueryAuditTrail(context.Background(), models.AuditQuery{To: start}); len(records) != 0 {
+		t.Errorf("expected no record before %s, got %d", start, len(records))
+	}
+}
@@ -0,0 +1,393 @@
+package activities
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ErrNoSuchHost is returned when a resolver has no record for a name.
+var ErrNoSuchHost = errors.New("no such host")
+
+// DNSResolver resolves a name to its full address set.
+type DNSResolver interface {
+	Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error)
+}
+
+var (
+	dnsResolverMu sync.RWMutex
+	dnsResolver   DNSResolver = NewDefaultDNSResolver()
+)
+
+// SetDNSResolver replaces the resolver used by the DNS activities.
+func SetDNSResolver(r DNSResolver) {
+	dnsResolverMu.Lock()
+	defer dnsResolverMu.Unlock()
+	dnsResolver = r
+}
+
+func currentDNSResolver() DNSResolver {
+	dnsResolverMu.RLock()
+	defer dnsResolverMu.RUnlock()
+	return dnsResolver
+}
+
+// NewDefaultDNSResolver checks /etc/hosts, then the system resolver.
+func NewDefaultDNSResolver() DNSResolver {
+	return ChainResolver{
+		&HostsResolver{Path: config.DNS_HOSTS_FILE_DEFAULT},
+		&SystemResolver{},
+	}
+}
+
+// ParseDNSRecordTypes parses a comma separated list of record types, A when
+// empty.
+func ParseDNSRecordTypes(s string) ([]models.DNSRecordType, error) {
+	var types []models.DNSRecordType
+	for _, f := range strings.Split(s, ",") {
+		switch t := models.DNSRecordType(strings.ToUpper(strings.TrimSpace(f))); t {
+		case "":
+		case models.DNSRecordA, models.DNSRecordAAAA:
+			if !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported DNS record type %q", f)
+		}
+	}
+	if len(types) == 0 {
+		types = []models.DNSRecordType{models.DNSRecordA}
+	}
+	return types, nil
+}
+
+// ChainResolver asks its resolvers in order; the first one knowing the name
+// answers.
+type ChainResolver []DNSResolver
+
+func (c ChainResolver) Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error) {
+	var errs []error
+	for _, r := range c {
+		set, err := r.Resolve(ctx, name)
+		if err == nil {
+			return set, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// HostsResolver looks names up in a hosts file.
+type HostsResolver struct {
+	Path string
+	// Types filters the addresses, every address when empty.
+	Types []models.DNSRecordType
+}
+
+func (h *HostsResolver) Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error) {
+	data, err := readHostsFile(h.Path)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s in %s: %w", name, h.Path, err)
+	}
+	name = strings.TrimSuffix(name, ".")
+
+	var addrs []netip.Addr
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		for _, host := range fields[1:] {
+			if strings.EqualFold(strings.TrimSuffix(host, "."), name) {
+				addrs = append(addrs, addr)
+				break
+			}
+		}
+	}
+	addrs = filterAddrs(addrs, h.Types)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("lookup %s in %s: %w", name, h.Path, ErrNoSuchHost)
+	}
+	return &models.DNSRecordSet{Name: name, Addresses: sortAddrs(addrs), Source: "hosts"}, nil
+}
+
+// SystemResolver uses the resolver of the host. It gives no TTL and no CNAME
+// chain.
+type SystemResolver struct {
+	Types []models.DNSRecordType
+}
+
+func (s *SystemResolver) Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error) {
+	ips, err := dnsLookup(name)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []netip.Addr
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+	addrs = filterAddrs(addrs, s.Types)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+	}
+	return &models.DNSRecordSet{Name: name, Addresses: sortAddrs(addrs), Source: "system"}, nil
+}
+
+// ServerResolver queries DNS servers directly, ie the authoritative or GSLB
+// servers of the broker FQDNs, bypassing caches in between. Servers are
+// tried in order until one answers; a name the server does not know is an
+// answer.
+type ServerResolver struct {
+	// Servers are host:port addresses, port 53 when omitted.
+	Servers []string
+	Types   []models.DNSRecordType
+	// Timeout of a single query, config.DNS_QUERY_TIMEOUT if 0.
+	Timeout time.Duration
+}
+
+// NewServerResolver builds a ServerResolver, adding the default port to the
+// servers.
+func NewServerResolver(servers []string, types []models.DNSRecordType) (*ServerResolver, error) {
+	r := &ServerResolver{Types: types}
+	for _, s := range servers {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(strings.Trim(s, "[]"), "53")
+		}
+		r.Servers = append(r.Servers, s)
+	}
+	if len(r.Servers) == 0 {
+		return nil, errors.New("no DNS server configured")
+	}
+	return r, nil
+}
+
+func (r *ServerResolver) Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error) {
+	var errs []error
+	for _, server := range r.Servers {
+		set, err := r.resolveWith(ctx, server, name)
+		if err == nil || errors.Is(err, ErrNoSuchHost) {
+			return set, err
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (r *ServerResolver) resolveWith(ctx context.Context, server, name string) (*models.DNSRecordSet, error) {
+	types := r.Types
+	if len(types) == 0 {
+		types = []models.DNSRecordType{models.DNSRecordA}
+	}
+	set := &models.DNSRecordSet{Name: strings.TrimSuffix(name, "."), Source: server}
+	ttl := ^uint32(0)
+	var addrs []netip.Addr
+
+	for _, t := range types {
+		qtype := dnsmessage.TypeA
+		if t == models.DNSRecordAAAA {
+			qtype = dnsmessage.TypeAAAA
+		}
+		// follow the chain from where the previous type left it
+		target := set.Canonical() + "."
+		for hops := 0; ; hops++ {
+			answers, err := r.exchange(ctx, server, target, qtype)
+			if err != nil {
+				return nil, fmt.Errorf("lookup %s %s on %s: %w", name, t, server, err)
+			}
+			cnames := make(map[string]dnsmessage.Resource)
+			for _, a := range answers {
+				if a.Header.Type == dnsmessage.TypeCNAME {
+					cnames[strings.ToLower(a.Header.Name.String())] = a
+				}
+			}
+			queried := target
+			for c, ok := cnames[strings.ToLower(target)]; ok; c, ok = cnames[strings.ToLower(target)] {
+				delete(cnames, strings.ToLower(target))
+				target = c.Body.(*dnsmessage.CNAMEResource).CNAME.String()
+				ttl = min(ttl, c.Header.TTL)
+				set.CNAMEs = append(set.CNAMEs, strings.TrimSuffix(target, "."))
+				if len(set.CNAMEs) > config.DNS_MAX_CNAME_CHAIN {
+					return nil, fmt.Errorf("lookup %s on %s: CNAME chain longer than %d", name, server, config.DNS_MAX_CNAME_CHAIN)
+				}
+			}
+
+			found := false
+			for _, a := range answers {
+				if !strings.EqualFold(a.Header.Name.String(), target) {
+					continue
+				}
+				switch b := a.Body.(type) {
+				case *dnsmessage.AResource:
+					addrs = append(addrs, netip.AddrFrom4(b.A))
+				case *dnsmessage.AAAAResource:
+					addrs = append(addrs, netip.AddrFrom16(b.AAAA))
+				default:
+					continue
+				}
+				ttl = min(ttl, a.Header.TTL)
+				found = true
+			}
+			// a server authoritative for the alias only answers with the
+			// CNAME: ask again for its target
+			if found || target == queried || hops >= config.DNS_MAX_CNAME_CHAIN {
+				break
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("lookup %s on %s: %w", name, server, ErrNoSuchHost)
+	}
+	set.Addresses = sortAddrs(addrs)
+	set.TTL = ttl
+	return set, nil
+}
+
+// errNXDomain is the NXDOMAIN answer of a server.
+var errNXDomain = fmt.Errorf("NXDOMAIN: %w", ErrNoSuchHost)
+
+// exchange sends one query over UDP, retrying over TCP when the answer is
+// truncated, and returns the answer section.
+func (r *ServerResolver) exchange(ctx context.Context, server, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = config.DNS_QUERY_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, err := roundTrip(ctx, "udp", server, packed)
+	if err == nil && resp.Header.Truncated {
+		resp, err = roundTrip(ctx, "tcp", server, packed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.ID != query.Header.ID {
+		return nil, errors.New("answer does not match the query")
+	}
+	switch resp.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+		return resp.Answers, nil
+	case dnsmessage.RCodeNameError:
+		return nil, errNXDomain
+	default:
+		return nil, fmt.Errorf("server answered %s", resp.Header.RCode)
+	}
+}
+
+func roundTrip(ctx context.Context, network, server string, packed []byte) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var buf []byte
+	if network == "tcp" {
+		msg := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+		if _, err := conn.Write(append(msg, packed...)); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(packed); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, 65535)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = buf[:n]
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("invalid answer: %w", err)
+	}
+	return &resp, nil
+}
+
+func filterAddrs(addrs []netip.Addr, types []models.DNSRecordType) []netip.Addr {
+	if len(types) == 0 {
+		return addrs
+	}
+	var out []netip.Addr
+	for _, a := range addrs {
+		if a.Is4() && slices.Contains(types, models.DNSRecordA) || a.Is6() && slices.Contains(types, models.DNSRecordAAAA) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// sortAddrs sorts and deduplicates addresses, IPv4 first.
+func sortAddrs(addrs []netip.Addr) []string {
+	slices.SortFunc(addrs, func(a, b netip.Addr) int { return a.Compare(b) })
+	addrs = slices.Compact(addrs)
+	out := make([]string, len(addrs))
+	for i, a := range addrs {
+		out[i] = a.String()
+	}
+	return out
+}
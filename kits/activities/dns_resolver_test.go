@@ -0,0 +1,304 @@
+package activities
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"kits-worker/kits/models"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNSServer answers A, AAAA and CNAME queries from a zone over UDP and
+// TCP on loopback.
+type fakeDNSServer struct {
+	zone map[string][]dnsmessage.Resource
+	// recursive follows CNAMEs within the zone in a single answer, otherwise
+	// only the records of the queried name are answered.
+	recursive bool
+	// truncate answers UDP queries with the TC bit set and no records.
+	truncate bool
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (s *fakeDNSServer) queried() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+func (s *fakeDNSServer) cname(name, target string, ttl uint32) {
+	s.add(name, ttl, dnsmessage.TypeCNAME, &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName(target)})
+}
+
+func (s *fakeDNSServer) a(name, ip string, ttl uint32) {
+	addr := netip.MustParseAddr(ip)
+	if addr.Is4() {
+		s.add(name, ttl, dnsmessage.TypeA, &dnsmessage.AResource{A: addr.As4()})
+	} else {
+		s.add(name, ttl, dnsmessage.TypeAAAA, &dnsmessage.AAAAResource{AAAA: addr.As16()})
+	}
+}
+
+func (s *fakeDNSServer) add(name string, ttl uint32, typ dnsmessage.Type, body dnsmessage.ResourceBody) {
+	if s.zone == nil {
+		s.zone = make(map[string][]dnsmessage.Resource)
+	}
+	s.zone[name] = append(s.zone[name], dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Type: typ, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   body,
+	})
+}
+
+func (s *fakeDNSServer) answer(req []byte, udp bool) []byte {
+	var q dnsmessage.Message
+	if err := q.Unpack(req); err != nil {
+		return nil
+	}
+	question := q.Questions[0]
+	name := question.Name.String()
+	s.mu.Lock()
+	s.queries = append(s.queries, question.Type.String()+" "+name)
+	s.mu.Unlock()
+
+	resp := dnsmessage.Message{Header: dnsmessage.Header{ID: q.Header.ID, Response: true}, Questions: q.Questions}
+	switch {
+	case udp && s.truncate:
+		resp.Header.Truncated = true
+	case s.zone[name] == nil:
+		resp.Header.RCode = dnsmessage.RCodeNameError
+	default:
+		for hops := 0; hops < 10; hops++ {
+			next := ""
+			for _, r := range s.zone[name] {
+				switch r.Header.Type {
+				case question.Type:
+					resp.Answers = append(resp.Answers, r)
+				case dnsmessage.TypeCNAME:
+					resp.Answers = append(resp.Answers, r)
+					next = r.Body.(*dnsmessage.CNAMEResource).CNAME.String()
+				}
+			}
+			if next == "" || !s.recursive {
+				break
+			}
+			name = next
+		}
+	}
+	packed, _ := resp.Pack()
+	return packed
+}
+
+// start serves the zone and returns the server address.
+func (s *fakeDNSServer) start(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		l.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(s.answer(buf[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			if _, err := io.ReadFull(conn, size[:]); err == nil {
+				req := make([]byte, binary.BigEndian.Uint16(size[:]))
+				if _, err := io.ReadFull(conn, req); err == nil {
+					resp := s.answer(req, false)
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestServerResolver_CNAMEChain(t *testing.T) {
+	srv := &fakeDNSServer{recursive: true}
+	srv.cname("orders-solace-a.local.", "orders.gslb.local.", 60)
+	srv.cname("orders.gslb.local.", "dc2-pool.gslb.local.", 30)
+	srv.a("dc2-pool.gslb.local.", "10.20.1.101", 20)
+	srv.a("dc2-pool.gslb.local.", "10.20.1.100", 20)
+	srv.a("dc2-pool.gslb.local.", "fd00::1", 10)
+	addr := srv.start(t)
+
+	r, err := NewServerResolver([]string{addr}, []models.DNSRecordType{models.DNSRecordA, models.DNSRecordAAAA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := r.Resolve(context.Background(), "orders-solace-a.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(set.Addresses, " "); got != "10.20.1.100 10.20.1.101 fd00::1" {
+		t.Errorf("expected sorted addresses, got %s", got)
+	}
+	if got := strings.Join(set.CNAMEs, " "); got != "orders.gslb.local dc2-pool.gslb.local" {
+		t.Errorf("unexpected chain %s", got)
+	}
+	if set.Canonical() != "dc2-pool.gslb.local" {
+		t.Errorf("unexpected canonical name %s", set.Canonical())
+	}
+	if set.TTL != 10 {
+		t.Errorf("expected the smallest TTL, got %d", set.TTL)
+	}
+	if set.Source != addr {
+		t.Errorf("expected source %s, got %s", addr, set.Source)
+	}
+	if q := srv.queried(); len(q) != 2 {
+		t.Errorf("expected one query per record type, got %v", q)
+	}
+}
+
+func TestServerResolver_FollowsCNAMEAcrossQueries(t *testing.T) {
+	srv := &fakeDNSServer{}
+	srv.cname("orders-solace-b.local.", "orders-b.dc2.local.", 60)
+	srv.a("orders-b.dc2.local.", "10.20.1.102", 60)
+	addr := srv.start(t)
+
+	r := &ServerResolver{Servers: []string{addr}}
+	set, err := r.Resolve(context.Background(), "orders-solace-b.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.String() != "10.20.1.102" {
+		t.Errorf("unexpected addresses %v", set.Addresses)
+	}
+	if q := srv.queried(); len(q) != 2 || q[1] != "TypeA orders-b.dc2.local." {
+		t.Errorf("expected a second query for the CNAME target, got %v", q)
+	}
+}
+
+func TestServerResolver_Servers(t *testing.T) {
+	srv := &fakeDNSServer{truncate: true}
+	srv.a("orders-solace-a.local.", "10.10.1.100", 30)
+	addr := srv.start(t)
+
+	// nothing listens on the first server
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := pc.LocalAddr().String()
+	pc.Close()
+
+	r := &ServerResolver{Servers: []string{dead, addr}, Timeout: time.Second}
+	set, err := r.Resolve(context.Background(), "orders-solace-a.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Source != addr || set.String() != "10.10.1.100" {
+		t.Errorf("expected the second server to answer over TCP, got %+v", set)
+	}
+
+	// a name unknown to the first server that answers is not asked further
+	r = &ServerResolver{Servers: []string{addr, dead}, Timeout: time.Second}
+	if _, err := r.Resolve(context.Background(), "unknown-solace-a.local"); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("expected ErrNoSuchHost, got %v", err)
+	}
+}
+
+func TestNewServerResolver(t *testing.T) {
+	r, err := NewServerResolver([]string{"10.0.0.53", " ns1.local:5353", "", "fd00::53"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.Servers, " "); got != "10.0.0.53:53 ns1.local:5353 [fd00::53]:53" {
+		t.Errorf("unexpected servers %s", got)
+	}
+	if _, err := NewServerResolver([]string{" "}, nil); err == nil {
+		t.Error("expected an error without servers")
+	}
+}
+
+func TestHostsAndChainResolver(t *testing.T) {
+	origReadHosts := readHostsFile
+	origLookup := dnsLookup
+	defer func() {
+		readHostsFile = origReadHosts
+		dnsLookup = origLookup
+	}()
+
+	readHostsFile = func(string) ([]byte, error) {
+		return []byte(`
+10.10.1.101 orders-solace-a.local # second address
+10.10.1.100 orders-solace-a.local orders-solace-a
+fd00::100   orders-solace-a.local
+`), nil
+	}
+	dnsLookup = func(host string) ([]net.IP, error) {
+		if host == "billing-solace-a.local" {
+			return []net.IP{net.ParseIP("10.10.2.100")}, nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	hosts := &HostsResolver{Path: "/etc/hosts", Types: []models.DNSRecordType{models.DNSRecordA}}
+	set, err := hosts.Resolve(context.Background(), "orders-solace-a.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.String() != "10.10.1.100,10.10.1.101" || set.Source != "hosts" {
+		t.Errorf("unexpected set %+v", set)
+	}
+
+	chain := ChainResolver{hosts, &SystemResolver{}}
+	set, err = chain.Resolve(context.Background(), "billing-solace-a.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.String() != "10.10.2.100" || set.Source != "system" {
+		t.Errorf("expected the system resolver to answer, got %+v", set)
+	}
+	if _, err := chain.Resolve(context.Background(), "unknown-solace-a.local"); err == nil {
+		t.Error("expected an error for an unknown name")
+	}
+}
+
+func TestParseDNSRecordTypes(t *testing.T) {
+	types, err := ParseDNSRecordTypes(" aaaa, A ,AAAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != models.DNSRecordAAAA || types[1] != models.DNSRecordA {
+		t.Errorf("unexpected types %v", types)
+	}
+	if types, _ := ParseDNSRecordTypes(""); len(types) != 1 || types[0] != models.DNSRecordA {
+		t.Errorf("expected A by default, got %v", types)
+	}
+	if _, err := ParseDNSRecordTypes("MX"); err == nil {
+		t.Error("expected an error for MX")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"kits-worker/kits/config"
 	"kits-worker/kits/models"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"go.temporal.io/sdk/activity"
 )
@@ -15,6 +17,8 @@ import (
 type AboutResponse struct {
 	Platform    string `json:"platform"`
 	SempVersion string `json:"sempVersion"`
+	// ApiVersion is the SEMP API generation serving /monitor/about/api, ie v2.
+	ApiVersion string `json:"apiVersion,omitempty"`
 }
 
 // aboutWrapper is the generic wrapper returned by /monitor/about and /monitor/about/api
@@ -102,6 +106,7 @@ func BrokerSEMPApiAboutActivity(
 	data := apiWrapper.Data
 	platform, _ := data["platform"].(string)
 	sempVersion, _ := data["sempVersion"].(string)
+	apiVersion := sempAPIVersion(wrapper.Links.APIUri)
 
 	logger.Info(
 		"SEMP /about/api retrieved",
@@ -112,10 +117,28 @@ func BrokerSEMPApiAboutActivity(
 		"port", ep.Port,
 		"platform", platform,
 		"sempVersion", sempVersion,
+		"apiVersion", apiVersion,
 	)
 
 	return &AboutResponse{
 		Platform:    platform,
 		SempVersion: sempVersion,
+		ApiVersion:  apiVersion,
 	}, nil
 }
+
+// sempAPIVersion returns the path segment following /SEMP/ in uri, or an
+// empty string if uri is not a SEMP API URI.
+func sempAPIVersion(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	segs := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segs); i++ {
+		if segs[i] == "SEMP" {
+			return segs[i+1]
+		}
+	}
+	return ""
+}
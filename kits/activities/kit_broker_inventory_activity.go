@@ -0,0 +1,170 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net/http"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+)
+
+type vpnInventoryPage struct {
+	Data []struct {
+		Name               string `json:"msgVpnName"`
+		ReplicationEnabled bool   `json:"replicationEnabled"`
+		ReplicationRole    string `json:"replicationRole"`
+	} `json:"data"`
+	Meta struct {
+		Paging struct {
+			NextPageURI string `json:"nextPageUri"`
+		} `json:"paging"`
+	} `json:"meta"`
+}
+
+// BrokerInventoryActivity collects the inventory of a single mate: platform
+// and SEMP version (SEMP v2 about), broker release (show version),
+// redundancy mode and config-sync status (SEMP v1) and message VPN counts.
+//
+// Only the about call is mandatory; the other sections are best effort and
+// left empty if the broker does not answer them. A failed VPN count is
+// reported in Error so that it does not read as a broker without VPNs.
+func BrokerInventoryActivity(
+	ctx context.Context,
+	kit string,
+	dc string,
+	mate string,
+	ep models.Endpoint,
+	auth models.BasicAuth,
+) (models.BrokerInventory, error) {
+
+	logger := activity.GetLogger(ctx)
+
+	inv := models.BrokerInventory{
+		AboutResult: models.AboutResult{
+			Kit:  kit,
+			DC:   dc,
+			Mate: mate,
+			Host: ep.Host,
+			Port: ep.Port,
+		},
+	}
+
+	about, err := BrokerSEMPApiAboutActivity(ctx, kit, dc, mate, ep, auth)
+	if err != nil {
+		return inv, err
+	}
+	inv.Platform = about.Platform
+	inv.SempVersion = about.SempVersion
+	inv.ApiVersion = about.ApiVersion
+
+	client := NewSEMPv1Client(ep, auth)
+
+	if v, err := client.ShowVersion(ctx); err != nil {
+		logger.Warn("show version failed", "kit", kit, "dc", dc, "mate", mate, "error", err)
+	} else {
+		inv.Build = v.CurrentLoad
+		inv.Release = strings.TrimPrefix(v.CurrentLoad, "soltr_")
+		inv.Description = v.Description
+	}
+
+	if r, err := client.ShowRedundancy(ctx); err != nil {
+		logger.Warn("show redundancy failed", "kit", kit, "dc", dc, "mate", mate, "error", err)
+	} else {
+		inv.RedundancyMode = r.RedundancyMode
+		inv.RedundancyStatus = r.RedundancyStatus
+		inv.ActiveStandbyRole = r.ActiveStandbyRole
+	}
+
+	if cs, err := client.ShowConfigSync(ctx); err != nil {
+		logger.Warn("show config-sync failed", "kit", kit, "dc", dc, "mate", mate, "error", err)
+	} else {
+		inv.ConfigSyncAdminStatus = cs.Status.AdminStatus
+		inv.ConfigSyncOperStatus = cs.Status.OperStatus
+	}
+
+	var counted models.BrokerInventory
+	if err := countVPNs(ctx, ep, auth, &counted); err != nil {
+		logger.Warn("msgVpns inventory failed", "kit", kit, "dc", dc, "mate", mate, "error", err)
+		inv.Error = "msgVpns inventory failed: " + err.Error()
+	} else {
+		inv.VPNCount = counted.VPNCount
+		inv.ActiveVPNCount = counted.ActiveVPNCount
+		inv.StandbyVPNCount = counted.StandbyVPNCount
+	}
+
+	logger.Info(
+		"broker inventoried",
+		"kit", kit,
+		"dc", dc,
+		"mate", mate,
+		"platform", inv.Platform,
+		"sempVersion", inv.SempVersion,
+		"release", inv.Release,
+		"vpnCount", inv.VPNCount,
+	)
+
+	return inv, nil
+}
+
+func countVPNs(
+	ctx context.Context,
+	ep models.Endpoint,
+	auth models.BasicAuth,
+	inv *models.BrokerInventory,
+) error {
+
+	next := fmt.Sprintf(
+		"%s://%s:%d/SEMP/v2/monitor/msgVpns?count=100&select=msgVpnName,replicationEnabled,replicationRole",
+		config.CURRENT_HTTP_SCHEME,
+		ep.Host,
+		ep.Port,
+	)
+
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return err
+		}
+		applyBasicAuth(req, auth)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("SEMP v2 request failed: %s", resp.Status)
+		}
+
+		var page vpnInventoryPage
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, vpn := range page.Data {
+			// skip internal VPNs such as #config-sync
+			if strings.HasPrefix(vpn.Name, "#") {
+				continue
+			}
+			inv.VPNCount++
+			if !vpn.ReplicationEnabled {
+				continue
+			}
+			switch vpn.ReplicationRole {
+			case "active":
+				inv.ActiveVPNCount++
+			case "standby":
+				inv.StandbyVPNCount++
+			}
+		}
+		next = page.Meta.Paging.NextPageURI
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package activities
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+// inventoryBroker serves the SEMP v2 about endpoints, the SEMP v1 show
+// fixtures and a msgVpns monitor answering with the given status and body.
+func inventoryBroker(t *testing.T, vpnsStatus int, vpns string) models.Endpoint {
+	t.Helper()
+	fixture := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join("testdata", "semp_v1", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	version := fixture("show_version.xml")
+	redundancy := fixture("show_redundancy.xml")
+	configSync := fixture("show_config_sync.xml")
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/SEMP/v2/monitor/about":
+			w.Write([]byte(`{"data":{},"links":{"apiUri":"` + srv.URL + `/SEMP/v2/monitor/about/api"}}`))
+		case "/SEMP/v2/monitor/about/api":
+			w.Write([]byte(`{"data":{"platform":"Enterprise","sempVersion":"2.36"}}`))
+		case "/SEMP":
+			body, _ := io.ReadAll(r.Body)
+			switch {
+			case strings.Contains(string(body), "<version"):
+				w.Write(version)
+			case strings.Contains(string(body), "<redundancy"):
+				w.Write(redundancy)
+			default:
+				w.Write(configSync)
+			}
+		case "/SEMP/v2/monitor/msgVpns":
+			w.WriteHeader(vpnsStatus)
+			w.Write([]byte(vpns))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return models.Endpoint{Host: u.Hostname(), Port: port}
+}
+
+func TestBrokerInventoryActivity(t *testing.T) {
+	vpns := `{"data":[` +
+		`{"msgVpnName":"#config-sync"},` +
+		`{"msgVpnName":"orders","replicationEnabled":true,"replicationRole":"active"},` +
+		`{"msgVpnName":"payments","replicationEnabled":true,"replicationRole":"standby"},` +
+		`{"msgVpnName":"default"}],"meta":{}}`
+
+	cases := []struct {
+		name       string
+		status     int
+		body       string
+		wantCounts [3]int
+		wantError  string
+	}{
+		{name: "vpns counted", status: http.StatusOK, body: vpns, wantCounts: [3]int{3, 1, 1}},
+		{name: "non-200 reply", status: http.StatusUnauthorized, body: vpns, wantError: "401 Unauthorized"},
+		{name: "broken reply", status: http.StatusOK, body: `{"data":[`, wantError: "msgVpns inventory failed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestActivityEnvironment()
+			env.RegisterActivity(BrokerInventoryActivity)
+
+			val, err := env.ExecuteActivity(
+				BrokerInventoryActivity, "kit-a", "dc1", "mate1", inventoryBroker(t, c.status, c.body), models.BasicAuth{},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var inv models.BrokerInventory
+			if err := val.Get(&inv); err != nil {
+				t.Fatal(err)
+			}
+
+			if inv.Platform != "Enterprise" || inv.ApiVersion != "v2" || inv.Release != "10.4.1.112" {
+				t.Errorf("expected about and version sections, got %+v", inv)
+			}
+			got := [3]int{inv.VPNCount, inv.ActiveVPNCount, inv.StandbyVPNCount}
+			if got != c.wantCounts {
+				t.Errorf("expected counts %v, got %v", c.wantCounts, got)
+			}
+			if c.wantError == "" && inv.Error != "" || !strings.Contains(inv.Error, c.wantError) {
+				t.Errorf("expected error %q, got %q", c.wantError, inv.Error)
+			}
+
+			// the CSV row carries the failed count instead of a bare zero
+			data, err := FleetInventoryCSV(models.FleetInventory{
+				Kits: []models.KitInventory{{Kit: "kit-a", Mates: []models.BrokerInventory{inv}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2 || len(rows[1]) != len(inventoryCSVHeader) {
+				t.Fatalf("expected header and one mate row, got %v", rows)
+			}
+			row := rows[1]
+			if row[0] != "kit-a" || row[7] != "v2" || row[15] != strconv.Itoa(c.wantCounts[0]) || row[len(row)-1] != inv.Error {
+				t.Errorf("unexpected CSV row %v", row)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net"
+	"strconv"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	SEMPOperationDiscovery = "discovery"
+	SEMPOperationFailover  = "failover"
+)
+
+var sempMinVersions = map[string]string{
+	SEMPOperationDiscovery: config.SEMP_MIN_VERSION_DISCOVERY,
+	SEMPOperationFailover:  config.SEMP_MIN_VERSION_FAILOVER,
+}
+
+// CheckSEMPCompatibility asserts that every broker runs a supported platform
+// and at least the minimum SEMP version for the operation, and that all
+// brokers share the same SEMP major version.
+func CheckSEMPCompatibility(operation string, brokers []models.AboutResult) error {
+	minVersion, ok := sempMinVersions[operation]
+	if !ok {
+		return fmt.Errorf("unknown SEMP operation %q", operation)
+	}
+	minParsed, err := parseSEMPVersion(minVersion)
+	if err != nil {
+		return err
+	}
+
+	supported := make(map[string]bool)
+	for _, p := range strings.Split(config.SEMP_SUPPORTED_PLATFORMS, ",") {
+		supported[strings.ToLower(strings.TrimSpace(p))] = true
+	}
+
+	var problems []string
+	major := -1
+	for _, b := range brokers {
+		name := fmt.Sprintf("%s/%s/%s (%s:%d)", b.Kit, b.DC, b.Mate, b.Host, b.Port)
+
+		if !supported[strings.ToLower(b.Platform)] {
+			problems = append(problems, fmt.Sprintf("%s: unsupported platform %q", name, b.Platform))
+		}
+
+		v, err := parseSEMPVersion(b.SempVersion)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		if compareSEMPVersions(v, minParsed) < 0 {
+			problems = append(problems, fmt.Sprintf(
+				"%s: sempVersion %s is below %s required for %s",
+				name, b.SempVersion, minVersion, operation,
+			))
+		}
+		if major >= 0 && v[0] != major {
+			problems = append(problems, fmt.Sprintf(
+				"%s: sempVersion %s does not match the SEMP major version %d of its peers",
+				name, b.SempVersion, major,
+			))
+		}
+		if major < 0 {
+			major = v[0]
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("SEMP compatibility check for %s failed: %s", operation, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// CheckSEMPCompatibilityActivity fetches /SEMP/v2/monitor/about/api from every
+// given mate and refuses the operation if any of them is unsupported. Nil
+// mates and mates that cannot be reached (ie a lost active site) are skipped,
+// so that only a real platform or version mismatch blocks the operation; the
+// check is retried while none of the mates answers.
+func CheckSEMPCompatibilityActivity(
+	ctx context.Context,
+	operation string,
+	mates []*models.MateResult,
+	auth models.BasicAuth,
+) ([]models.AboutResult, error) {
+
+	logger := activity.GetLogger(ctx)
+
+	var brokers []models.AboutResult
+	var unreachable error
+	for _, m := range mates {
+		if m == nil {
+			continue
+		}
+		ep := models.Endpoint{Host: m.Host, Port: m.Port}
+		about, err := BrokerSEMPApiAboutActivity(ctx, m.Kit, m.DC, m.Mate, ep, auth)
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			logger.Warn("broker unreachable, not checked", "operation", operation, "dc", m.DC, "mate", m.Mate, "error", err)
+			unreachable = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		brokers = append(brokers, models.AboutResult{
+			Kit:         m.Kit,
+			DC:          m.DC,
+			Mate:        m.Mate,
+			Host:        m.Host,
+			Port:        m.Port,
+			Platform:    about.Platform,
+			SempVersion: about.SempVersion,
+			ApiVersion:  about.ApiVersion,
+		})
+	}
+
+	if len(brokers) == 0 && unreachable != nil {
+		// no broker answered → retryable
+		return nil, unreachable
+	}
+
+	if err := CheckSEMPCompatibility(operation, brokers); err != nil {
+		logger.Error("SEMP compatibility check failed", "operation", operation, "error", err)
+		return brokers, temporal.NewNonRetryableApplicationError(
+			err.Error(),
+			"SEMPIncompatible",
+			err,
+		)
+	}
+
+	logger.Info("SEMP compatibility check passed", "operation", operation, "brokers", len(brokers))
+	return brokers, nil
+}
+
+func parseSEMPVersion(v string) ([]int, error) {
+	parts := strings.Split(strings.TrimSpace(v), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid sempVersion %q", v)
+	}
+	out := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sempVersion %q", v)
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+func compareSEMPVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+package activities
+
+import (
+	"errors"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCheckSEMPCompatibility(t *testing.T) {
+	broker := func(dc, platform, version string) models.AboutResult {
+		return models.AboutResult{Kit: "kit", DC: dc, Mate: "mate1", Host: dc + ".local", Port: 8080, Platform: platform, SempVersion: version}
+	}
+
+	if err := CheckSEMPCompatibility(SEMPOperationFailover, []models.AboutResult{
+		broker("dc1", "VMR", "2.26"),
+		broker("dc2", "Appliance", "2.12"),
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		brokers []models.AboutResult
+		want    string
+	}{
+		{[]models.AboutResult{broker("dc1", "VMR", "2.9")}, "below"},
+		{[]models.AboutResult{broker("dc1", "Unknown", "2.26")}, "unsupported platform"},
+		{[]models.AboutResult{broker("dc1", "VMR", "")}, "invalid sempVersion"},
+		{[]models.AboutResult{broker("dc1", "VMR", "2.26"), broker("dc2", "VMR", "3.1")}, "major version"},
+	}
+	for _, c := range cases {
+		err := CheckSEMPCompatibility(SEMPOperationFailover, c.brokers)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("expected error containing %q, got %v", c.want, err)
+		}
+	}
+}
+
+// aboutBroker serves the SEMP v2 about endpoints of a mate; a closed broker
+// refuses connections like a lost site.
+func aboutBroker(t *testing.T, dc, version string, closed bool) *models.MateResult {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/SEMP/v2/monitor/about":
+			w.Write([]byte(`{"data":{},"links":{"apiUri":"` + srv.URL + `/SEMP/v2/monitor/about/api"}}`))
+		case "/SEMP/v2/monitor/about/api":
+			w.Write([]byte(`{"data":{"platform":"VMR","sempVersion":"` + version + `"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	if closed {
+		srv.Close()
+	} else {
+		t.Cleanup(srv.Close)
+	}
+	return &models.MateResult{Kit: "kit", DC: dc, Mate: "mate1", Host: u.Hostname(), Port: port}
+}
+
+func TestCheckSEMPCompatibilityActivity(t *testing.T) {
+	cases := []struct {
+		name    string
+		mates   func(t *testing.T) []*models.MateResult
+		checked int
+		want    string // error type, "" when the operation may proceed
+	}{
+		{
+			name: "compatible",
+			mates: func(t *testing.T) []*models.MateResult {
+				return []*models.MateResult{aboutBroker(t, "dc1", "2.26", false), aboutBroker(t, "dc2", "2.26", false)}
+			},
+			checked: 2,
+		},
+		{
+			name: "active site lost",
+			mates: func(t *testing.T) []*models.MateResult {
+				return []*models.MateResult{aboutBroker(t, "dc1", "2.26", true), aboutBroker(t, "dc2", "2.26", false)}
+			},
+			checked: 1,
+		},
+		{
+			name: "active site absent",
+			mates: func(t *testing.T) []*models.MateResult {
+				return []*models.MateResult{nil, aboutBroker(t, "dc2", "2.26", false)}
+			},
+			checked: 1,
+		},
+		{
+			name: "version mismatch",
+			mates: func(t *testing.T) []*models.MateResult {
+				return []*models.MateResult{aboutBroker(t, "dc1", "2.26", false), aboutBroker(t, "dc2", "3.1", false)}
+			},
+			want: "SEMPIncompatible",
+		},
+		{
+			name: "no broker answers",
+			mates: func(t *testing.T) []*models.MateResult {
+				return []*models.MateResult{aboutBroker(t, "dc1", "2.26", true), aboutBroker(t, "dc2", "2.26", true)}
+			},
+			want: "retryable",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestActivityEnvironment()
+			env.RegisterActivity(CheckSEMPCompatibilityActivity)
+
+			val, err := env.ExecuteActivity(CheckSEMPCompatibilityActivity, SEMPOperationFailover, c.mates(t), models.BasicAuth{})
+			var appErr *temporal.ApplicationError
+			switch c.want {
+			case "":
+				if err != nil {
+					t.Fatal(err)
+				}
+				var brokers []models.AboutResult
+				if err := val.Get(&brokers); err != nil {
+					t.Fatal(err)
+				}
+				if len(brokers) != c.checked {
+					t.Errorf("expected %d brokers checked, got %+v", c.checked, brokers)
+				}
+			case "retryable":
+				if err == nil || errors.As(err, &appErr) && appErr.NonRetryable() {
+					t.Errorf("expected a retryable error, got %v", err)
+				}
+			default:
+				if !errors.As(err, &appErr) || appErr.Type() != c.want || !appErr.NonRetryable() {
+					t.Errorf("expected non-retryable %s, got %v", c.want, err)
+				}
+			}
+		})
+	}
+}
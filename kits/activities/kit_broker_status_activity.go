@@ -1,38 +1,49 @@
 package activities
 
 import (
-	"bytes"
 	"context"
-	"encoding/xml"
-	"fmt"
-	"kits-worker/kits/config"
 	"kits-worker/kits/models"
-	"net/http"
-	"time"
+	"strings"
 
 	"go.temporal.io/sdk/activity"
-	"go.temporal.io/sdk/temporal"
 )
 
-const sempPayload = `<rpc><show><redundancy/></show></rpc>`
+// classifyMate derives the mate state from the show redundancy reply and the
+// operational status of the message spool (empty if unknown).
+func classifyMate(r RedundancyReply, spool string) (models.MateStatus, string) {
+	var states []string
+	localActive, mateActive := false, false
+	for _, vr := range []*VirtualRouterStatus{r.VirtualRouters.Primary, r.VirtualRouters.Backup} {
+		if vr == nil || vr.Status.Activity == "" {
+			continue
+		}
+		states = append(states, vr.Status.Activity)
+		switch vr.Status.Activity {
+		case "Local Active":
+			localActive = true
+		case "Mate Active":
+			mateActive = true
+		}
+	}
+	vrActivity := strings.Join(states, "/")
 
-type sempReply struct {
-	RPC struct {
-		Show struct {
-			Redundancy struct {
-				VirtualRouters struct {
-					Primary *routerStatus `xml:"primary"`
-					Backup  *routerStatus `xml:"backup"`
-				} `xml:"virtual-routers"`
-			} `xml:"redundancy"`
-		} `xml:"show"`
-	} `xml:"rpc"`
-}
+	if r.ConfigStatus != "" && !strings.EqualFold(r.ConfigStatus, "Enabled") {
+		return models.RedundancyDisabled, vrActivity
+	}
 
-type routerStatus struct {
-	Status struct {
-		Activity string `xml:"activity"`
-	} `xml:"status"`
+	switch {
+	case localActive && spool == "AD-Standby":
+		return models.ADStandby, vrActivity
+	case localActive:
+		return models.Active, vrActivity
+	case mateActive:
+		return models.Standby, vrActivity
+	case len(states) > 0:
+		// Shutdown, Local Inactive, ... on every virtual router
+		return models.Down, vrActivity
+	default:
+		return models.Unknown, vrActivity
+	}
 }
 
 func CheckMateStatusActivity(
@@ -44,56 +55,32 @@ func CheckMateStatusActivity(
 	auth models.BasicAuth,
 ) (models.MateResult, error) {
 
-	url := fmt.Sprintf("%s://%s:%d/SEMP", config.CURRENT_HTTP_SCHEME, ep.Host, ep.Port)
+	client := NewSEMPv1Client(ep, auth)
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBufferString(sempPayload),
-	)
+	redundancy, err := client.ShowRedundancy(ctx)
 	if err != nil {
-		return models.MateResult{}, temporal.NewNonRetryableApplicationError(
-			"failed to create request",
-			"RequestError",
-			err,
-		)
+		return models.MateResult{}, sempV1ActivityError(err)
 	}
 
-	applyBasicAuth(req, auth)
-	req.Header.Set("Content-Type", "application/xml")
-
-	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+	logger := activity.GetLogger(ctx)
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		// DNS, timeout, connection refused → retryable
-		return models.MateResult{}, err
+	// message spool and config-sync states are informative, a failure to
+	// read them must not hide the redundancy state
+	var spoolStatus, configSyncStatus string
+	if spool, err := client.ShowMessageSpool(ctx); err != nil {
+		logger.Warn("show message-spool failed", "kit", kit, "dc", dc, "mate", mate, "error", err)
+	} else {
+		spoolStatus = spool.Info.OperationalStatus
 	}
-	defer resp.Body.Close()
-
-	var reply sempReply
-	if err := xml.NewDecoder(resp.Body).Decode(&reply); err != nil {
-		return models.MateResult{}, temporal.NewNonRetryableApplicationError(
-			"failed to decode SEMP XML",
-			"DecodeError",
-			err,
-		)
+	if configSync, err := client.ShowConfigSync(ctx); err != nil {
+		logger.Warn("show config-sync failed", "kit", kit, "dc", dc, "mate", mate, "error", err)
+	} else {
+		configSyncStatus = configSync.Status.OperStatus
 	}
 
-	status := models.Standby
-
-	vr := reply.RPC.Show.Redundancy.VirtualRouters
-	if vr.Primary != nil && vr.Primary.Status.Activity == "Local Active" {
-		status = models.Active
-	}
-	if vr.Backup != nil && vr.Backup.Status.Activity == "Local Active" {
-		status = models.Active
-	}
+	status, vrActivity := classifyMate(*redundancy, spoolStatus)
 
-	activity.GetLogger(ctx).Info(
+	logger.Info(
 		"mate checked",
 		"kit", kit,
 		"dc", dc,
@@ -101,14 +88,20 @@ func CheckMateStatusActivity(
 		"host", ep.Host,
 		"port", ep.Port,
 		"status", status,
+		"activity", vrActivity,
+		"messageSpool", spoolStatus,
 	)
 
 	return models.MateResult{
-		Kit:    kit,
-		DC:     dc,
-		Mate:   mate,
-		Host:   ep.Host,
-		Port:   ep.Port,
-		Status: status,
+		Kit:              kit,
+		DC:               dc,
+		Mate:             mate,
+		Host:             ep.Host,
+		Port:             ep.Port,
+		Status:           status,
+		RedundancyStatus: redundancy.RedundancyStatus,
+		Activity:         vrActivity,
+		ConfigSync:       configSyncStatus,
+		MessageSpool:     spoolStatus,
 	}, nil
 }
@@ -0,0 +1,50 @@
+package activities
+
+import (
+	"encoding/xml"
+	"kits-worker/kits/models"
+	"testing"
+)
+
+func TestClassifyMate(t *testing.T) {
+	reply := func(config, primary, backup string) RedundancyReply {
+		var r sempV1Envelope
+		body := `<rpc-reply><rpc><show><redundancy><config-status>` + config + `</config-status><virtual-routers>`
+		if primary != "" {
+			body += `<primary><status><activity>` + primary + `</activity></status></primary>`
+		}
+		if backup != "" {
+			body += `<backup><status><activity>` + backup + `</activity></status></backup>`
+		}
+		body += `</virtual-routers></redundancy></show></rpc></rpc-reply>`
+		if err := xml.Unmarshal([]byte(body), &r); err != nil {
+			t.Fatal(err)
+		}
+		return *r.RPC.Show.Redundancy
+	}
+
+	cases := []struct {
+		name  string
+		r     RedundancyReply
+		spool string
+		want  models.MateStatus
+	}{
+		{"primary active", reply("Enabled", "Local Active", "Shutdown"), "AD-Active", models.Active},
+		{"backup took over", reply("Enabled", "Shutdown", "Local Active"), "AD-Active", models.Active},
+		{"mate active", reply("Enabled", "Mate Active", ""), "AD-Standby", models.Standby},
+		{"spool not active yet", reply("Enabled", "Local Active", ""), "AD-Standby", models.ADStandby},
+		{"shutdown", reply("Enabled", "Shutdown", "Local Inactive"), "", models.Down},
+		{"no virtual routers", reply("Enabled", "", ""), "", models.Unknown},
+		{"standalone", reply("Disabled", "Local Active", ""), "AD-Active", models.RedundancyDisabled},
+	}
+	for _, c := range cases {
+		if got, _ := classifyMate(c.r, c.spool); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+
+	standalone := models.MateResult{Status: models.RedundancyDisabled, MessageSpool: "AD-Active"}
+	if !standalone.Serving() {
+		t.Errorf("standalone broker with AD-Active spool should be serving")
+	}
+}
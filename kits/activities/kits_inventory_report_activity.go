@@ -0,0 +1,110 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"go.temporal.io/sdk/activity"
+)
+
+var (
+	reportDirMu sync.RWMutex
+	reportDir   = filepath.Join(os.TempDir(), config.REPORT_DEFAULT_DIR)
+)
+
+// SetReportDir sets the worker directory where reports are exported and
+// returns the previous one.
+func SetReportDir(dir string) string {
+	reportDirMu.Lock()
+	defer reportDirMu.Unlock()
+	prev := reportDir
+	reportDir = dir
+	return prev
+}
+
+func currentReportDir() string {
+	reportDirMu.RLock()
+	defer reportDirMu.RUnlock()
+	return reportDir
+}
+
+var inventoryCSVHeader = []string{
+	"kit", "dc", "mate", "host", "port",
+	"platform", "sempVersion", "apiVersion", "release", "build",
+	"redundancyMode", "redundancyStatus", "activeStandbyRole",
+	"configSyncAdminStatus", "configSyncOperStatus",
+	"vpnCount", "activeVpnCount", "standbyVpnCount",
+	"error",
+}
+
+// FleetInventoryCSV renders one CSV row per mate.
+func FleetInventoryCSV(inv models.FleetInventory) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(inventoryCSVHeader); err != nil {
+		return nil, err
+	}
+	for _, kit := range inv.Kits {
+		for _, m := range kit.Mates {
+			row := []string{
+				m.Kit, m.DC, m.Mate, m.Host, strconv.Itoa(m.Port),
+				m.Platform, m.SempVersion, m.ApiVersion, m.Release, m.Build,
+				m.RedundancyMode, m.RedundancyStatus, m.ActiveStandbyRole,
+				m.ConfigSyncAdminStatus, m.ConfigSyncOperStatus,
+				strconv.Itoa(m.VPNCount), strconv.Itoa(m.ActiveVPNCount), strconv.Itoa(m.StandbyVPNCount),
+				m.Error,
+			}
+			if err := w.Write(row); err != nil {
+				return nil, err
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// ExportFleetInventoryActivity writes the inventory as JSON and CSV into the
+// worker report directory and returns both paths.
+func ExportFleetInventoryActivity(
+	ctx context.Context,
+	name string,
+	inv models.FleetInventory,
+) ([]string, error) {
+
+	dir := currentReportDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	csvData, err := FleetInventoryCSV(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonPath := filepath.Join(dir, fmt.Sprintf("fleet-inventory-%s.json", name))
+	csvPath := filepath.Join(dir, fmt.Sprintf("fleet-inventory-%s.csv", name))
+
+	if err := os.WriteFile(jsonPath, jsonData, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(csvPath, csvData, 0o644); err != nil {
+		return nil, err
+	}
+
+	activity.GetLogger(ctx).Info("fleet inventory exported", "json", jsonPath, "csv", csvPath)
+	return []string{jsonPath, csvPath}, nil
+}
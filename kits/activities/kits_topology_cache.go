@@ -0,0 +1,141 @@
+package activities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TopologyCache is a local content-addressed store of downloaded topology
+// artifacts. Blobs are stored under their sha256 digest and an index keyed by
+// URL remembers the ETag/Last-Modified validators of the last download, so
+// unchanged artifacts are revalidated with a conditional GET instead of being
+// downloaded again.
+type TopologyCache struct {
+	dir string
+	mu  sync.Mutex
+}
+
+type topologyCacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Digest       string `json:"digest"`
+}
+
+var (
+	topologyCacheMu sync.RWMutex
+	topologyCache   *TopologyCache
+)
+
+// SetTopologyCache enables caching of remote topology artifacts. A nil
+// cache disables it.
+func SetTopologyCache(c *TopologyCache) {
+	topologyCacheMu.Lock()
+	defer topologyCacheMu.Unlock()
+	topologyCache = c
+}
+
+func currentTopologyCache() *TopologyCache {
+	topologyCacheMu.RLock()
+	defer topologyCacheMu.RUnlock()
+	return topologyCache
+}
+
+// NewTopologyCache creates the cache directory layout under dir.
+func NewTopologyCache(dir string) (*TopologyCache, error) {
+	for _, sub := range []string{"blobs", "index"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &TopologyCache{dir: dir}, nil
+}
+
+func (c *TopologyCache) indexPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, "index", hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *TopologyCache) blobPath(digest string) string {
+	return filepath.Join(c.dir, "blobs", digest)
+}
+
+// lookup returns the validators and digest of the last download of url,
+// provided its blob is still present.
+func (c *TopologyCache) lookup(url string) (*topologyCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.indexPath(url))
+	if err != nil {
+		return nil, false
+	}
+	var entry topologyCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url {
+		return nil, false
+	}
+	if _, err := os.Stat(c.blobPath(entry.Digest)); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// blob reads a cached artifact and checks it still matches its digest.
+func (c *TopologyCache) blob(digest string) ([]byte, error) {
+	data, err := os.ReadFile(c.blobPath(digest))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != digest {
+		os.Remove(c.blobPath(digest))
+		return nil, fmt.Errorf("cached topology blob %s is corrupt", digest)
+	}
+	return data, nil
+}
+
+// store saves data and records the validators for url.
+func (c *TopologyCache) store(url, etag, lastModified string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	if err := writeFileAtomic(c.blobPath(digest), data); err != nil {
+		return err
+	}
+
+	entry, err := json.Marshal(topologyCacheEntry{
+		URL:          url,
+		ETag:         etag,
+		LastModified: lastModified,
+		Digest:       digest,
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.indexPath(url), entry)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,67 @@
+package activities
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTopologyCache_ConditionalDownload(t *testing.T) {
+	body := []byte("kits: {}\n")
+	etag := `"v1"`
+	downloads := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	cache, err := NewTopologyCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTopologyCache(cache)
+	defer SetTopologyCache(nil)
+
+	src, err := NewTopologySource(srv.URL + "/kits.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if downloads != 1 {
+		t.Fatalf("expected a single full download, got %d", downloads)
+	}
+	if first.Cached || !second.Cached {
+		t.Fatalf("expected only the second fetch to be served from cache")
+	}
+	v1, v2 := topologyVersion(src, first), topologyVersion(src, second)
+	if v1.Digest != v2.Digest || v2.ETag != etag {
+		t.Fatalf("unexpected versions: %+v %+v", v1, v2)
+	}
+
+	// a changed artifact is downloaded and produces a new digest
+	body, etag = []byte("kits: {a: {}}\n"), `"v2"`
+	third, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Cached || topologyVersion(src, third).Digest == v1.Digest {
+		t.Fatalf("expected a fresh download with a new digest")
+	}
+}
@@ -1,84 +1,129 @@
 package activities
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"context"
-	"fmt"
-	"io"
-	"kits-worker/kits/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"kits-worker/kits/models"
-	"net/http"
-	"strings"
 
 	"go.temporal.io/sdk/activity"
-	"gopkg.in/yaml.v3"
+	"go.temporal.io/sdk/temporal"
 )
 
 func DownloadAndParseKitsActivity(
 	ctx context.Context,
 	url string,
 	filter string,
-) (map[string]models.Kit, error) {
+	integrity models.TopologyIntegrity,
+) (*models.Topology, error) {
 
-	resp, err := http.Get(url)
+	kitFilter, err := ParseKitFilter(filter)
 	if err != nil {
-		return nil, err
+		return nil, temporal.NewNonRetryableApplicationError(
+			err.Error(),
+			"KitFilterInvalid",
+			err,
+		)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	version, art, err := fetchVerifiedTopology(ctx, url, integrity)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create gzip reader
-	gzr, err := gzip.NewReader(resp.Body)
+	file, err := ParseKitsTopology(art.KitsYAML)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		var verr *TopologyValidationError
+		if errors.As(err, &verr) {
+			activity.GetLogger(ctx).Error("kits topology invalid", "problems", len(verr.Problems))
+			return nil, temporal.NewNonRetryableApplicationError(
+				verr.Error(),
+				"TopologyInvalid",
+				err,
+				verr.Problems,
+			)
+		}
+		return nil, err
 	}
-	defer gzr.Close()
 
-	tr := tar.NewReader(gzr)
+	result := make(map[string]models.Kit)
+	for name, kit := range file.Kits {
+		if !kitFilter.Match(name, kit) {
+			continue
+		}
+		result[name] = kit
+	}
 
-	var kitsYAML []byte
+	activity.GetLogger(ctx).Info(
+		"kits parsed",
+		"source", version.Source,
+		"digest", version.Digest,
+		"cached", version.Cached,
+		"count", len(result),
+	)
+	return &models.Topology{Version: version, Kits: result}, nil
+}
 
-	// Iterate through files in the tarball
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("tar read error: %w", err)
-		}
+// CheckTopologyVersionActivity returns the current version of the topology
+// without parsing it. Unchanged remote artifacts are revalidated with a
+// conditional GET, so long-running workflows can poll it cheaply and compare
+// Digest with the version they started from.
+func CheckTopologyVersionActivity(
+	ctx context.Context,
+	url string,
+	integrity models.TopologyIntegrity,
+) (models.TopologyVersion, error) {
 
-		// Match kits.yaml (allow nested paths)
-		if hdr.Typeflag == tar.TypeReg && strings.HasSuffix(hdr.Name, config.KIT_MESH_CONFIG_FILE) {
-			kitsYAML, err = io.ReadAll(tr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read %s: %w", config.KIT_MESH_CONFIG_FILE, err)
-			}
-			break
-		}
-	}
+	version, _, err := fetchVerifiedTopology(ctx, url, integrity)
+	return version, err
+}
+
+func fetchVerifiedTopology(
+	ctx context.Context,
+	url string,
+	integrity models.TopologyIntegrity,
+) (models.TopologyVersion, *TopologyArtifact, error) {
 
-	if kitsYAML == nil {
-		return nil, fmt.Errorf("%s not found in archive", config.KIT_MESH_CONFIG_FILE)
+	src, err := NewTopologySource(url)
+	if err != nil {
+		return models.TopologyVersion{}, nil, temporal.NewNonRetryableApplicationError(
+			err.Error(),
+			"TopologySourceInvalid",
+			err,
+		)
 	}
 
-	var file models.KitsFile
-	if err := yaml.Unmarshal(kitsYAML, &file); err != nil {
-		return nil, err
+	art, err := src.Fetch(ctx)
+	if err != nil {
+		return models.TopologyVersion{}, nil, err
 	}
 
-	result := make(map[string]models.Kit)
-	for name, kit := range file.Kits {
-		if filter != "" && !strings.Contains(name, filter) {
-			continue
+	if err := VerifyTopologyIntegrity(ctx, src, art, integrity); err != nil {
+		if errors.Is(err, ErrTopologyIntegrity) {
+			activity.GetLogger(ctx).Error("kits topology integrity check failed", "source", src.String(), "error", err)
+			return models.TopologyVersion{}, nil, temporal.NewNonRetryableApplicationError(
+				err.Error(),
+				"TopologyIntegrityError",
+				err,
+			)
 		}
-		result[name] = kit
+		return models.TopologyVersion{}, nil, err
 	}
 
-	activity.GetLogger(ctx).Info("kits parsed from tgz", "count", len(result))
-	return result, nil
+	return topologyVersion(src, art), art, nil
+}
+
+func topologyVersion(src TopologySource, art *TopologyArtifact) models.TopologyVersion {
+	kitsSum := sha256.Sum256(art.KitsYAML)
+	rawSum := sha256.Sum256(art.Raw)
+	return models.TopologyVersion{
+		Source:         src.String(),
+		Entry:          art.Entry,
+		Digest:         hex.EncodeToString(kitsSum[:]),
+		ArtifactDigest: hex.EncodeToString(rawSum[:]),
+		ETag:           art.ETag,
+		LastModified:   art.LastModified,
+		Cached:         art.Cached,
+	}
 }
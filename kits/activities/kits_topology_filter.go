@@ -0,0 +1,193 @@
+package activities
+
+import (
+	"fmt"
+	"kits-worker/kits/models"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// KitFilter selects kits by name and labels.
+//
+// A filter expression is a list of terms separated by commas or whitespace
+// (a /regex/ term may contain both):
+//   - kit-prod-1         exact kit name
+//   - kit-prod-*         glob (*, ? and [...] as in path.Match)
+//   - /^kit-prod-[0-9]$/ regular expression (unanchored unless anchored explicitly)
+//   - @env=prod          label selector: label env equals prod
+//   - @env!=prod         label selector: label env missing or different
+//   - @env               label selector: label env present
+//   - !term              excludes kits matching term (any of the above)
+//
+// A kit is selected when it matches at least one name term (or there are
+// no name terms), matches every label selector and matches no exclusion.
+// An empty expression selects every kit.
+type KitFilter struct {
+	include []kitMatcher
+	labels  []kitMatcher
+	exclude []kitMatcher
+}
+
+type kitMatcher func(name string, kit models.Kit) bool
+
+// ParseKitFilter compiles a filter expression.
+func ParseKitFilter(expr string) (*KitFilter, error) {
+	f := &KitFilter{}
+
+	terms, err := splitKitFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		negate := strings.HasPrefix(term, "!")
+		if negate {
+			term = term[1:]
+		}
+
+		m, isLabel, err := compileKitTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case negate:
+			f.exclude = append(f.exclude, m)
+		case isLabel:
+			f.labels = append(f.labels, m)
+		default:
+			f.include = append(f.include, m)
+		}
+	}
+
+	return f, nil
+}
+
+func isKitFilterSeparator(c byte) bool {
+	return c == ',' || c == ' ' || c == '\t' || c == '\n'
+}
+
+// splitKitFilter cuts expr into terms. A /regex/ term may contain separators
+// (ie {1,3}): it ends at the first unescaped / followed by a separator or the
+// end of the expression.
+func splitKitFilter(expr string) ([]string, error) {
+	var terms []string
+	for i := 0; i < len(expr); {
+		if isKitFilterSeparator(expr[i]) {
+			i++
+			continue
+		}
+
+		start, j := i, i
+		if expr[j] == '!' {
+			j++
+		}
+		if j < len(expr) && expr[j] == '/' {
+			end := -1
+			for k := j + 1; k < len(expr); k++ {
+				if expr[k] == '\\' {
+					k++
+					continue
+				}
+				if expr[k] == '/' && (k+1 == len(expr) || isKitFilterSeparator(expr[k+1])) {
+					end = k + 1
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated regex filter %q", expr[start:])
+			}
+			terms = append(terms, expr[start:end])
+			i = end
+			continue
+		}
+
+		for i < len(expr) && !isKitFilterSeparator(expr[i]) {
+			i++
+		}
+		terms = append(terms, expr[start:i])
+	}
+	return terms, nil
+}
+
+func compileKitTerm(term string) (kitMatcher, bool, error) {
+	switch {
+	case term == "":
+		return nil, false, fmt.Errorf("empty filter term")
+
+	case strings.HasPrefix(term, "@"):
+		return compileLabelSelector(term[1:])
+
+	case len(term) >= 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/"):
+		re, err := regexp.Compile(term[1 : len(term)-1])
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid regex filter %q: %w", term, err)
+		}
+		return func(name string, _ models.Kit) bool { return re.MatchString(name) }, false, nil
+
+	case strings.ContainsAny(term, "*?["):
+		if _, err := path.Match(term, ""); err != nil {
+			return nil, false, fmt.Errorf("invalid glob filter %q: %w", term, err)
+		}
+		return func(name string, _ models.Kit) bool {
+			ok, _ := path.Match(term, name)
+			return ok
+		}, false, nil
+
+	default:
+		return func(name string, _ models.Kit) bool { return name == term }, false, nil
+	}
+}
+
+func compileLabelSelector(sel string) (kitMatcher, bool, error) {
+	if i := strings.Index(sel, "!="); i >= 0 {
+		key, value := sel[:i], sel[i+2:]
+		if key == "" {
+			return nil, true, fmt.Errorf("invalid label selector %q", "@"+sel)
+		}
+		return func(_ string, kit models.Kit) bool {
+			v, ok := kit.Labels[key]
+			return !ok || v != value
+		}, true, nil
+	}
+	if i := strings.Index(sel, "="); i >= 0 {
+		key, value := sel[:i], sel[i+1:]
+		if key == "" {
+			return nil, true, fmt.Errorf("invalid label selector %q", "@"+sel)
+		}
+		return func(_ string, kit models.Kit) bool {
+			v, ok := kit.Labels[key]
+			return ok && v == value
+		}, true, nil
+	}
+	if sel == "" {
+		return nil, true, fmt.Errorf("invalid label selector %q", "@")
+	}
+	return func(_ string, kit models.Kit) bool {
+		_, ok := kit.Labels[sel]
+		return ok
+	}, true, nil
+}
+
+// Match reports whether the kit is selected by the filter.
+func (f *KitFilter) Match(name string, kit models.Kit) bool {
+	for _, m := range f.exclude {
+		if m(name, kit) {
+			return false
+		}
+	}
+	for _, m := range f.labels {
+		if !m(name, kit) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, m := range f.include {
+		if m(name, kit) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package activities
+
+import (
+	"kits-worker/kits/models"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestKitFilter(t *testing.T) {
+	kits := map[string]models.Kit{
+		"kit-prod-1":  {Labels: map[string]string{"env": "prod", "region": "sg"}},
+		"kit-prod-10": {Labels: map[string]string{"env": "prod", "region": "hk"}},
+		"kit-prod-2":  {Labels: map[string]string{"env": "prod"}},
+		"kit-dev-1":   {Labels: map[string]string{"env": "dev", "region": "sg"}},
+		"other":       {},
+	}
+
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"", []string{"kit-dev-1", "kit-prod-1", "kit-prod-10", "kit-prod-2", "other"}},
+		{"kit-prod-1", []string{"kit-prod-1"}},
+		{"kit-prod-1, kit-dev-1", []string{"kit-dev-1", "kit-prod-1"}},
+		{"kit-prod-?", []string{"kit-prod-1", "kit-prod-2"}},
+		{"/^kit-prod-1[0-9]*$/", []string{"kit-prod-1", "kit-prod-10"}},
+		{"kit-*,!kit-prod-10", []string{"kit-dev-1", "kit-prod-1", "kit-prod-2"}},
+		{"@env=prod,@region", []string{"kit-prod-1", "kit-prod-10"}},
+		{"kit-*,@env!=prod", []string{"kit-dev-1"}},
+		{"!@env", []string{"other"}},
+		{"/^kit-prod-[0-9]{1,3}$/", []string{"kit-prod-1", "kit-prod-10", "kit-prod-2"}},
+		{"kit-*, !/^kit-[a-z]+-[0-9]{2,}$/", []string{"kit-dev-1", "kit-prod-1", "kit-prod-2"}},
+		{"/^(other|kit-dev-1)( |$)/ kit-prod-2", []string{"kit-dev-1", "kit-prod-2", "other"}},
+	}
+
+	for _, c := range cases {
+		f, err := ParseKitFilter(c.expr)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", c.expr, err)
+		}
+		var got []string
+		for name, kit := range kits {
+			if f.Match(name, kit) {
+				got = append(got, name)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: expected %v, got %v", c.expr, c.want, got)
+		}
+	}
+
+	for _, bad := range []string{"/[/", "kit-[", "@", "@=x", "!", "/kit-[0-9]{1,3}"} {
+		if _, err := ParseKitFilter(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package activities
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrTopologyIntegrity marks checksum and signature mismatches.
+var ErrTopologyIntegrity = errors.New("topology integrity check failed")
+
+var (
+	signingKeysMu sync.RWMutex
+	signingKeys   []ed25519.PublicKey
+)
+
+// SetTopologySigningKeys configures the ed25519 public keys trusted to sign
+// topology artifacts. Once keys are set every artifact must carry a valid
+// detached signature.
+func SetTopologySigningKeys(keys []ed25519.PublicKey) {
+	signingKeysMu.Lock()
+	defer signingKeysMu.Unlock()
+	signingKeys = keys
+}
+
+func topologySigningKeys() []ed25519.PublicKey {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+	return signingKeys
+}
+
+// LoadTopologySigningKeys reads ed25519 public keys from a file containing
+// PEM "PUBLIC KEY" blocks and/or base64 encoded raw keys, one per line.
+func LoadTopologySigningKeys(path string) ([]ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []ed25519.PublicKey
+	rest := data
+	for {
+		block, next := pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		edKey, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: key is %T, expected ed25519", path, pub)
+		}
+		keys = append(keys, edKey)
+		rest = next
+	}
+
+	for _, line := range strings.Split(string(rest), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: invalid ed25519 public key %q", path, line)
+		}
+		keys = append(keys, ed25519.PublicKey(raw))
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no public keys found", path)
+	}
+	return keys, nil
+}
+
+// VerifyTopologyIntegrity checks the artifact against the requested checksum
+// and, when keys are configured or a signature is required, its detached
+// ed25519 signature. Mismatches and missing sidecars wrap
+// ErrTopologyIntegrity.
+func VerifyTopologyIntegrity(
+	ctx context.Context,
+	src TopologySource,
+	art *TopologyArtifact,
+	integrity models.TopologyIntegrity,
+) error {
+
+	sum := sha256.Sum256(art.Raw)
+	actual := hex.EncodeToString(sum[:])
+
+	if integrity.SHA256 != "" {
+		if !strings.EqualFold(strings.TrimSpace(integrity.SHA256), actual) {
+			return fmt.Errorf("%w: %s sha256 is %s, expected %s", ErrTopologyIntegrity, src, actual, integrity.SHA256)
+		}
+	}
+
+	if integrity.SHA256Sidecar {
+		sidecar, err := src.Sidecar(ctx, config.TOPOLOGY_SHA256_SIDECAR_SUFFIX)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s has no %s sidecar", ErrTopologyIntegrity, src, config.TOPOLOGY_SHA256_SIDECAR_SUFFIX)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch checksum for %s: %w", src, err)
+		}
+		// accept both a bare digest and sha256sum output ("<digest>  <file>")
+		fields := strings.Fields(string(sidecar))
+		if len(fields) == 0 || !strings.EqualFold(fields[0], actual) {
+			return fmt.Errorf("%w: %s sha256 is %s, does not match %s sidecar", ErrTopologyIntegrity, src, actual, config.TOPOLOGY_SHA256_SIDECAR_SUFFIX)
+		}
+	}
+
+	keys := topologySigningKeys()
+	if len(keys) == 0 {
+		if integrity.RequireSignature {
+			return fmt.Errorf("%w: signature required but no public keys configured on worker (%s)", ErrTopologyIntegrity, config.TOPOLOGY_PUBLIC_KEYS_ENV)
+		}
+		return nil
+	}
+
+	// a missing signature is final, only a failed download is retried
+	sigData, err := src.Sidecar(ctx, config.TOPOLOGY_SIGNATURE_SIDECAR_SUFFIX)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s is not signed, no %s sidecar", ErrTopologyIntegrity, src, config.TOPOLOGY_SIGNATURE_SIDECAR_SUFFIX)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch signature for %s: %w", src, err)
+	}
+	sig, err := decodeSignature(sigData)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrTopologyIntegrity, src, err)
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, art.Raw, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s signature does not match any configured public key", ErrTopologyIntegrity, src)
+}
+
+// decodeSignature accepts a raw 64 byte signature or its base64 encoding.
+func decodeSignature(data []byte) ([]byte, error) {
+	if len(data) == ed25519.SignatureSize {
+		return data, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("signature is neither raw nor base64 encoded ed25519")
+	}
+	return sig, nil
+}
@@ -0,0 +1,94 @@
+package activities
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyTopologyIntegrity(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "kits.yaml")
+	content := []byte("kits: {}\n")
+	os.WriteFile(file, content, 0o644)
+
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	os.WriteFile(file+".sha256", []byte(digest+"  kits.yaml\n"), 0o644)
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	os.WriteFile(file+".sig", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, content))), 0o644)
+
+	src, err := NewTopologySource(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	art, err := src.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetTopologySigningKeys(nil)
+	ctx := context.Background()
+
+	if err := VerifyTopologyIntegrity(ctx, src, art, models.TopologyIntegrity{SHA256: digest, SHA256Sidecar: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := VerifyTopologyIntegrity(ctx, src, art, models.TopologyIntegrity{SHA256: "00"}); !errors.Is(err, ErrTopologyIntegrity) {
+		t.Fatalf("expected digest mismatch, got %v", err)
+	}
+	if err := VerifyTopologyIntegrity(ctx, src, art, models.TopologyIntegrity{RequireSignature: true}); !errors.Is(err, ErrTopologyIntegrity) {
+		t.Fatalf("expected missing keys error, got %v", err)
+	}
+
+	SetTopologySigningKeys([]ed25519.PublicKey{pub})
+	if err := VerifyTopologyIntegrity(ctx, src, art, models.TopologyIntegrity{}); err != nil {
+		t.Fatalf("unexpected signature error: %v", err)
+	}
+
+	// an unsigned artifact is refused, not retried
+	unsigned := filepath.Join(dir, "unsigned.yaml")
+	os.WriteFile(unsigned, content, 0o644)
+	unsignedSrc, err := NewTopologySource(unsigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyTopologyIntegrity(ctx, unsignedSrc, art, models.TopologyIntegrity{}); !errors.Is(err, ErrTopologyIntegrity) {
+		t.Fatalf("expected missing signature error, got %v", err)
+	}
+	if err := VerifyTopologyIntegrity(ctx, unsignedSrc, art, models.TopologyIntegrity{SHA256Sidecar: true}); !errors.Is(err, ErrTopologyIntegrity) {
+		t.Fatalf("expected missing checksum error, got %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/kits.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer srv.Close()
+	remote, err := NewTopologySource(srv.URL + "/kits.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyTopologyIntegrity(ctx, remote, art, models.TopologyIntegrity{}); !errors.Is(err, ErrTopologyIntegrity) {
+		t.Fatalf("expected missing remote signature error, got %v", err)
+	}
+
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	SetTopologySigningKeys([]ed25519.PublicKey{other})
+	if err := VerifyTopologyIntegrity(ctx, src, art, models.TopologyIntegrity{}); !errors.Is(err, ErrTopologyIntegrity) {
+		t.Fatalf("expected signature mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,465 @@
+package activities
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"kits-worker/kits/config"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TopologyArtifact is what a TopologySource downloaded: the artifact exactly
+// as published (used for checksums and signatures) and the kits.yaml
+// content extracted from it.
+type TopologyArtifact struct {
+	Raw      []byte
+	Entry    string
+	KitsYAML []byte
+
+	// HTTP validators of the download and whether the artifact was served
+	// from the local cache after a 304 Not Modified.
+	ETag         string
+	LastModified string
+	Cached       bool
+}
+
+// TopologySource fetches the kits topology from wherever it is published.
+type TopologySource interface {
+	Fetch(ctx context.Context) (*TopologyArtifact, error)
+	// Sidecar reads a companion file published next to the artifact,
+	// ie the ".sha256" or ".sig" file.
+	Sidecar(ctx context.Context, suffix string) ([]byte, error)
+	String() string
+}
+
+// NewTopologySource selects a TopologySource from the scheme of kitsURL.
+//
+// Supported forms:
+//   - /path/kits.yaml, file:///path/kits.yaml: local YAML file
+//   - /path/dir, file:///path/dir, dir:///path/dir: directory containing kits.yaml
+//   - http(s)://host/kits.yaml (or .yml): plain YAML
+//   - http(s)://host/topology.zip: zip archive
+//   - http(s)://host/topology.tar.gz (or .tgz): gzipped tarball (default for http)
+//   - yaml+, zip+ or tgz+ prefixed schemes (ie tgz+https://host/download?id=1)
+//     force the format when the path has no usable extension.
+//
+// For archives and directories the file inside is selected with the URL
+// fragment (ie https://host/topology.tgz#mesh/prod/kits.yaml). Without a
+// fragment the entry whose base name is kits.yaml is used, and more than one
+// such entry is an error.
+func NewTopologySource(kitsURL string) (TopologySource, error) {
+	u, err := url.Parse(kitsURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kits URL %q: %w", kitsURL, err)
+	}
+
+	format, scheme := "", u.Scheme
+	if i := strings.Index(scheme, "+"); i >= 0 {
+		format, scheme = scheme[:i], scheme[i+1:]
+	}
+	inner := strings.TrimPrefix(u.Fragment, "/")
+
+	var res resource
+	switch scheme {
+	case "", "file", "dir":
+		location := u.Path
+		if u.Host != "" {
+			location = u.Host + u.Path
+		}
+		if location == "" {
+			return nil, fmt.Errorf("kits URL %q has no path", kitsURL)
+		}
+		if format == "" {
+			info, statErr := os.Stat(location)
+			if scheme == "dir" || (statErr == nil && info.IsDir()) {
+				return &directorySource{dir: location, inner: inner}, nil
+			}
+		}
+		res = resource{location: location}
+	case config.HTTP_SCHEME, config.HTTPS_SCHEME:
+		u.Scheme, u.Fragment = scheme, ""
+		res = resource{location: u.String(), remote: true}
+	default:
+		return nil, fmt.Errorf("unsupported kits URL scheme %q", u.Scheme)
+	}
+
+	if format == "" {
+		format = formatFromPath(u.Path, scheme)
+	}
+
+	switch format {
+	case "yaml", "yml":
+		return &yamlSource{res: res}, nil
+	case "zip":
+		return &zipSource{res: res, inner: inner}, nil
+	case "tgz", "tar.gz":
+		return &tarballSource{res: res, inner: inner}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kits URL format %q", format)
+	}
+}
+
+func formatFromPath(p string, scheme string) string {
+	lower := strings.ToLower(p)
+	switch {
+	case strings.HasSuffix(lower, ".yaml"), strings.HasSuffix(lower, ".yml"):
+		return "yaml"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tgz"
+	case scheme == config.HTTP_SCHEME || scheme == config.HTTPS_SCHEME:
+		// historical default: the mesh config is published as a tarball
+		return "tgz"
+	default:
+		return "yaml"
+	}
+}
+
+// resource is a single file on local disk or reachable over http(s).
+type resource struct {
+	location string
+	remote   bool
+}
+
+func (r resource) read(ctx context.Context) ([]byte, error) {
+	art, err := r.get(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return art.Raw, nil
+}
+
+// fetch reads the artifact, revalidating against the topology cache (if
+// one is configured) with If-None-Match/If-Modified-Since.
+func (r resource) fetch(ctx context.Context) (*TopologyArtifact, error) {
+	return r.get(ctx, currentTopologyCache())
+}
+
+func (r resource) get(ctx context.Context, cache *TopologyCache) (*TopologyArtifact, error) {
+	if !r.remote {
+		raw, err := os.ReadFile(r.location)
+		if err != nil {
+			return nil, err
+		}
+		return &TopologyArtifact{Raw: raw}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cached *topologyCacheEntry
+	if cache != nil {
+		if entry, ok := cache.lookup(r.location); ok {
+			cached = entry
+			if entry.ETag != "" {
+				req.Header.Set("If-None-Match", entry.ETag)
+			}
+			if entry.LastModified != "" {
+				req.Header.Set("If-Modified-Since", entry.LastModified)
+			}
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		raw, err := cache.blob(cached.Digest)
+		if err == nil {
+			return &TopologyArtifact{
+				Raw:          raw,
+				ETag:         cached.ETag,
+				LastModified: cached.LastModified,
+				Cached:       true,
+			}, nil
+		}
+		// cache is unusable, download unconditionally
+		return r.get(ctx, nil)
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, fmt.Errorf("download of %s failed: %s: %w", r.location, resp.Status, fs.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of %s failed: %s", r.location, resp.Status)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	art := &TopologyArtifact{
+		Raw:          raw,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if cache != nil {
+		// caching is best effort, a failure only costs a download next time
+		_ = cache.store(r.location, art.ETag, art.LastModified, raw)
+	}
+	return art, nil
+}
+
+// sidecar returns the resource published next to r with suffix appended to
+// its path (the query string, if any, is kept).
+func (r resource) sidecar(suffix string) resource {
+	if !r.remote {
+		return resource{location: r.location + suffix}
+	}
+	u, err := url.Parse(r.location)
+	if err != nil {
+		return resource{location: r.location + suffix, remote: true}
+	}
+	u.Path += suffix
+	u.RawPath = ""
+	return resource{location: u.String(), remote: true}
+}
+
+// yamlSource reads kits.yaml directly from a file or URL.
+type yamlSource struct {
+	res resource
+}
+
+func (s *yamlSource) Fetch(ctx context.Context) (*TopologyArtifact, error) {
+	art, err := s.res.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	art.KitsYAML = art.Raw
+	return art, nil
+}
+
+func (s *yamlSource) Sidecar(ctx context.Context, suffix string) ([]byte, error) {
+	return s.res.sidecar(suffix).read(ctx)
+}
+
+func (s *yamlSource) String() string { return "yaml:" + s.res.location }
+
+// directorySource reads kits.yaml from an unpacked checkout on disk. The
+// selected file itself is treated as the artifact.
+type directorySource struct {
+	dir   string
+	inner string
+}
+
+func (s *directorySource) selectFile() (string, error) {
+	if s.inner != "" {
+		// the fragment selects a file of the checkout, never one outside it
+		name := filepath.Clean(filepath.FromSlash(s.inner))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("%q is outside of %s", s.inner, s.dir)
+		}
+		return filepath.Join(s.dir, name), nil
+	}
+
+	var candidates []string
+	err := filepath.WalkDir(s.dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && p != s.dir {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && d.Name() == config.KIT_MESH_CONFIG_FILE {
+			rel, _ := filepath.Rel(s.dir, p)
+			candidates = append(candidates, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	name, err := pickTopologyEntry(candidates, s.String())
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name)), nil
+}
+
+func (s *directorySource) Fetch(ctx context.Context) (*TopologyArtifact, error) {
+	file, err := s.selectFile()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rel, _ := filepath.Rel(s.dir, file)
+	return &TopologyArtifact{Raw: raw, Entry: filepath.ToSlash(rel), KitsYAML: raw}, nil
+}
+
+func (s *directorySource) Sidecar(ctx context.Context, suffix string) ([]byte, error) {
+	file, err := s.selectFile()
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(file + suffix)
+}
+
+func (s *directorySource) String() string { return "dir:" + s.dir }
+
+// tarballSource extracts kits.yaml from a gzipped tarball.
+type tarballSource struct {
+	res   resource
+	inner string
+}
+
+func (s *tarballSource) Fetch(ctx context.Context) (*TopologyArtifact, error) {
+	art, err := s.res.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gzr, err := gzip.NewReader(bytes.NewReader(art.Raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+
+	// Entries can only be read while iterating, so keep every candidate
+	// until we know whether the selection is ambiguous.
+	contents := make(map[string][]byte)
+	var candidates []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tar read error: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := cleanEntryName(hdr.Name)
+		if !entryMatches(name, s.inner) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		contents[name] = data
+		candidates = append(candidates, name)
+	}
+
+	name, err := pickTopologyEntry(candidates, s.String())
+	if err != nil {
+		return nil, err
+	}
+	art.Entry, art.KitsYAML = name, contents[name]
+	return art, nil
+}
+
+func (s *tarballSource) Sidecar(ctx context.Context, suffix string) ([]byte, error) {
+	return s.res.sidecar(suffix).read(ctx)
+}
+
+func (s *tarballSource) String() string { return "tgz:" + s.res.location }
+
+// zipSource extracts kits.yaml from a zip archive.
+type zipSource struct {
+	res   resource
+	inner string
+}
+
+func (s *zipSource) Fetch(ctx context.Context) (*TopologyArtifact, error) {
+	art, err := s.res.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(art.Raw), int64(len(art.Raw)))
+	if err != nil {
+		return nil, fmt.Errorf("zip read error: %w", err)
+	}
+
+	files := make(map[string]*zip.File)
+	var candidates []string
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := cleanEntryName(f.Name)
+		if entryMatches(name, s.inner) {
+			files[name] = f
+			candidates = append(candidates, name)
+		}
+	}
+
+	name, err := pickTopologyEntry(candidates, s.String())
+	if err != nil {
+		return nil, err
+	}
+
+	fr, err := files[name].Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer fr.Close()
+
+	kitsYAML, err := io.ReadAll(fr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	art.Entry, art.KitsYAML = name, kitsYAML
+	return art, nil
+}
+
+func (s *zipSource) Sidecar(ctx context.Context, suffix string) ([]byte, error) {
+	return s.res.sidecar(suffix).read(ctx)
+}
+
+func (s *zipSource) String() string { return "zip:" + s.res.location }
+
+func cleanEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// entryMatches selects the explicit inner path if one was given, otherwise
+// any entry named exactly kits.yaml.
+func entryMatches(name string, inner string) bool {
+	if inner != "" {
+		return name == cleanEntryName(inner)
+	}
+	return path.Base(name) == config.KIT_MESH_CONFIG_FILE
+}
+
+func pickTopologyEntry(candidates []string, source string) (string, error) {
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("%s not found in %s", config.KIT_MESH_CONFIG_FILE, source)
+	case 1:
+		return candidates[0], nil
+	default:
+		sort.Strings(candidates)
+		return "", fmt.Errorf(
+			"multiple %s found in %s (%s); select one with #<path> in the kits URL",
+			config.KIT_MESH_CONFIG_FILE,
+			source,
+			strings.Join(candidates, ", "),
+		)
+	}
+}
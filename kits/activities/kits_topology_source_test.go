@@ -0,0 +1,126 @@
+package activities
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gzw.Close()
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestNewTopologySource_Fetch(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "checkout", "prod"), 0o755)
+	os.WriteFile(filepath.Join(dir, "kits.yaml"), []byte("local"), 0o644)
+	os.WriteFile(filepath.Join(dir, "checkout", "prod", "kits.yaml"), []byte("dir"), 0o644)
+
+	tarball := buildTarball(t, map[string]string{
+		"mesh/old-kits.yaml": "wrong",
+		"mesh/kits.yaml":     "tgz",
+	})
+	nested := buildTarball(t, map[string]string{
+		"np/kits.yaml":   "np",
+		"prod/kits.yaml": "prod",
+	})
+	zipped := buildZip(t, map[string]string{"a/kits.yaml": "zip"})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mesh.tar.gz", "/download":
+			w.Write(tarball)
+		case "/nested.tgz":
+			w.Write(nested)
+		case "/mesh.zip":
+			w.Write(zipped)
+		case "/kits.yaml":
+			w.Write([]byte("http"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{filepath.Join(dir, "kits.yaml"), "local"},
+		{"file://" + filepath.Join(dir, "kits.yaml"), "local"},
+		{"dir://" + filepath.Join(dir, "checkout"), "dir"},
+		{srv.URL + "/kits.yaml", "http"},
+		{srv.URL + "/mesh.tar.gz", "tgz"},
+		{"tgz+" + srv.URL + "/download", "tgz"},
+		{srv.URL + "/nested.tgz#prod/kits.yaml", "prod"},
+		{srv.URL + "/mesh.zip", "zip"},
+	}
+	for _, c := range cases {
+		src, err := NewTopologySource(c.url)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.url, err)
+		}
+		got, err := src.Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: fetch failed: %v", c.url, err)
+		}
+		if string(got.KitsYAML) != c.want {
+			t.Fatalf("%s: expected %q, got %q", c.url, c.want, got.KitsYAML)
+		}
+	}
+
+	// ambiguous selection must be explicit
+	src, _ := NewTopologySource(srv.URL + "/nested.tgz")
+	if _, err := src.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "multiple") {
+		t.Fatalf("expected ambiguity error, got %v", err)
+	}
+
+	// the fragment of a checkout must stay inside it
+	for _, fragment := range []string{"../kits.yaml", "prod/../../kits.yaml", "//" + filepath.Join(dir, "kits.yaml")} {
+		src, _ := NewTopologySource("dir://" + filepath.Join(dir, "checkout") + "#" + fragment)
+		if _, err := src.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Fatalf("%s: expected the fragment to be refused, got %v", fragment, err)
+		}
+	}
+
+	if _, err := NewTopologySource("ftp://host/kits.yaml"); err == nil {
+		t.Fatalf("expected unsupported scheme error")
+	}
+}
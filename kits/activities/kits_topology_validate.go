@@ -0,0 +1,348 @@
+package activities
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TopologyProblem is a single finding reported by the kits.yaml validator.
+type TopologyProblem struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p TopologyProblem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", p.Line, p.Path, p.Message)
+}
+
+// TopologyValidationError carries every problem found in kits.yaml so that
+// operators can fix the file in one go instead of one error per run.
+type TopologyValidationError struct {
+	Problems []TopologyProblem `json:"problems"`
+}
+
+func (e *TopologyValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		lines = append(lines, p.String())
+	}
+	return fmt.Sprintf(
+		"%s invalid (%d problem(s)): %s",
+		config.KIT_MESH_CONFIG_FILE,
+		len(e.Problems),
+		strings.Join(lines, "; "),
+	)
+}
+
+// ParseKitsTopology validates raw kits.yaml content and decodes it.
+//
+// Validation covers required fields, port ranges, host syntax (IP or DNS
+// name), hosts used by more than one endpoint and unknown or duplicated keys. All
+// problems are returned together in a *TopologyValidationError.
+func ParseKitsTopology(data []byte) (models.KitsFile, error) {
+	var file models.KitsFile
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return file, &TopologyValidationError{Problems: []TopologyProblem{{Message: err.Error()}}}
+	}
+
+	v := &topologyValidator{hosts: make(map[string]endpointRef)}
+	v.validateDocument(&root)
+	if len(v.problems) > 0 {
+		sort.SliceStable(v.problems, func(i, j int) bool {
+			return v.problems[i].Line < v.problems[j].Line
+		})
+		return file, &TopologyValidationError{Problems: v.problems}
+	}
+
+	// strict decoding as a last line of defence for anything the walker missed
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return file, &TopologyValidationError{Problems: []TopologyProblem{{Message: err.Error()}}}
+	}
+
+	return file, nil
+}
+
+// endpointRef records where a host was first used.
+type endpointRef struct {
+	path string
+	line int
+}
+
+type topologyValidator struct {
+	problems []TopologyProblem
+	hosts    map[string]endpointRef
+}
+
+func (v *topologyValidator) add(n *yaml.Node, path, format string, args ...interface{}) {
+	p := TopologyProblem{Path: path, Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		p.Line = n.Line
+		p.Column = n.Column
+	}
+	v.problems = append(v.problems, p)
+}
+
+func (v *topologyValidator) validateDocument(root *yaml.Node) {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		v.add(root, "", "document is empty")
+		return
+	}
+
+	fields := v.mapping(root.Content[0], "", "kits")
+	if fields == nil {
+		return
+	}
+
+	kits, ok := fields["kits"]
+	if !ok {
+		v.add(root.Content[0], "", "missing required key %q", "kits")
+		return
+	}
+	v.validateKits(kits, "kits")
+}
+
+func (v *topologyValidator) validateKits(n *yaml.Node, path string) {
+	if n.Kind != yaml.MappingNode {
+		v.add(n, path, "expected a mapping of kit name to kit")
+		return
+	}
+	if len(n.Content) == 0 {
+		v.add(n, path, "no kits defined")
+		return
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		name := key.Value
+		if name == "" {
+			v.add(key, path, "kit name must not be empty")
+			continue
+		}
+		if line, dup := seen[name]; dup {
+			v.add(key, path+"."+name, "duplicate kit (first defined on line %d)", line)
+			continue
+		}
+		seen[name] = key.Line
+		v.validateKit(key, value, path+"."+name)
+	}
+}
+
+// validateKit and the functions below report missing keys against owner,
+// the key node of the parent mapping, so the line points at the object name.
+func (v *topologyValidator) validateKit(owner, n *yaml.Node, path string) {
+	fields := v.mapping(n, path, "dc1", "dc2", "labels")
+	if fields == nil {
+		return
+	}
+	if labels, ok := fields["labels"]; ok {
+		v.validateLabels(labels, path+".labels")
+	}
+	prefixes := make(map[string][]sitePrefix)
+	for _, dc := range []string{"dc1", "dc2"} {
+		dcNode, ok := fields[dc]
+		if !ok {
+			v.add(owner, path, "missing required key %q", dc)
+			continue
+		}
+		prefixes[dc] = v.validateDC(keyNode(n, dc), dcNode, path+"."+dc)
+	}
+
+	// an address of both DCs would make the DNS watcher unable to tell them apart
+	for _, p2 := range prefixes["dc2"] {
+		for _, p1 := range prefixes["dc1"] {
+			if p1.prefix.Overlaps(p2.prefix) {
+				v.add(p2.node, path+".dc2.vpnAddresses", "%s overlaps dc1 address %s", p2.prefix, p1.prefix)
+			}
+		}
+	}
+}
+
+// sitePrefix is a parsed vpnAddresses entry.
+type sitePrefix struct {
+	prefix netip.Prefix
+	node   *yaml.Node
+}
+
+func (v *topologyValidator) validateLabels(n *yaml.Node, path string) {
+	if n.Kind != yaml.MappingNode {
+		v.add(n, path, "expected a mapping of label name to value")
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Value == "" {
+			v.add(key, path, "label name must not be empty")
+		}
+		if value.Kind != yaml.ScalarNode {
+			v.add(value, path+"."+key.Value, "label value must be a scalar")
+		}
+	}
+}
+
+func (v *topologyValidator) validateDC(owner, n *yaml.Node, path string) []sitePrefix {
+	fields := v.mapping(n, path, "mate1", "mate2", "vpnAddresses")
+	if fields == nil {
+		return nil
+	}
+	for _, mate := range []string{"mate1", "mate2"} {
+		mateNode, ok := fields[mate]
+		if !ok {
+			v.add(owner, path, "missing required key %q", mate)
+			continue
+		}
+		v.validateEndpoint(keyNode(n, mate), mateNode, path+"."+mate)
+	}
+	if addrs, ok := fields["vpnAddresses"]; ok {
+		return v.validateVPNAddresses(addrs, path+".vpnAddresses")
+	}
+	return nil
+}
+
+func (v *topologyValidator) validateVPNAddresses(n *yaml.Node, path string) []sitePrefix {
+	if n.Kind != yaml.SequenceNode {
+		v.add(n, path, "expected a list of IP addresses or CIDR prefixes")
+		return nil
+	}
+	var prefixes []sitePrefix
+	for _, item := range n.Content {
+		if item.Kind != yaml.ScalarNode {
+			v.add(item, path, "expected an IP address or CIDR prefix")
+			continue
+		}
+		p, err := models.ParseSiteAddress(item.Value)
+		if err != nil {
+			v.add(item, path, "%q is neither an IP address nor a CIDR prefix", item.Value)
+			continue
+		}
+		prefixes = append(prefixes, sitePrefix{prefix: p, node: item})
+	}
+	return prefixes
+}
+
+func (v *topologyValidator) validateEndpoint(owner, n *yaml.Node, path string) {
+	fields := v.mapping(n, path, "host", "port")
+	if fields == nil {
+		return
+	}
+
+	host, hostOK := fields["host"]
+	if !hostOK {
+		v.add(owner, path, "missing required key %q", "host")
+	} else if host.Kind != yaml.ScalarNode || strings.TrimSpace(host.Value) == "" {
+		v.add(host, path+".host", "host must not be empty")
+		hostOK = false
+	} else if !validHost(host.Value) {
+		v.add(host, path+".host", "%q is neither an IP address nor a valid DNS name", host.Value)
+		hostOK = false
+	}
+
+	if port, ok := fields["port"]; !ok {
+		v.add(owner, path, "missing required key %q", "port")
+	} else if p, err := strconv.Atoi(port.Value); port.Kind != yaml.ScalarNode || port.Tag != "!!int" || err != nil {
+		v.add(port, path+".port", "port must be an integer, got %q", port.Value)
+	} else if p < 1 || p > 65535 {
+		v.add(port, path+".port", "port %d out of range 1-65535", p)
+	}
+
+	// every mate is a separate broker, so a host must not appear twice even
+	// on different ports
+	if hostOK {
+		key := strings.ToLower(strings.TrimSuffix(host.Value, "."))
+		if first, dup := v.hosts[key]; dup {
+			v.add(host, path+".host", "duplicate host %s (already used by %s on line %d)", key, first.path, first.line)
+		} else {
+			v.hosts[key] = endpointRef{path: path, line: host.Line}
+		}
+	}
+}
+
+// mapping checks that n is a mapping, reports unknown and duplicated keys,
+// and returns the value nodes indexed by key. It returns nil if n is not a
+// mapping.
+func (v *topologyValidator) mapping(n *yaml.Node, path string, known ...string) map[string]*yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		v.add(n, path, "expected a mapping with keys %s", strings.Join(known, ", "))
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(known))
+	for _, k := range known {
+		allowed[k] = true
+	}
+
+	fields := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		childPath := key.Value
+		if path != "" {
+			childPath = path + "." + key.Value
+		}
+		if !allowed[key.Value] {
+			v.add(key, childPath, "unknown key (expected one of %s)", strings.Join(known, ", "))
+			continue
+		}
+		if _, dup := fields[key.Value]; dup {
+			v.add(key, childPath, "duplicate key")
+			continue
+		}
+		fields[key.Value] = value
+	}
+	return fields
+}
+
+// keyNode returns the key node for name in mapping n.
+func keyNode(n *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == name {
+			return n.Content[i]
+		}
+	}
+	return n
+}
+
+// validHost accepts IP literals and RFC 1123 host names.
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	host = strings.TrimSuffix(host, ".")
+	if len(host) == 0 || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package activities
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseKitsTopology_Valid(t *testing.T) {
+	file, err := ParseKitsTopology([]byte(`
+kits:
+  kit-a:
+    dc1:
+      mate1: {host: solace-a1.local, port: 8080}
+      mate2: {host: solace-a2.local, port: 8080}
+    dc2:
+      mate1: {host: 10.0.0.1, port: 8080}
+      mate2: {host: 10.0.0.2, port: 8080}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := file.Kits["kit-a"].DC2.Mate2.Host; got != "10.0.0.2" {
+		t.Fatalf("expected 10.0.0.2, got %s", got)
+	}
+}
+
+func TestParseKitsTopology_ReportsAllProblems(t *testing.T) {
+	_, err := ParseKitsTopology([]byte(`kits:
+  kit-a:
+    dc1:
+      mate1: {host: solace-a1.local, port: 0}
+      mate2: {host: "", port: 8080}
+    dc2:
+      mate1: {host: solace-a3.local, port: 8080, prot: 1}
+      mate2: {host: bad_-.-host, port: 8080}
+  kit-b:
+    dc1:
+      mate1: {host: solace-b1.local, port: 70000}
+`))
+
+	var verr *TopologyValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected TopologyValidationError, got %v", err)
+	}
+
+	want := []struct {
+		line int
+		text string
+	}{
+		{4, "out of range"},
+		{5, "host must not be empty"},
+		{7, "unknown key"},
+		{8, "valid DNS name"},
+		{9, `missing required key "dc2"`},
+		{10, `missing required key "mate2"`},
+		{11, "out of range"},
+	}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d: %v", len(want), len(verr.Problems), verr)
+	}
+	for i, w := range want {
+		p := verr.Problems[i]
+		if p.Line != w.line || !strings.Contains(p.Message, w.text) {
+			t.Errorf("problem %d: expected line %d containing %q, got %s", i, w.line, w.text, p)
+		}
+	}
+}
+
+func TestParseKitsTopology_DuplicateHost(t *testing.T) {
+	_, err := ParseKitsTopology([]byte(`kits:
+  kit-a:
+    dc1:
+      mate1: {host: solace.local, port: 8080}
+      mate2: {host: SOLACE.local, port: 8080}
+    dc2:
+      mate1: {host: solace-b1.local, port: 8081}
+      mate2: {host: solace.local., port: 8082}
+`))
+
+	var verr *TopologyValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("expected two duplicate problems, got %v", err)
+	}
+	for i, line := range []int{5, 8} {
+		p := verr.Problems[i]
+		if !strings.Contains(p.Message, "duplicate host solace.local") || p.Line != line {
+			t.Errorf("problem %d: expected a duplicate host on line %d, got %s", i, line, p)
+		}
+	}
+}
+
+func TestParseKitsTopology_VPNAddresses(t *testing.T) {
+	file, err := ParseKitsTopology([]byte(`kits:
+  kit-a:
+    dc1:
+      mate1: {host: solace-a1.local, port: 8080}
+      mate2: {host: solace-a2.local, port: 8080}
+      vpnAddresses: [10.10.0.0/16, 192.168.1.10]
+    dc2:
+      mate1: {host: solace-b1.local, port: 8080}
+      mate2: {host: solace-b2.local, port: 8080}
+      vpnAddresses: [10.20.0.0/16]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := file.Kits["kit-a"].DC1.VPNAddresses; len(got) != 2 || got[1] != "192.168.1.10" {
+		t.Fatalf("unexpected dc1 addresses %v", got)
+	}
+
+	_, err = ParseKitsTopology([]byte(`kits:
+  kit-a:
+    dc1:
+      mate1: {host: solace-a1.local, port: 8080}
+      mate2: {host: solace-a2.local, port: 8080}
+      vpnAddresses: [10.10.0.0/16, not-an-ip]
+    dc2:
+      mate1: {host: solace-b1.local, port: 8080}
+      mate2: {host: solace-b2.local, port: 8080}
+      vpnAddresses: [10.10.5.1]
+`))
+	var verr *TopologyValidationError
+	if !errors.As(err, &verr) || len(verr.Problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", err)
+	}
+	if !strings.Contains(verr.Problems[0].Message, "not-an-ip") || !strings.Contains(verr.Problems[1].Message, "overlaps dc1 address 10.10.0.0/16") {
+		t.Errorf("unexpected problems %v", verr)
+	}
+}
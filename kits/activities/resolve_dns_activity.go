@@ -4,45 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/netip"
 	"os"
-	"strings"
 )
 
 var readHostsFile = os.ReadFile
 var dnsLookup = net.LookupIP
 
+// ResolveDNSActivity returns the first IPv4 address of dnsName, resolved
+// with the resolver set by SetDNSResolver. Watchers compare record sets now,
+// it is kept for the watchers started before.
 func ResolveDNSActivity(ctx context.Context, dnsName string) (string, error) {
-	// todo: this is a hack just for quick test....
-	// 1. Try /etc/hosts first
-	hosts, err := os.ReadFile("/etc/hosts")
-	if err == nil {
-		lines := strings.Split(string(hosts), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
-			}
-			fields := strings.Fields(line)
-			if len(fields) < 2 {
-				continue
-			}
-			ip := fields[0]
-			for _, name := range fields[1:] {
-				if name == dnsName {
-					return ip, nil
-				}
-			}
-		}
-	}
-
-	// 2. Fallback to normal DNS lookup
-	ips, err := net.LookupIP(dnsName)
-	if err != nil || len(ips) == 0 {
+	set, err := currentDNSResolver().Resolve(ctx, dnsName)
+	if err != nil {
 		return "", err
 	}
-	for _, ip := range ips {
-		if v4 := ip.To4(); v4 != nil {
-			return v4.String(), nil
+	for _, a := range set.Addresses {
+		if addr, err := netip.ParseAddr(a); err == nil && addr.Is4() {
+			return a, nil
 		}
 	}
 	return "", fmt.Errorf("no IPv4 found for %s", dnsName)
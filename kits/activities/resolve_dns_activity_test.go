@@ -35,14 +35,14 @@ func TestResolveDNSActivity_Google(t *testing.T) {
 		return nil, fmt.Errorf("not found")
 	}
 
-	//ip, err := ResolveDNSActivity(context.Background(), "google.com")
-	//if err != nil {
-	//	t.Fatalf("unexpected error: %v", err)
-	//}
-
-	//if ip != "8.8.8.8" {
-	//	t.Fatalf("expected 8.8.8.8, got %s", ip)
-	//}
+	ip, err := ResolveDNSActivity(context.Background(), "google.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ip != "8.8.8.8" {
+		t.Fatalf("expected 8.8.8.8, got %s", ip)
+	}
 
 	ip2, err2 := ResolveDNSActivity(context.Background(), "test-vpn-1-solace-a.local")
 	if err2 != nil {
@@ -0,0 +1,152 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"kits-worker/kits/models"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// defaultQuorumResolver names the resolver of SetDNSResolver when no quorum
+// resolvers are configured.
+const defaultQuorumResolver = "default"
+
+var (
+	dnsQuorumMu        sync.RWMutex
+	dnsQuorumResolvers map[string]DNSResolver
+)
+
+// SetDNSQuorumResolvers sets the named resolvers asked by the DNS quorum,
+// ie the resolvers of each DC. Nil falls back to the resolver set by
+// SetDNSResolver alone. It returns the previous resolvers.
+func SetDNSQuorumResolvers(resolvers map[string]DNSResolver) map[string]DNSResolver {
+	dnsQuorumMu.Lock()
+	defer dnsQuorumMu.Unlock()
+	prev := dnsQuorumResolvers
+	dnsQuorumResolvers = resolvers
+	return prev
+}
+
+func currentDNSQuorumResolvers() map[string]DNSResolver {
+	dnsQuorumMu.RLock()
+	defer dnsQuorumMu.RUnlock()
+	if len(dnsQuorumResolvers) == 0 {
+		return map[string]DNSResolver{defaultQuorumResolver: currentDNSResolver()}
+	}
+	return dnsQuorumResolvers
+}
+
+// ParseDNSQuorumResolvers parses named resolver groups, ie
+// "dc1=10.10.0.53,10.10.0.54;dc2=10.20.0.53:5353". The servers of a group
+// are tried in order and count as one vote.
+func ParseDNSQuorumResolvers(spec string, types []models.DNSRecordType) (map[string]DNSResolver, error) {
+	resolvers := make(map[string]DNSResolver)
+	for _, group := range strings.Split(spec, ";") {
+		if strings.TrimSpace(group) == "" {
+			continue
+		}
+		name, servers, ok := strings.Cut(group, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("resolver group %q is not name=server,...", group)
+		}
+		if _, dup := resolvers[name]; dup {
+			return nil, fmt.Errorf("resolver %s is defined twice", name)
+		}
+		r, err := NewServerResolver(strings.Split(servers, ","), types)
+		if err != nil {
+			return nil, fmt.Errorf("resolver %s: %w", name, err)
+		}
+		resolvers[name] = r
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("no resolver in %q", spec)
+	}
+	return resolvers, nil
+}
+
+// ResolveDNSQuorumActivity asks every quorum resolver for dnsName and
+// returns the set at least quorum of them agree on; 0 requires a majority.
+// No agreement is not an error, the answer has no Agreed set. It fails when
+// no resolver answered.
+func ResolveDNSQuorumActivity(ctx context.Context, dnsName string, quorum int) (*models.DNSQuorumAnswer, error) {
+	resolvers := currentDNSQuorumResolvers()
+	names := make([]string, 0, len(resolvers))
+	for name := range resolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if quorum == 0 {
+		quorum = len(names)/2 + 1
+	}
+	if quorum < 0 || quorum > len(names) {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("quorum %d is not within the %d configured resolvers", quorum, len(names)),
+			"InvalidInput",
+			nil,
+		)
+	}
+
+	answer := &models.DNSQuorumAnswer{Name: dnsName, Quorum: quorum, Answers: make([]models.DNSResolverAnswer, len(names))}
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			a := models.DNSResolverAnswer{Resolver: name}
+			set, err := resolvers[name].Resolve(ctx, dnsName)
+			if err != nil {
+				a.Error = err.Error()
+			} else {
+				a.Set = set
+			}
+			answer.Answers[i] = a
+		}(i, name)
+	}
+	wg.Wait()
+
+	// vote on the address sets, a tie for the most votes is no agreement
+	votes := make(map[string]int)
+	var failed []string
+	for _, a := range answer.Answers {
+		if a.Set == nil {
+			failed = append(failed, a.Resolver+": "+a.Error)
+			continue
+		}
+		votes[a.Set.String()]++
+	}
+	if len(failed) == len(names) {
+		return nil, fmt.Errorf("no resolver answered for %s: %v", dnsName, failed)
+	}
+	best, tie := "", false
+	for set, n := range votes {
+		switch {
+		case n > answer.Votes:
+			best, answer.Votes, tie = set, n, false
+		case n == answer.Votes:
+			tie = true
+		}
+	}
+	if !tie && answer.Votes >= quorum {
+		for _, a := range answer.Answers {
+			if a.Set != nil && a.Set.String() == best {
+				answer.Agreed = a.Set
+				break
+			}
+		}
+	}
+
+	logger := activity.GetLogger(ctx)
+	if answer.Agreed == nil {
+		logger.Warn("DNS resolvers do not agree", "dnsName", dnsName, "quorum", quorum, "votes", answer.Votes, "failed", failed)
+	} else if len(failed) > 0 || answer.Votes < len(names) {
+		logger.Info("DNS quorum reached with dissent", "dnsName", dnsName, "quorum", quorum, "votes", answer.Votes, "failed", failed)
+	}
+	return answer, nil
+}
@@ -0,0 +1,147 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// staticResolver answers every name with the same addresses, or fails.
+type staticResolver string
+
+func (r staticResolver) Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error) {
+	if r == "" {
+		return nil, errors.New("timeout")
+	}
+	return &models.DNSRecordSet{Name: name, Addresses: strings.Split(string(r), ","), Source: string(r)}, nil
+}
+
+func TestResolveDNSQuorumActivity(t *testing.T) {
+	defer SetDNSQuorumResolvers(nil)
+
+	cases := []struct {
+		name      string
+		resolvers map[string]DNSResolver
+		quorum    int
+		agreed    string
+		votes     int
+		err       string
+	}{
+		{
+			name:      "unanimous",
+			resolvers: map[string]DNSResolver{"dc1": staticResolver("10.20.1.100"), "dc2": staticResolver("10.20.1.100")},
+			agreed:    "10.20.1.100", votes: 2,
+		},
+		{
+			name: "majority against a stale resolver",
+			resolvers: map[string]DNSResolver{
+				"dc1": staticResolver("10.10.1.100"), "dc2": staticResolver("10.20.1.100"), "gslb": staticResolver("10.20.1.100"),
+			},
+			agreed: "10.20.1.100", votes: 2,
+		},
+		{
+			name:      "split",
+			resolvers: map[string]DNSResolver{"dc1": staticResolver("10.10.1.100"), "dc2": staticResolver("10.20.1.100")},
+			quorum:    1, votes: 1,
+		},
+		{
+			name: "failed resolver counts against the quorum",
+			resolvers: map[string]DNSResolver{
+				"dc1": staticResolver(""), "dc2": staticResolver("10.20.1.100"), "gslb": staticResolver("10.20.1.100"),
+			},
+			quorum: 3, votes: 2,
+		},
+		{
+			name:      "no resolver answers",
+			resolvers: map[string]DNSResolver{"dc1": staticResolver(""), "dc2": staticResolver("")},
+			err:       "no resolver answered",
+		},
+		{
+			name:      "quorum above the resolvers",
+			resolvers: map[string]DNSResolver{"dc1": staticResolver("10.20.1.100")},
+			quorum:    2, err: "InvalidInput",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			SetDNSQuorumResolvers(c.resolvers)
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestActivityEnvironment()
+			env.RegisterActivity(ResolveDNSQuorumActivity)
+
+			val, err := env.ExecuteActivity(ResolveDNSQuorumActivity, "orders-solace-a.local", c.quorum)
+			if c.err != "" {
+				var appErr *temporal.ApplicationError
+				if err == nil || !strings.Contains(err.Error(), c.err) && !(errors.As(err, &appErr) && appErr.Type() == c.err) {
+					t.Fatalf("expected %s, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var answer models.DNSQuorumAnswer
+			if err := val.Get(&answer); err != nil {
+				t.Fatal(err)
+			}
+
+			agreed := ""
+			if answer.Agreed != nil {
+				agreed = answer.Agreed.String()
+			}
+			if agreed != c.agreed || answer.Votes != c.votes {
+				t.Errorf("expected %q with %d votes, got %q with %d", c.agreed, c.votes, agreed, answer.Votes)
+			}
+			if len(answer.Answers) != len(c.resolvers) || answer.Answers[0].Resolver != "dc1" {
+				t.Errorf("expected an answer per resolver in name order, got %+v", answer.Answers)
+			}
+		})
+	}
+}
+
+func TestResolveDNSQuorumActivity_DefaultResolver(t *testing.T) {
+	SetDNSResolver(staticResolver("10.10.1.100"))
+	defer SetDNSResolver(NewDefaultDNSResolver())
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(ResolveDNSQuorumActivity)
+
+	val, err := env.ExecuteActivity(ResolveDNSQuorumActivity, "orders-solace-a.local", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var answer models.DNSQuorumAnswer
+	if err := val.Get(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if answer.Quorum != 1 || answer.Agreed == nil || answer.Answers[0].Resolver != "default" {
+		t.Errorf("expected the single default resolver to decide, got %+v", answer)
+	}
+}
+
+func TestParseDNSQuorumResolvers(t *testing.T) {
+	resolvers, err := ParseDNSQuorumResolvers("dc1=10.10.0.53, 10.10.0.54; dc2=10.20.0.53:5353;", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc1, ok1 := resolvers["dc1"].(*ServerResolver)
+	dc2, ok2 := resolvers["dc2"].(*ServerResolver)
+	if len(resolvers) != 2 || !ok1 || !ok2 {
+		t.Fatalf("unexpected resolvers %v", resolvers)
+	}
+	if strings.Join(dc1.Servers, " ") != "10.10.0.53:53 10.10.0.54:53" || dc2.Servers[0] != "10.20.0.53:5353" {
+		t.Errorf("unexpected servers %v %v", dc1.Servers, dc2.Servers)
+	}
+
+	for _, spec := range []string{"", "10.10.0.53", "dc1=", "dc1=10.10.0.53;dc1=10.10.0.54"} {
+		if _, err := ParseDNSQuorumResolvers(spec, nil); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
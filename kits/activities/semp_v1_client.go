@@ -0,0 +1,347 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net/http"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// SEMPv1Client sends SEMP v1 XML RPCs (POST /SEMP) to a single broker.
+type SEMPv1Client struct {
+	URL        string
+	Auth       models.BasicAuth
+	HTTPClient *http.Client
+}
+
+func NewSEMPv1Client(ep models.Endpoint, auth models.BasicAuth) *SEMPv1Client {
+	return &SEMPv1Client{
+		URL:  fmt.Sprintf("%s://%s:%d/SEMP", config.CURRENT_HTTP_SCHEME, ep.Host, ep.Port),
+		Auth: auth,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// SEMPv1Error is returned when the broker answers with an execute-result
+// other than "ok" or with a parse-error.
+type SEMPv1Error struct {
+	Code       string
+	Reason     string
+	ReasonCode string
+	ParseError string
+}
+
+func (e *SEMPv1Error) Error() string {
+	if e.ParseError != "" {
+		return fmt.Sprintf("SEMP v1 parse error: %s", e.ParseError)
+	}
+	return fmt.Sprintf("SEMP v1 execute-result %s: %s (reason-code %s)", e.Code, e.Reason, e.ReasonCode)
+}
+
+// SEMPv1DecodeError is returned when the reply is not valid SEMP v1 XML.
+type SEMPv1DecodeError struct {
+	Err error
+}
+
+func (e *SEMPv1DecodeError) Error() string { return "failed to decode SEMP XML: " + e.Err.Error() }
+func (e *SEMPv1DecodeError) Unwrap() error { return e.Err }
+
+// sempV1ActivityError maps a client error to the activity error: broker
+// rejections and undecodable replies are not retried, transport errors are.
+func sempV1ActivityError(err error) error {
+	var execErr *SEMPv1Error
+	if errors.As(err, &execErr) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), "SEMPError", err)
+	}
+	var decodeErr *SEMPv1DecodeError
+	if errors.As(err, &decodeErr) {
+		return temporal.NewNonRetryableApplicationError("failed to decode SEMP XML", "DecodeError", err)
+	}
+	return err
+}
+
+type sempV1ExecuteResult struct {
+	Code       string `xml:"code,attr"`
+	Reason     string `xml:"reason,attr"`
+	ReasonCode string `xml:"reason-code,attr"`
+}
+
+// sempV1Envelope is the rpc-reply wrapper; RPC holds the typed show reply.
+type sempV1Envelope struct {
+	XMLName       xml.Name             `xml:"rpc-reply"`
+	SEMPVersion   string               `xml:"semp-version,attr"`
+	RPC           sempV1RPCReply       `xml:"rpc"`
+	ParseError    string               `xml:"parse-error"`
+	ExecuteResult *sempV1ExecuteResult `xml:"execute-result"`
+}
+
+type sempV1RPCReply struct {
+	Show struct {
+		Version      *VersionReply      `xml:"version"`
+		Redundancy   *RedundancyReply   `xml:"redundancy"`
+		ConfigSync   *ConfigSyncReply   `xml:"config-sync"`
+		MessageSpool *MessageSpoolReply `xml:"message-spool"`
+		Replication  *ReplicationReply  `xml:"replication"`
+		MessageVPN   *MessageVPNReply   `xml:"message-vpn"`
+	} `xml:"show"`
+}
+
+// Requests
+
+type sempV1Request struct {
+	XMLName xml.Name        `xml:"rpc"`
+	Show    sempV1ShowQuery `xml:"show"`
+}
+
+type sempV1ShowQuery struct {
+	Version      *struct{}          `xml:"version"`
+	Redundancy   *struct{}          `xml:"redundancy"`
+	ConfigSync   *configSyncRequest `xml:"config-sync"`
+	MessageSpool *struct{}          `xml:"message-spool"`
+	Replication  *struct{}          `xml:"replication"`
+	MessageVPN   *messageVPNRequest `xml:"message-vpn"`
+}
+
+type configSyncRequest struct {
+	Database *configSyncDatabaseRequest `xml:"database"`
+}
+
+type configSyncDatabaseRequest struct {
+	MessageVPN *messageVPNRequest `xml:"message-vpn"`
+}
+
+type messageVPNRequest struct {
+	VPNName     string    `xml:"vpn-name"`
+	Replication *struct{} `xml:"replication"`
+}
+
+// Replies
+
+type VersionReply struct {
+	Description string `xml:"description"`
+	CurrentLoad string `xml:"current-load"`
+}
+
+type RedundancyReply struct {
+	ConfigStatus        string `xml:"config-status"`
+	RedundancyStatus    string `xml:"redundancy-status"`
+	OperatingMode       string `xml:"operating-mode"`
+	SwitchoverMechanism string `xml:"switchover-mechanism"`
+	AutoRevert          string `xml:"auto-revert"`
+	RedundancyMode      string `xml:"redundancy-mode"`
+	ActiveStandbyRole   string `xml:"active-standby-role"`
+	MateRouterName      string `xml:"mate-router-name"`
+	ADBLinkUp           bool   `xml:"adb-link-up"`
+	ADBHelloUp          bool   `xml:"adb-hello-up"`
+	VirtualRouters      struct {
+		Primary *VirtualRouterStatus `xml:"primary"`
+		Backup  *VirtualRouterStatus `xml:"backup"`
+	} `xml:"virtual-routers"`
+}
+
+type VirtualRouterStatus struct {
+	Status struct {
+		Activity string `xml:"activity"`
+	} `xml:"status"`
+}
+
+type ConfigSyncReply struct {
+	Status struct {
+		AdminStatus string `xml:"admin-status"`
+		OperStatus  string `xml:"oper-status"`
+	} `xml:"status"`
+	Database struct {
+		Tables []ConfigSyncTable `xml:"local>tables>table"`
+	} `xml:"database"`
+}
+
+type ConfigSyncTable struct {
+	Type      string `xml:"type"`
+	Name      string `xml:"name"`
+	SyncState string `xml:"sync-state"`
+	Ownership string `xml:"ownership"`
+}
+
+type MessageSpoolReply struct {
+	Info struct {
+		ConfigStatus                  string  `xml:"config-status"`
+		OperationalStatus             string  `xml:"operational-status"`
+		CurrentPersistUsage           float64 `xml:"current-persist-usage"`
+		MaxDiskUsage                  float64 `xml:"max-disk-usage"`
+		TotalMessagesCurrentlySpooled int64   `xml:"total-messages-currently-spooled"`
+	} `xml:"message-spool-info"`
+}
+
+type ReplicationReply struct {
+	Info struct {
+		AdminStatus string `xml:"admin-status"`
+		OperStatus  string `xml:"oper-status"`
+		MateAddress string `xml:"mate-address"`
+	} `xml:"replication-info"`
+}
+
+type MessageVPNReply struct {
+	VPNs []struct {
+		Name        string `xml:"name"`
+		Enabled     bool   `xml:"enabled"`
+		Operational bool   `xml:"operational"`
+		LocalStatus string `xml:"local-status"`
+		Connections int    `xml:"connections"`
+	} `xml:"vpn"`
+	Replication struct {
+		VPNs []MessageVPNReplication `xml:"message-vpns>message-vpn"`
+	} `xml:"replication"`
+}
+
+type MessageVPNReplication struct {
+	VPNName     string `xml:"vpn-name"`
+	AdminState  string `xml:"admin-state"`
+	ConfigState string `xml:"config-state"`
+	LocalStatus string `xml:"local-status"`
+	BridgeState string `xml:"bridge-state"`
+	QueueState  string `xml:"queue-state"`
+}
+
+// do posts the request and decodes the envelope, checking execute-result.
+func (c *SEMPv1Client) do(ctx context.Context, query sempV1ShowQuery) (*sempV1Envelope, error) {
+	body, err := xml.Marshal(sempV1Request{Show: query})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	applyBasicAuth(req, c.Auth)
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		// DNS, timeout, connection refused → retryable
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("SEMP v1 request to %s failed: %s", c.URL, resp.Status)
+	}
+
+	var env sempV1Envelope
+	if err := xml.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return nil, &SEMPv1DecodeError{Err: err}
+	}
+
+	if env.ParseError != "" {
+		return nil, &SEMPv1Error{ParseError: env.ParseError}
+	}
+	if env.ExecuteResult == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no execute-result")}
+	}
+	if env.ExecuteResult.Code != "ok" {
+		return nil, &SEMPv1Error{
+			Code:       env.ExecuteResult.Code,
+			Reason:     env.ExecuteResult.Reason,
+			ReasonCode: env.ExecuteResult.ReasonCode,
+		}
+	}
+	return &env, nil
+}
+
+func (c *SEMPv1Client) ShowVersion(ctx context.Context) (*VersionReply, error) {
+	env, err := c.do(ctx, sempV1ShowQuery{Version: &struct{}{}})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.Version == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no version section")}
+	}
+	return env.RPC.Show.Version, nil
+}
+
+func (c *SEMPv1Client) ShowRedundancy(ctx context.Context) (*RedundancyReply, error) {
+	env, err := c.do(ctx, sempV1ShowQuery{Redundancy: &struct{}{}})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.Redundancy == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no redundancy section")}
+	}
+	return env.RPC.Show.Redundancy, nil
+}
+
+func (c *SEMPv1Client) ShowConfigSync(ctx context.Context) (*ConfigSyncReply, error) {
+	env, err := c.do(ctx, sempV1ShowQuery{ConfigSync: &configSyncRequest{}})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.ConfigSync == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no config-sync section")}
+	}
+	return env.RPC.Show.ConfigSync, nil
+}
+
+// ShowConfigSyncDatabase runs "show config-sync database message-vpn <vpn>"
+// and returns the local config-sync tables of the VPN.
+func (c *SEMPv1Client) ShowConfigSyncDatabase(ctx context.Context, vpn string) ([]ConfigSyncTable, error) {
+	env, err := c.do(ctx, sempV1ShowQuery{ConfigSync: &configSyncRequest{
+		Database: &configSyncDatabaseRequest{MessageVPN: &messageVPNRequest{VPNName: vpn}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.ConfigSync == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no config-sync section")}
+	}
+	return env.RPC.Show.ConfigSync.Database.Tables, nil
+}
+
+func (c *SEMPv1Client) ShowMessageSpool(ctx context.Context) (*MessageSpoolReply, error) {
+	env, err := c.do(ctx, sempV1ShowQuery{MessageSpool: &struct{}{}})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.MessageSpool == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no message-spool section")}
+	}
+	return env.RPC.Show.MessageSpool, nil
+}
+
+func (c *SEMPv1Client) ShowReplication(ctx context.Context) (*ReplicationReply, error) {
+	env, err := c.do(ctx, sempV1ShowQuery{Replication: &struct{}{}})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.Replication == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no replication section")}
+	}
+	return env.RPC.Show.Replication, nil
+}
+
+// ShowMessageVPN runs "show message-vpn <vpn>"; with replication set it runs
+// "show message-vpn <vpn> replication" and fills MessageVPNReply.Replication.
+func (c *SEMPv1Client) ShowMessageVPN(ctx context.Context, vpn string, replication bool) (*MessageVPNReply, error) {
+	q := &messageVPNRequest{VPNName: vpn}
+	if replication {
+		q.Replication = &struct{}{}
+	}
+	env, err := c.do(ctx, sempV1ShowQuery{MessageVPN: q})
+	if err != nil {
+		return nil, err
+	}
+	if env.RPC.Show.MessageVPN == nil {
+		return nil, &SEMPv1DecodeError{Err: fmt.Errorf("reply has no message-vpn section")}
+	}
+	return env.RPC.Show.MessageVPN, nil
+}
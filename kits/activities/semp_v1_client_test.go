@@ -0,0 +1,149 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sempV1FixtureServer answers every SEMP v1 RPC with the given captured reply
+// and records the last request body.
+func sempV1FixtureServer(t *testing.T, fixture string, lastRequest *string) *SEMPv1Client {
+	t.Helper()
+	reply, err := os.ReadFile(filepath.Join("testdata", "semp_v1", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/SEMP" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		*lastRequest = string(body)
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(reply)
+	}))
+	t.Cleanup(srv.Close)
+	return &SEMPv1Client{URL: srv.URL + "/SEMP", HTTPClient: srv.Client()}
+}
+
+func TestSEMPv1ClientShowReplies(t *testing.T) {
+	ctx := context.Background()
+	var request string
+
+	version, err := sempV1FixtureServer(t, "show_version.xml", &request).ShowVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != `<rpc><show><version></version></show></rpc>` {
+		t.Errorf("unexpected request %s", request)
+	}
+	if version.CurrentLoad != "soltr_10.4.1.112" {
+		t.Errorf("unexpected current-load %q", version.CurrentLoad)
+	}
+
+	redundancy, err := sempV1FixtureServer(t, "show_redundancy.xml", &request).ShowRedundancy(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != `<rpc><show><redundancy></redundancy></show></rpc>` {
+		t.Errorf("unexpected request %s", request)
+	}
+	if redundancy.RedundancyMode != "Active/Standby" || redundancy.ActiveStandbyRole != "Primary" || !redundancy.ADBLinkUp {
+		t.Errorf("unexpected redundancy %+v", redundancy)
+	}
+	if redundancy.VirtualRouters.Primary.Status.Activity != "Local Active" ||
+		redundancy.VirtualRouters.Backup.Status.Activity != "Shutdown" {
+		t.Errorf("unexpected virtual routers %+v", redundancy.VirtualRouters)
+	}
+
+	configSync, err := sempV1FixtureServer(t, "show_config_sync.xml", &request).ShowConfigSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configSync.Status.AdminStatus != "Enabled" || configSync.Status.OperStatus != "Up" {
+		t.Errorf("unexpected config-sync %+v", configSync.Status)
+	}
+
+	tables, err := sempV1FixtureServer(t, "show_config_sync_database_vpn.xml", &request).ShowConfigSyncDatabase(ctx, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != `<rpc><show><config-sync><database><message-vpn><vpn-name>orders</vpn-name></message-vpn></database></config-sync></show></rpc>` {
+		t.Errorf("unexpected request %s", request)
+	}
+	if len(tables) != 1 || tables[0].Name != "orders" || tables[0].SyncState != "In-Sync" {
+		t.Errorf("unexpected config-sync tables %+v", tables)
+	}
+
+	spool, err := sempV1FixtureServer(t, "show_message_spool.xml", &request).ShowMessageSpool(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spool.Info.OperationalStatus != "AD-Active" || spool.Info.TotalMessagesCurrentlySpooled != 4211 {
+		t.Errorf("unexpected message spool %+v", spool.Info)
+	}
+
+	replication, err := sempV1FixtureServer(t, "show_replication.xml", &request).ShowReplication(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replication.Info.OperStatus != "Up" {
+		t.Errorf("unexpected replication %+v", replication.Info)
+	}
+
+	vpn, err := sempV1FixtureServer(t, "show_message_vpn.xml", &request).ShowMessageVPN(ctx, "orders", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != `<rpc><show><message-vpn><vpn-name>orders</vpn-name></message-vpn></show></rpc>` {
+		t.Errorf("unexpected request %s", request)
+	}
+	if len(vpn.VPNs) != 1 || vpn.VPNs[0].Name != "orders" || !vpn.VPNs[0].Operational || vpn.VPNs[0].Connections != 37 {
+		t.Errorf("unexpected message-vpn %+v", vpn.VPNs)
+	}
+
+	vpnRepl, err := sempV1FixtureServer(t, "show_message_vpn_replication.xml", &request).ShowMessageVPN(ctx, "orders", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != `<rpc><show><message-vpn><vpn-name>orders</vpn-name><replication></replication></message-vpn></show></rpc>` {
+		t.Errorf("unexpected request %s", request)
+	}
+	if len(vpnRepl.Replication.VPNs) != 1 || vpnRepl.Replication.VPNs[0].ConfigState != "Active" ||
+		vpnRepl.Replication.VPNs[0].BridgeState != "Up" {
+		t.Errorf("unexpected message-vpn replication %+v", vpnRepl.Replication.VPNs)
+	}
+}
+
+func TestSEMPv1ClientExecuteResult(t *testing.T) {
+	ctx := context.Background()
+	var request string
+
+	_, err := sempV1FixtureServer(t, "execute_fail.xml", &request).ShowMessageVPN(ctx, "missing", false)
+	var execErr *SEMPv1Error
+	if !errors.As(err, &execErr) {
+		t.Fatalf("expected SEMPv1Error, got %v", err)
+	}
+	if execErr.Code != "fail" || execErr.Reason != "Unknown message VPN" || execErr.ReasonCode != "6" {
+		t.Errorf("unexpected execute-result %+v", execErr)
+	}
+
+	_, err = sempV1FixtureServer(t, "parse_error.xml", &request).ShowRedundancy(ctx)
+	if !errors.As(err, &execErr) || execErr.ParseError == "" {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+
+	// a reply for another command must not be mistaken for an empty section
+	_, err = sempV1FixtureServer(t, "show_config_sync.xml", &request).ShowRedundancy(ctx)
+	var decodeErr *SEMPv1DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected SEMPv1DecodeError, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package activities
+
+import (
+	"context"
+	"kits-worker/kits/models"
+	"net/netip"
+	"slices"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// ResolveSiteAddressesActivity returns the VPN addresses of both DCs of a
+// kit, by DC: the vpnAddresses of kits.yaml, or else the addresses the mate
+// hosts resolve to. Addresses resolved for both DCs are dropped, they do not
+// tell the DCs apart; a DC left without addresses is omitted.
+func ResolveSiteAddressesActivity(ctx context.Context, kit models.Kit) (map[string][]string, error) {
+	logger := activity.GetLogger(ctx)
+
+	sites := make(map[string][]string)
+	resolved := make(map[string][]netip.Addr)
+	for _, site := range []struct {
+		name string
+		dc   models.DC
+	}{{"dc1", kit.DC1}, {"dc2", kit.DC2}} {
+		if len(site.dc.VPNAddresses) > 0 {
+			sites[site.name] = slices.Clone(site.dc.VPNAddresses)
+			continue
+		}
+		for _, ep := range []models.Endpoint{site.dc.Mate1, site.dc.Mate2} {
+			if addr, err := netip.ParseAddr(ep.Host); err == nil {
+				resolved[site.name] = append(resolved[site.name], addr)
+				continue
+			}
+			set, err := currentDNSResolver().Resolve(ctx, ep.Host)
+			if err != nil {
+				logger.Warn("Mate host does not resolve", "dc", site.name, "host", ep.Host, "error", err)
+				continue
+			}
+			for _, a := range set.Addresses {
+				if addr, err := netip.ParseAddr(a); err == nil {
+					resolved[site.name] = append(resolved[site.name], addr)
+				}
+			}
+		}
+	}
+
+	for dc, addrs := range resolved {
+		other := "dc1"
+		if dc == "dc1" {
+			other = "dc2"
+		}
+		var own []netip.Addr
+		for _, a := range addrs {
+			if slices.Contains(resolved[other], a) {
+				logger.Warn("Mate address shared by both DCs is ignored", "address", a.String())
+				continue
+			}
+			own = append(own, a)
+		}
+		if len(own) > 0 {
+			sites[dc] = sortAddrs(own)
+		}
+	}
+	return sites, nil
+}
@@ -0,0 +1,76 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+// staticHosts resolves the names it holds to comma separated addresses.
+type staticHosts map[string]string
+
+func (h staticHosts) Resolve(ctx context.Context, name string) (*models.DNSRecordSet, error) {
+	addrs, ok := h[name]
+	if !ok {
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+	}
+	return &models.DNSRecordSet{Name: name, Addresses: strings.Split(addrs, ","), Source: "static"}, nil
+}
+
+func TestResolveSiteAddressesActivity(t *testing.T) {
+	SetDNSResolver(ChainResolver{staticHosts{
+		"solace-dc2-a.local": "10.20.0.11",
+		"solace-dc2-b.local": "10.20.0.12,10.20.0.13",
+		"shared.local":       "10.99.0.1",
+	}})
+	defer SetDNSResolver(NewDefaultDNSResolver())
+
+	cases := []struct {
+		name     string
+		kit      models.Kit
+		dc1, dc2 string
+	}{
+		{
+			name: "configured addresses win",
+			kit: models.Kit{
+				DC1: models.DC{Mate1: models.Endpoint{Host: "10.10.0.11"}, VPNAddresses: []string{"10.10.100.0/24"}},
+				DC2: models.DC{Mate1: models.Endpoint{Host: "solace-dc2-a.local"}, Mate2: models.Endpoint{Host: "solace-dc2-b.local"}},
+			},
+			dc1: "10.10.100.0/24", dc2: "10.20.0.11 10.20.0.12 10.20.0.13",
+		},
+		{
+			name: "shared and unresolved hosts are dropped",
+			kit: models.Kit{
+				DC1: models.DC{Mate1: models.Endpoint{Host: "shared.local"}, Mate2: models.Endpoint{Host: "unknown.local"}},
+				DC2: models.DC{Mate1: models.Endpoint{Host: "shared.local"}, Mate2: models.Endpoint{Host: "10.20.0.11"}},
+			},
+			dc1: "", dc2: "10.20.0.11",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestActivityEnvironment()
+			env.RegisterActivity(ResolveSiteAddressesActivity)
+
+			val, err := env.ExecuteActivity(ResolveSiteAddressesActivity, c.kit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sites map[string][]string
+			if err := val.Get(&sites); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(sites["dc1"], " "); got != c.dc1 {
+				t.Errorf("dc1: expected %q, got %q", c.dc1, got)
+			}
+			if got := strings.Join(sites["dc2"], " "); got != c.dc2 {
+				t.Errorf("dc2: expected %q, got %q", c.dc2, got)
+			}
+		})
+	}
+}
@@ -25,8 +25,10 @@ func GetRoleVPNsActivity(
 	auth models.BasicAuth,
 ) ([]string, error) {
 
+	// '#' is escaped, a bare one would start the URL fragment and drop the
+	// condition skipping system VPNs such as #config-sync
 	url := fmt.Sprintf(
-		"%s://%s:%d/SEMP/v2/monitor/msgVpns?where=enabled==true,replicationEnabled==true,replicationRole==%s,msgVpnName!=#*",
+		"%s://%s:%d/SEMP/v2/monitor/msgVpns?where=enabled==true,replicationEnabled==true,replicationRole==%s,msgVpnName!=%%23*",
 		config.CURRENT_HTTP_SCHEME,
 		host,
 		port,
@@ -0,0 +1,49 @@
+package activities
+
+import (
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestGetRoleVPNsActivity(t *testing.T) {
+	var where string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/SEMP/v2/monitor/msgVpns" {
+			http.NotFound(w, r)
+			return
+		}
+		where = r.URL.Query().Get("where")
+		w.Write([]byte(`{"data":[{"msgVpnName":"billing"},{"msgVpnName":"orders"}],"meta":{}}`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(GetRoleVPNsActivity)
+
+	val, err := env.ExecuteActivity(GetRoleVPNsActivity, u.Hostname(), port, "standby", models.BasicAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vpns []string
+	if err := val.Get(&vpns); err != nil {
+		t.Fatal(err)
+	}
+	if len(vpns) != 2 || vpns[0] != "billing" || vpns[1] != "orders" {
+		t.Errorf("expected [billing orders], got %v", vpns)
+	}
+
+	// the system VPN condition must reach the broker, not end up in a fragment
+	want := "enabled==true,replicationEnabled==true,replicationRole==standby,msgVpnName!=#*"
+	if where != want {
+		t.Errorf("expected where=%s, got %q", want, where)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"kits-worker/kits/config"
 	"kits-worker/kits/models"
 	"net/http"
@@ -11,6 +12,12 @@ import (
 	"go.temporal.io/sdk/activity"
 )
 
+type vpnRoleResponse struct {
+	Data struct {
+		ReplicationRole string `json:"replicationRole"`
+	} `json:"data"`
+}
+
 func SetVPNReplicationRoleActivity(
 	ctx context.Context,
 	host string,
@@ -28,6 +35,20 @@ func SetVPNReplicationRoleActivity(
 
 	body := []byte(fmt.Sprintf(`{"replicationRole":"%s"}`, role))
 
+	rec := models.AuditRecord{
+		Action:      "SetVPNReplicationRole",
+		Host:        host,
+		Port:        port,
+		VPN:         vpn,
+		SEMPUser:    auth.Username,
+		Method:      http.MethodPatch,
+		URL:         url,
+		RequestBody: string(body),
+	}
+
+	// roles before and after are informative for the audit trail only
+	rec.RoleBefore, _ = vpnReplicationRole(ctx, host, port, vpn, auth)
+
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPatch,
@@ -43,16 +64,46 @@ func SetVPNReplicationRoleActivity(
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		rec.Error = err.Error()
+		recordAudit(ctx, rec)
 		return err
 	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
 	resp.Body.Close()
 
+	rec.StatusCode = resp.StatusCode
+	rec.ResponseBody = string(respBody)
+	// a rejected PATCH leaves the role unchanged and fails the step
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("setting replicationRole %s on vpn %s failed: %s", role, vpn, resp.Status)
+		rec.Error = err.Error()
+	}
+	rec.RoleAfter, _ = vpnReplicationRole(ctx, host, port, vpn, auth)
+	recordAudit(ctx, rec)
+	if err != nil {
+		return err
+	}
+
 	activity.GetLogger(ctx).Info(
 		"vpn role updated",
 		"vpn", vpn,
 		"host", host,
 		"role", role,
+		"roleBefore", rec.RoleBefore,
 	)
 
 	return nil
 }
+
+func vpnReplicationRole(ctx context.Context, host string, port int, vpn string, auth models.BasicAuth) (string, error) {
+	url := fmt.Sprintf(
+		"%s://%s:%d/SEMP/v2/monitor/msgVpns/%s?select=replicationRole",
+		config.CURRENT_HTTP_SCHEME,
+		host, port, vpn,
+	)
+	var r vpnRoleResponse
+	if err := sempV2Get(ctx, url, auth, &r); err != nil {
+		return "", err
+	}
+	return r.Data.ReplicationRole, nil
+}
@@ -0,0 +1,55 @@
+package activities
+
+import (
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestSetVPNReplicationRoleActivity(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "accepted", status: http.StatusOK},
+		{name: "rejected", status: http.StatusBadRequest, wantErr: "setting replicationRole standby on vpn orders failed: 400 Bad Request"},
+		{name: "broker error", status: http.StatusInternalServerError, wantErr: "500 Internal Server Error"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/SEMP/v2/monitor/msgVpns/orders":
+					w.Write([]byte(`{"data":{"replicationRole":"active"}}`))
+				case r.Method == http.MethodPatch && r.URL.Path == "/SEMP/v2/config/msgVpns/orders":
+					w.WriteHeader(c.status)
+					w.Write([]byte(`{"meta":{"responseCode":` + strconv.Itoa(c.status) + `}}`))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			u, _ := url.Parse(srv.URL)
+			port, _ := strconv.Atoi(u.Port())
+
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestActivityEnvironment()
+			env.RegisterActivity(SetVPNReplicationRoleActivity)
+
+			_, err := env.ExecuteActivity(SetVPNReplicationRoleActivity, u.Hostname(), port, "orders", "standby", models.BasicAuth{})
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Fatal(err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Fatalf("expected error %q, got %v", c.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+type configPage struct {
+	Data json.RawMessage `json:"data"`
+	Meta struct {
+		Paging struct {
+			NextPageURI string `json:"nextPageUri"`
+		} `json:"paging"`
+	} `json:"meta"`
+}
+
+// snapshotCollections are the VPN collections captured in a snapshot, with
+// the attribute naming their objects.
+var snapshotCollections = []struct {
+	kind, path, key string
+}{
+	{models.ConfigKindQueue, "queues", "queueName"},
+	{models.ConfigKindClientProfile, "clientProfiles", "clientProfileName"},
+	{models.ConfigKindACLProfile, "aclProfiles", "aclProfileName"},
+}
+
+var (
+	snapshotDirMu sync.RWMutex
+	snapshotDir   = filepath.Join(os.TempDir(), config.SNAPSHOT_DEFAULT_DIR)
+)
+
+// SetSnapshotDir sets the shared directory VPN config snapshots are stored in
+// and returns the previous one.
+func SetSnapshotDir(dir string) string {
+	snapshotDirMu.Lock()
+	defer snapshotDirMu.Unlock()
+	prev := snapshotDir
+	snapshotDir = dir
+	return prev
+}
+
+func currentSnapshotDir() string {
+	snapshotDirMu.RLock()
+	defer snapshotDirMu.RUnlock()
+	return snapshotDir
+}
+
+// CaptureVPNConfigSnapshotActivity reads the SEMP v2 configuration of the VPN
+// (msgVpn with its replication settings, queues, client profiles and ACL
+// profiles) from the mate, stores it in the snapshot directory and returns a
+// reference to it. The content stays out of the workflow history, it can be
+// larger than a payload.
+func CaptureVPNConfigSnapshotActivity(
+	ctx context.Context,
+	vpn string,
+	mate *models.MateResult,
+	phase string,
+	auth models.BasicAuth,
+) (models.ConfigSnapshotRef, error) {
+
+	snap, err := captureVPNConfig(ctx, vpn, mate, phase, auth)
+	if err != nil {
+		return models.ConfigSnapshotRef{}, err
+	}
+
+	path, err := storeVPNConfigSnapshot(snap)
+	if err != nil {
+		return models.ConfigSnapshotRef{}, fmt.Errorf("storing vpn config snapshot: %w", err)
+	}
+
+	ref := models.ConfigSnapshotRef{
+		VPN:     vpn,
+		Host:    mate.Host,
+		Port:    mate.Port,
+		DC:      mate.DC,
+		Phase:   phase,
+		TakenAt: snap.TakenAt,
+		Path:    path,
+	}
+	for _, objs := range snap.Objects {
+		ref.Objects += len(objs)
+	}
+
+	activity.GetLogger(ctx).Info("vpn config snapshot stored", "vpn", vpn, "host", mate.Host, "phase", phase, "objects", ref.Objects, "path", path)
+	return ref, nil
+}
+
+// storeVPNConfigSnapshot writes the snapshot in the snapshot directory and
+// returns its path.
+func storeVPNConfigSnapshot(snap models.VPNConfigSnapshot) (string, error) {
+	dir := currentSnapshotDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf(
+		"%s_%s_%d_%s_%s.json",
+		snap.VPN, snap.Host, snap.Port, snap.Phase, snap.TakenAt.Format("20060102T150405.000Z"),
+	))
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func captureVPNConfig(
+	ctx context.Context,
+	vpn string,
+	mate *models.MateResult,
+	phase string,
+	auth models.BasicAuth,
+) (models.VPNConfigSnapshot, error) {
+
+	snap := models.VPNConfigSnapshot{
+		VPN:     vpn,
+		Host:    mate.Host,
+		Port:    mate.Port,
+		DC:      mate.DC,
+		Phase:   phase,
+		TakenAt: time.Now().UTC(),
+		Objects: make(map[string]map[string]map[string]interface{}),
+	}
+
+	base := fmt.Sprintf(
+		"%s://%s:%d/SEMP/v2/config/msgVpns/%s",
+		config.CURRENT_HTTP_SCHEME, mate.Host, mate.Port, url.PathEscape(vpn),
+	)
+
+	var page configPage
+	if err := sempV2Get(ctx, base, auth, &page); err != nil {
+		return snap, err
+	}
+	var msgVpn map[string]interface{}
+	if err := json.Unmarshal(page.Data, &msgVpn); err != nil {
+		return snap, err
+	}
+	snap.Objects[models.ConfigKindMsgVpn] = map[string]map[string]interface{}{vpn: msgVpn}
+
+	for _, c := range snapshotCollections {
+		objs := make(map[string]map[string]interface{})
+		next := base + "/" + c.path + "?count=100"
+		for next != "" {
+			var page configPage
+			if err := sempV2Get(ctx, next, auth, &page); err != nil {
+				return snap, err
+			}
+			var items []map[string]interface{}
+			if err := json.Unmarshal(page.Data, &items); err != nil {
+				return snap, err
+			}
+			for _, item := range items {
+				name, _ := item[c.key].(string)
+				objs[name] = item
+			}
+			next = page.Meta.Paging.NextPageURI
+		}
+		snap.Objects[c.kind] = objs
+	}
+	return snap, nil
+}
+
+// DiffVPNConfigSnapshotsActivity reads two snapshots of the same broker from
+// the snapshot directory and returns their differences.
+func DiffVPNConfigSnapshotsActivity(
+	ctx context.Context,
+	before models.ConfigSnapshotRef,
+	after models.ConfigSnapshotRef,
+) (models.ConfigDiff, error) {
+
+	b, err := loadVPNConfigSnapshot(before)
+	if err != nil {
+		return models.ConfigDiff{}, err
+	}
+	a, err := loadVPNConfigSnapshot(after)
+	if err != nil {
+		return models.ConfigDiff{}, err
+	}
+
+	diff := models.ConfigDiff{
+		VPN:     before.VPN,
+		Host:    before.Host,
+		DC:      before.DC,
+		Before:  before.Path,
+		After:   after.Path,
+		Changes: DiffVPNConfig(b, a),
+	}
+	activity.GetLogger(ctx).Info("vpn config diffed", "vpn", diff.VPN, "host", diff.Host, "changes", len(diff.Changes))
+	return diff, nil
+}
+
+func loadVPNConfigSnapshot(ref models.ConfigSnapshotRef) (models.VPNConfigSnapshot, error) {
+	var snap models.VPNConfigSnapshot
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return snap, err
+	}
+	return snap, json.Unmarshal(data, &snap)
+}
+
+// DiffVPNConfig compares two snapshots object by object and attribute by
+// attribute. Changes are sorted by kind, name and field.
+func DiffVPNConfig(before, after models.VPNConfigSnapshot) []models.ConfigChange {
+	changes := []models.ConfigChange{}
+
+	for _, kind := range unionKeys(before.Objects, after.Objects) {
+		b, a := before.Objects[kind], after.Objects[kind]
+		for _, name := range unionKeys(b, a) {
+			bo, inBefore := b[name]
+			ao, inAfter := a[name]
+			switch {
+			case !inAfter:
+				changes = append(changes, models.ConfigChange{Kind: kind, Name: name, Change: "removed"})
+			case !inBefore:
+				changes = append(changes, models.ConfigChange{Kind: kind, Name: name, Change: "added"})
+			default:
+				for _, field := range unionKeys(bo, ao) {
+					if reflect.DeepEqual(bo[field], ao[field]) {
+						continue
+					}
+					changes = append(changes, models.ConfigChange{
+						Kind:   kind,
+						Name:   name,
+						Change: "changed",
+						Field:  field,
+						Before: bo[field],
+						After:  ao[field],
+					})
+				}
+			}
+		}
+	}
+	return changes
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
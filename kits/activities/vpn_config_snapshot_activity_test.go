@@ -0,0 +1,121 @@
+package activities
+
+import (
+	"context"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestCaptureAndDiffVPNConfig(t *testing.T) {
+	role := "active"
+	queues := `[{"queueName":"q1","maxMsgSpoolUsage":100},{"queueName":"q2","maxMsgSpoolUsage":100}]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/SEMP/v2/config/msgVpns/orders":
+			w.Write([]byte(`{"data":{"msgVpnName":"orders","replicationEnabled":true,"replicationRole":"` + role + `"},"meta":{}}`))
+		case "/SEMP/v2/config/msgVpns/orders/queues":
+			if r.URL.Query().Get("cursor") == "" {
+				w.Write([]byte(`{"data":` + queues + `,"meta":{"paging":{"nextPageUri":"http://` + r.Host + r.URL.Path + `?cursor=2"}}}`))
+				return
+			}
+			w.Write([]byte(`{"data":[{"queueName":"q3","maxMsgSpoolUsage":10}],"meta":{}}`))
+		case "/SEMP/v2/config/msgVpns/orders/clientProfiles":
+			w.Write([]byte(`{"data":[{"clientProfileName":"default","allowGuaranteedMsgSendEnabled":true}],"meta":{}}`))
+		case "/SEMP/v2/config/msgVpns/orders/aclProfiles":
+			w.Write([]byte(`{"data":[{"aclProfileName":"default","clientConnectDefaultAction":"allow"}],"meta":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	mate := &models.MateResult{DC: "dc1", Host: u.Hostname(), Port: port}
+
+	ctx := context.Background()
+	before, err := captureVPNConfig(ctx, "orders", mate, "before", models.BasicAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(before.Objects[models.ConfigKindQueue]); n != 3 {
+		t.Fatalf("expected 3 queues across pages, got %d", n)
+	}
+
+	role = "standby"
+	queues = `[{"queueName":"q1","maxMsgSpoolUsage":200},{"queueName":"q4","maxMsgSpoolUsage":100}]`
+	after, err := captureVPNConfig(ctx, "orders", mate, "after", models.BasicAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := DiffVPNConfig(before, after)
+	want := []models.ConfigChange{
+		{Kind: models.ConfigKindMsgVpn, Name: "orders", Change: "changed", Field: "replicationRole", Before: "active", After: "standby"},
+		{Kind: models.ConfigKindQueue, Name: "q1", Change: "changed", Field: "maxMsgSpoolUsage", Before: float64(100), After: float64(200)},
+		{Kind: models.ConfigKindQueue, Name: "q2", Change: "removed"},
+		{Kind: models.ConfigKindQueue, Name: "q4", Change: "added"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: expected %+v, got %+v", i, want[i], changes[i])
+		}
+	}
+
+	if len(DiffVPNConfig(after, after)) != 0 {
+		t.Errorf("expected no change between identical snapshots")
+	}
+
+	// the refs only point at the stored snapshots, the diff reads them back
+	prev := currentSnapshotDir()
+	SetSnapshotDir(t.TempDir())
+	defer SetSnapshotDir(prev)
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(CaptureVPNConfigSnapshotActivity)
+	env.RegisterActivity(DiffVPNConfigSnapshotsActivity)
+
+	var refs []models.ConfigSnapshotRef
+	for _, phase := range []string{"before", "after"} {
+		val, err := env.ExecuteActivity(CaptureVPNConfigSnapshotActivity, "orders", mate, phase, models.BasicAuth{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ref models.ConfigSnapshotRef
+		if err := val.Get(&ref); err != nil {
+			t.Fatal(err)
+		}
+		if ref.Objects != 6 || filepath.Dir(ref.Path) != currentSnapshotDir() {
+			t.Fatalf("expected a reference to the stored snapshot, got %+v", ref)
+		}
+		refs = append(refs, ref)
+	}
+	val, err := env.ExecuteActivity(DiffVPNConfigSnapshotsActivity, refs[0], refs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diff models.ConfigDiff
+	if err := val.Get(&diff); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changes) != 0 {
+		t.Errorf("expected no change between unchanged snapshots, got %+v", diff.Changes)
+	}
+
+	if err := os.Remove(refs[1].Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.ExecuteActivity(DiffVPNConfigSnapshotsActivity, refs[0], refs[1]); err == nil {
+		t.Errorf("expected the diff of a missing snapshot to fail")
+	}
+}
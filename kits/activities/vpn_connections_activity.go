@@ -0,0 +1,101 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+)
+
+type vpnConnectionsResponse struct {
+	Data struct {
+		Name        string `json:"msgVpnName"`
+		Connections int    `json:"msgVpnConnections"`
+	} `json:"data"`
+}
+
+type queueBindPage struct {
+	Data []struct {
+		QueueName string `json:"queueName"`
+		BindCount int    `json:"bindCount"`
+	} `json:"data"`
+	Meta struct {
+		Paging struct {
+			NextPageURI string `json:"nextPageUri"`
+		} `json:"paging"`
+	} `json:"meta"`
+}
+
+// VPNConnectionsActivity counts the client connections of a VPN and the
+// consumer binds on its queues.
+func VPNConnectionsActivity(
+	ctx context.Context,
+	host string,
+	port int,
+	vpn string,
+	auth models.BasicAuth,
+) (models.VPNConnections, error) {
+
+	snap := models.VPNConnections{Host: host, Port: port}
+
+	base := fmt.Sprintf(
+		"%s://%s:%d/SEMP/v2/monitor/msgVpns/%s",
+		config.CURRENT_HTTP_SCHEME, host, port, url.PathEscape(vpn),
+	)
+
+	var conns vpnConnectionsResponse
+	if err := sempV2Get(ctx, base+"?select=msgVpnName,msgVpnConnections", auth, &conns); err != nil {
+		return snap, err
+	}
+	snap.Clients = conns.Data.Connections
+
+	next := base + "/queues?count=100&select=queueName,bindCount"
+	for next != "" {
+		var page queueBindPage
+		if err := sempV2Get(ctx, next, auth, &page); err != nil {
+			return snap, err
+		}
+		for _, q := range page.Data {
+			if strings.HasPrefix(q.QueueName, "#") {
+				continue
+			}
+			snap.Queues++
+			snap.QueueBinds += q.BindCount
+		}
+		next = page.Meta.Paging.NextPageURI
+	}
+
+	activity.GetLogger(ctx).Info(
+		"vpn connections counted",
+		"vpn", vpn,
+		"host", host,
+		"clients", snap.Clients,
+		"queueBinds", snap.QueueBinds,
+	)
+	return snap, nil
+}
+
+func sempV2Get(ctx context.Context, url string, auth models.BasicAuth, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	applyBasicAuth(req, auth)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SEMP v2 request failed: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
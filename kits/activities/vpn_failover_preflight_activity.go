@@ -0,0 +1,234 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.temporal.io/sdk/activity"
+)
+
+const (
+	PreflightReplicationBridge = "replication-bridge"
+	PreflightConfigSync        = "config-sync"
+	PreflightQueueSpool        = "queue-spool"
+	PreflightActiveSite        = "active-site"
+)
+
+type queueSpoolPage struct {
+	Data []struct {
+		QueueName        string  `json:"queueName"`
+		MsgSpoolUsage    float64 `json:"msgSpoolUsage"`
+		MaxMsgSpoolUsage float64 `json:"maxMsgSpoolUsage"`
+	} `json:"data"`
+	Meta struct {
+		Paging struct {
+			NextPageURI string `json:"nextPageUri"`
+		} `json:"paging"`
+	} `json:"meta"`
+}
+
+// FailoverPreflightActivity decides whether the VPN can safely be promoted on
+// the standby site: the replication bridge must be up and the VPN config in
+// sync on every reachable site, and no standby queue may be close to its
+// spool quota. A missing or unreachable active site is always a no-go, only a
+// forced failover proceeds without it.
+//
+// Failed checks never fail the activity; they are reported in the verdict.
+func FailoverPreflightActivity(
+	ctx context.Context,
+	vpn string,
+	active *models.MateResult,
+	standby *models.MateResult,
+	auth models.BasicAuth,
+) (models.FailoverPreflight, error) {
+
+	verdict := failoverPreflight(ctx, vpn, active, standby, auth)
+
+	activity.GetLogger(ctx).Info(
+		"failover pre-flight evaluated",
+		"vpn", vpn,
+		"go", verdict.Go,
+		"reasons", verdict.Reasons,
+	)
+	return verdict, nil
+}
+
+func failoverPreflight(
+	ctx context.Context,
+	vpn string,
+	active *models.MateResult,
+	standby *models.MateResult,
+	auth models.BasicAuth,
+) models.FailoverPreflight {
+
+	var checks []models.PreflightCheck
+
+	activeUnreachable := active == nil
+	if active == nil {
+		checks = append(checks, models.PreflightCheck{
+			Name:   PreflightActiveSite,
+			Detail: "active site unreachable, replication state cannot be verified",
+		})
+	}
+
+	for _, m := range []*models.MateResult{active, standby} {
+		if m == nil {
+			continue
+		}
+		client := NewSEMPv1Client(models.Endpoint{Host: m.Host, Port: m.Port}, auth)
+		bridge, err := checkReplicationBridge(ctx, client, vpn, m)
+		var netErr net.Error
+		if m == active && errors.As(err, &netErr) {
+			// the broker does not answer at all, config-sync would not either
+			activeUnreachable = true
+			c := newPreflightCheck(PreflightActiveSite, m)
+			c.Detail = "active site unreachable, replication state cannot be verified: " + err.Error()
+			checks = append(checks, c)
+			continue
+		}
+		checks = append(checks, bridge, checkVPNConfigSync(ctx, client, vpn, m))
+	}
+
+	if standby != nil {
+		checks = append(checks, checkStandbyQueueSpool(ctx, vpn, standby, auth))
+	}
+
+	verdict := preflightVerdict(vpn, checks)
+	verdict.ActiveUnreachable = activeUnreachable
+	return verdict
+}
+
+func preflightVerdict(vpn string, checks []models.PreflightCheck) models.FailoverPreflight {
+	verdict := models.FailoverPreflight{VPN: vpn, Go: true, Checks: checks}
+	for _, c := range checks {
+		if c.OK {
+			continue
+		}
+		verdict.Go = false
+		site := c.DC
+		if site == "" {
+			site = "-"
+		}
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%s %s: %s", site, c.Name, c.Detail))
+	}
+	return verdict
+}
+
+func newPreflightCheck(name string, m *models.MateResult) models.PreflightCheck {
+	return models.PreflightCheck{Name: name, DC: m.DC, Host: m.Host}
+}
+
+// checkReplicationBridge also returns the SEMP error, if any, so that an
+// unreachable broker can be told from a failed check.
+func checkReplicationBridge(ctx context.Context, client *SEMPv1Client, vpn string, m *models.MateResult) (models.PreflightCheck, error) {
+	c := newPreflightCheck(PreflightReplicationBridge, m)
+
+	reply, err := client.ShowMessageVPN(ctx, vpn, true)
+	if err != nil {
+		c.Detail = err.Error()
+		return c, err
+	}
+	for _, r := range reply.Replication.VPNs {
+		if r.VPNName != vpn {
+			continue
+		}
+		c.OK = r.BridgeState == "Up"
+		c.Detail = fmt.Sprintf("bridge %s, config-state %s, queue %s", r.BridgeState, r.ConfigState, r.QueueState)
+		return c, nil
+	}
+	c.Detail = "vpn has no replication state"
+	return c, nil
+}
+
+func checkVPNConfigSync(ctx context.Context, client *SEMPv1Client, vpn string, m *models.MateResult) models.PreflightCheck {
+	c := newPreflightCheck(PreflightConfigSync, m)
+
+	tables, err := client.ShowConfigSyncDatabase(ctx, vpn)
+	if err != nil {
+		c.Detail = err.Error()
+		return c
+	}
+	for _, t := range tables {
+		if t.Name != vpn || !strings.EqualFold(t.Type, "Vpn") {
+			continue
+		}
+		c.OK = t.SyncState == "In-Sync"
+		c.Detail = t.SyncState
+		return c
+	}
+	c.Detail = "vpn not found in config-sync database"
+	return c
+}
+
+// checkStandbyQueueSpool refuses the failover when a queue on the standby
+// site is above config.PREFLIGHT_MAX_QUEUE_SPOOL_USAGE_PCT of its quota.
+func checkStandbyQueueSpool(ctx context.Context, vpn string, m *models.MateResult, auth models.BasicAuth) models.PreflightCheck {
+	c := newPreflightCheck(PreflightQueueSpool, m)
+
+	next := fmt.Sprintf(
+		"%s://%s:%d/SEMP/v2/monitor/msgVpns/%s/queues?count=100&select=queueName,msgSpoolUsage,maxMsgSpoolUsage",
+		config.CURRENT_HTTP_SCHEME, m.Host, m.Port, url.PathEscape(vpn),
+	)
+
+	var full []string
+	queues := 0
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			c.Detail = err.Error()
+			return c
+		}
+		applyBasicAuth(req, auth)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			c.Detail = err.Error()
+			return c
+		}
+
+		var page queueSpoolPage
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("queues monitor request failed: %s", resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			c.Detail = err.Error()
+			return c
+		}
+
+		for _, q := range page.Data {
+			// skip internal queues such as #MSGVPN_REPLICATION_DATA_QUEUE
+			if strings.HasPrefix(q.QueueName, "#") || q.MaxMsgSpoolUsage <= 0 {
+				continue
+			}
+			queues++
+			// msgSpoolUsage is in bytes, maxMsgSpoolUsage in MB
+			pct := q.MsgSpoolUsage / (q.MaxMsgSpoolUsage * 1024 * 1024) * 100
+			if pct > config.PREFLIGHT_MAX_QUEUE_SPOOL_USAGE_PCT {
+				full = append(full, fmt.Sprintf("%s %.1f%%", q.QueueName, pct))
+			}
+		}
+		next = page.Meta.Paging.NextPageURI
+	}
+
+	if len(full) > 0 {
+		c.Detail = fmt.Sprintf(
+			"queues above %.0f%% of their spool quota: %s",
+			config.PREFLIGHT_MAX_QUEUE_SPOOL_USAGE_PCT, strings.Join(full, ", "),
+		)
+		return c
+	}
+	c.OK = true
+	c.Detail = fmt.Sprintf("%d queues below %.0f%% of their spool quota", queues, config.PREFLIGHT_MAX_QUEUE_SPOOL_USAGE_PCT)
+	return c
+}
@@ -0,0 +1,97 @@
+package activities
+
+import (
+	"context"
+	"io"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// preflightBroker serves the SEMP v1 replication and config-sync fixtures and
+// a SEMP v2 queue monitor answering the given queues JSON.
+func preflightBroker(t *testing.T, dc string, queues string) *models.MateResult {
+	t.Helper()
+	fixture := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join("testdata", "semp_v1", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	replication := fixture("show_message_vpn_replication.xml")
+	configSync := fixture("show_config_sync_database_vpn.xml")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/SEMP":
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "<database>") {
+				w.Write(configSync)
+			} else {
+				w.Write(replication)
+			}
+		case r.URL.Path == "/SEMP/v2/monitor/msgVpns/orders/queues":
+			w.Write([]byte(queues))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return &models.MateResult{DC: dc, Host: u.Hostname(), Port: port}
+}
+
+func TestFailoverPreflight(t *testing.T) {
+	ctx := context.Background()
+	healthy := `{"data":[{"queueName":"#MSGVPN_REPLICATION_DATA_QUEUE","msgSpoolUsage":99999999,"maxMsgSpoolUsage":1},` +
+		`{"queueName":"q1","msgSpoolUsage":1048576,"maxMsgSpoolUsage":100}],"meta":{}}`
+	full := `{"data":[{"queueName":"q1","msgSpoolUsage":99614720,"maxMsgSpoolUsage":100}],"meta":{}}`
+
+	active := preflightBroker(t, "dc1", healthy)
+	standby := preflightBroker(t, "dc2", healthy)
+	verdict := failoverPreflight(ctx, "orders", active, standby, models.BasicAuth{})
+	if !verdict.Go || verdict.ActiveUnreachable {
+		t.Fatalf("expected go, got reasons %v", verdict.Reasons)
+	}
+	if len(verdict.Checks) != 5 {
+		t.Errorf("expected 5 checks, got %+v", verdict.Checks)
+	}
+
+	standby = preflightBroker(t, "dc2", full)
+	verdict = failoverPreflight(ctx, "orders", active, standby, models.BasicAuth{})
+	if verdict.Go || len(verdict.Reasons) != 1 || !strings.Contains(verdict.Reasons[0], "q1 95.0%") {
+		t.Errorf("expected no-go on standby spool, got %+v", verdict)
+	}
+
+	// the VPN is unknown to the broker fixtures
+	verdict = failoverPreflight(ctx, "payments", active, preflightBroker(t, "dc2", healthy), models.BasicAuth{})
+	if verdict.Go {
+		t.Errorf("expected no-go for a vpn without replication state")
+	}
+
+	// without the active site the replication state cannot be trusted
+	verdict = failoverPreflight(ctx, "orders", nil, preflightBroker(t, "dc2", healthy), models.BasicAuth{})
+	if verdict.Go || verdict.Checks[0].Name != PreflightActiveSite || !verdict.ActiveUnreachable {
+		t.Errorf("expected no-go without active site, got %+v", verdict)
+	}
+
+	// an active site that does not answer is reported as unreachable
+	srv := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(srv.URL)
+	srv.Close()
+	lost := &models.MateResult{DC: "dc1", Host: u.Hostname()}
+	lost.Port, _ = strconv.Atoi(u.Port())
+	verdict = failoverPreflight(ctx, "orders", lost, preflightBroker(t, "dc2", healthy), models.BasicAuth{})
+	if verdict.Go || !verdict.ActiveUnreachable || len(verdict.Checks) != 4 || verdict.Checks[0].Name != PreflightActiveSite {
+		t.Errorf("expected no-go with the active site unreachable, got %+v", verdict)
+	}
+}
@@ -0,0 +1,226 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kits-worker/kits/config"
+	"sync"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// VPN lock workflow and updates, named here as activities cannot import the
+// workflows.
+const (
+	VPNLockWorkflowName = "VPNLockWorkflow"
+	VPNLockAcquire      = "acquire"
+	VPNLockRelease      = "release"
+	VPNLockRenew        = "renew"
+	VPNLockQuery        = "holder"
+
+	// VPNLockBusy is the error type of a lock still held by another
+	// failover once the wait timed out.
+	VPNLockBusy = "VPNLockBusy"
+	// VPNLockLost is the error type of a lease renewed by an owner no
+	// longer holding the lock.
+	VPNLockLost = "VPNLockLost"
+)
+
+// VPNLocker serializes the failovers of a VPN. Acquire blocks until owner
+// holds the lock of key, and returns at once when it already does. Renew
+// extends the lease of the lock owner holds, and fails with a VPNLockLost
+// error when it no longer does.
+type VPNLocker interface {
+	Acquire(ctx context.Context, key, owner string) error
+	Release(ctx context.Context, key, owner string) error
+	Renew(ctx context.Context, key, owner string) error
+}
+
+var (
+	vpnLockerMu sync.RWMutex
+	vpnLocker   VPNLocker = NewLocalVPNLocker()
+)
+
+// SetVPNLocker sets the locker of the VPN failovers, a LocalVPNLocker by
+// default.
+func SetVPNLocker(l VPNLocker) {
+	vpnLockerMu.Lock()
+	defer vpnLockerMu.Unlock()
+	vpnLocker = l
+}
+
+func currentVPNLocker() VPNLocker {
+	vpnLockerMu.RLock()
+	defer vpnLockerMu.RUnlock()
+	return vpnLocker
+}
+
+// VPNLockID is the workflow ID of the lock of key.
+func VPNLockID(key string) string {
+	return "vpn-lock/" + key
+}
+
+// TemporalVPNLocker holds the locks in VPNLockWorkflow executions, one per
+// key, so that a single failover of a VPN is in flight across the
+// namespace.
+type TemporalVPNLocker struct {
+	Client    client.Client
+	TaskQueue string
+}
+
+func (l *TemporalVPNLocker) Acquire(ctx context.Context, key, owner string) error {
+	start := l.Client.NewWithStartWorkflowOperation(client.StartWorkflowOptions{
+		ID:                       VPNLockID(key),
+		TaskQueue:                l.TaskQueue,
+		WorkflowIDConflictPolicy: enumspb.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING,
+		WorkflowIDReusePolicy:    enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+	}, VPNLockWorkflowName, key)
+	handle, err := l.Client.UpdateWithStartWorkflow(ctx, client.UpdateWithStartWorkflowOptions{
+		StartWorkflowOperation: start,
+		UpdateOptions: client.UpdateWorkflowOptions{
+			WorkflowID:   VPNLockID(key),
+			UpdateName:   VPNLockAcquire,
+			Args:         []interface{}{owner},
+			WaitForStage: client.WorkflowUpdateStageCompleted,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return handle.Get(ctx, nil)
+}
+
+func (l *TemporalVPNLocker) Release(ctx context.Context, key, owner string) error {
+	handle, err := l.Client.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   VPNLockID(key),
+		UpdateName:   VPNLockRelease,
+		Args:         []interface{}{owner},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		return err
+	}
+	return handle.Get(ctx, nil)
+}
+
+func (l *TemporalVPNLocker) Renew(ctx context.Context, key, owner string) error {
+	handle, err := l.Client.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   VPNLockID(key),
+		UpdateName:   VPNLockRenew,
+		Args:         []interface{}{owner},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		return err
+	}
+	return handle.Get(ctx, nil)
+}
+
+// LocalVPNLocker holds the locks in memory, it only serializes the
+// failovers of a single worker.
+type LocalVPNLocker struct {
+	mu    sync.Mutex
+	locks map[string]*localVPNLock
+}
+
+type localVPNLock struct {
+	owner    string
+	released chan struct{}
+}
+
+func NewLocalVPNLocker() *LocalVPNLocker {
+	return &LocalVPNLocker{locks: make(map[string]*localVPNLock)}
+}
+
+func (l *LocalVPNLocker) Acquire(ctx context.Context, key, owner string) error {
+	for {
+		l.mu.Lock()
+		lock := l.locks[key]
+		if lock == nil {
+			l.locks[key] = &localVPNLock{owner: owner, released: make(chan struct{})}
+			l.mu.Unlock()
+			return nil
+		}
+		if lock.owner == owner {
+			l.mu.Unlock()
+			return nil
+		}
+		released := lock.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return fmt.Errorf("vpn lock %s held by %s: %w", key, lock.owner, ctx.Err())
+		}
+	}
+}
+
+func (l *LocalVPNLocker) Release(ctx context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock := l.locks[key]; lock != nil && lock.owner == owner {
+		delete(l.locks, key)
+		close(lock.released)
+	}
+	return nil
+}
+
+// Renew only checks that owner holds the lock, local locks have no lease.
+func (l *LocalVPNLocker) Renew(ctx context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock := l.locks[key]; lock == nil || lock.owner != owner {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("vpn lock %s not held by %s", key, owner), VPNLockLost, nil)
+	}
+	return nil
+}
+
+// AcquireVPNLockActivity waits until owner holds the lock of key, ie until
+// no other failover of the VPN is in flight. A lock still held after
+// config.VPN_LOCK_WAIT_TIMEOUT fails with a non-retryable VPNLockBusy error.
+func AcquireVPNLockActivity(ctx context.Context, key, owner string) error {
+	activity.GetLogger(ctx).Info("Acquiring VPN lock", "key", key, "owner", owner)
+
+	ctx, cancel := context.WithTimeout(ctx, config.VPN_LOCK_WAIT_TIMEOUT)
+	defer cancel()
+
+	err := currentVPNLocker().Acquire(ctx, key, owner)
+	if err == nil {
+		return nil
+	}
+	var appErr *temporal.ApplicationError
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &appErr) && appErr.Type() == VPNLockBusy {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("vpn lock %s still held by another failover", key), VPNLockBusy, err)
+	}
+	return fmt.Errorf("acquiring vpn lock %s: %w", key, err)
+}
+
+// ReleaseVPNLockActivity releases the lock of key held by owner.
+func ReleaseVPNLockActivity(ctx context.Context, key, owner string) error {
+	if err := currentVPNLocker().Release(ctx, key, owner); err != nil {
+		return fmt.Errorf("releasing vpn lock %s: %w", key, err)
+	}
+	return nil
+}
+
+// RenewVPNLockActivity renews the lease of the lock of key held by owner.
+// A lock owner no longer holds fails with a non-retryable VPNLockLost error.
+func RenewVPNLockActivity(ctx context.Context, key, owner string) error {
+	err := currentVPNLocker().Renew(ctx, key, owner)
+	if err == nil {
+		return nil
+	}
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == VPNLockLost {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("vpn lock %s no longer held by %s", key, owner), VPNLockLost, err)
+	}
+	return fmt.Errorf("renewing vpn lock %s: %w", key, err)
+}
@@ -0,0 +1,60 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestLocalVPNLocker(t *testing.T) {
+	l := NewLocalVPNLocker()
+	ctx := context.Background()
+
+	if err := l.Acquire(ctx, "kit1/orders", "run-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Acquire(ctx, "kit1/orders", "run-1"); err != nil {
+		t.Fatalf("expected the holder to get the lock again, got %v", err)
+	}
+	if err := l.Acquire(ctx, "kit1/billing", "run-2"); err != nil {
+		t.Fatalf("expected another VPN not to wait, got %v", err)
+	}
+	if err := l.Renew(ctx, "kit1/orders", "run-1"); err != nil {
+		t.Fatalf("expected the holder to renew, got %v", err)
+	}
+	var appErr *temporal.ApplicationError
+	if err := l.Renew(ctx, "kit1/orders", "run-2"); !errors.As(err, &appErr) || appErr.Type() != VPNLockLost {
+		t.Fatalf("expected VPNLockLost for another owner, got %v", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(short, "kit1/orders", "run-2"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second failover to wait for the first, got %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- l.Acquire(ctx, "kit1/orders", "run-2") }()
+	if err := l.Release(ctx, "kit1/orders", "run-3"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-acquired:
+		t.Fatalf("expected the lock kept after the release of another owner, got %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if err := l.Release(ctx, "kit1/orders", "run-1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiting failover to get the released lock")
+	}
+}
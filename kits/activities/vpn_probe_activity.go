@@ -0,0 +1,84 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+// PublishSubscribeProbeActivity publishes a canary message on the VPN of the
+// given (newly active) mate and waits until it is consumed, proving that the
+// VPN actually serves messaging and not only reports the active role.
+//
+// A failed probe is reported in the result, not as an activity error.
+func PublishSubscribeProbeActivity(
+	ctx context.Context,
+	vpn string,
+	target *models.MateResult,
+	opts models.ProbeOptions,
+	auth models.BasicAuth,
+) (models.ProbeResult, error) {
+
+	info := activity.GetInfo(ctx)
+	id := fmt.Sprintf("%s-%s-%d", vpn, info.WorkflowExecution.RunID, info.Attempt)
+
+	result := probeVPN(ctx, ProbeTarget{
+		Host:     target.Host,
+		SEMPPort: target.Port,
+		VPN:      vpn,
+		Options:  opts,
+		SEMPAuth: auth,
+	}, id)
+
+	logger := activity.GetLogger(ctx)
+	if result.Consumed {
+		logger.Info("canary consumed", "vpn", vpn, "host", target.Host, "latencyMs", result.LatencyMs, "verified", result.Verified)
+	} else {
+		logger.Error("canary probe failed", "vpn", vpn, "host", target.Host, "error", result.Error)
+	}
+	return result, nil
+}
+
+func probeVPN(ctx context.Context, target ProbeTarget, id string) models.ProbeResult {
+	topic := config.PROBE_TOPIC_PREFIX + target.VPN + "/" + id
+	result := models.ProbeResult{VPN: target.VPN, Host: target.Host, Topic: topic, ID: id}
+
+	fail := func(step string, err error) models.ProbeResult {
+		result.Error = fmt.Sprintf("%s: %v", step, err)
+		return result
+	}
+
+	transport, err := currentMessagingTransportFactory()(target)
+	if err != nil {
+		return fail("connect", err)
+	}
+	defer transport.Close()
+
+	sub, err := transport.Subscribe(ctx, topic)
+	if err != nil {
+		return fail("subscribe", err)
+	}
+	defer sub.Close()
+
+	start := time.Now()
+	msg := CanaryMessage{ID: id, Payload: []byte(fmt.Sprintf(`{"canary":%q,"vpn":%q}`, id, target.VPN))}
+	if err := transport.Publish(ctx, topic, msg); err != nil {
+		return fail("publish", err)
+	}
+	result.Published = true
+
+	awaitCtx, cancel := context.WithTimeout(ctx, config.PROBE_TIMEOUT)
+	defer cancel()
+	verified, err := sub.Await(awaitCtx, msg)
+	if err != nil {
+		return fail("consume", err)
+	}
+	result.Consumed = true
+	result.Verified = verified
+	result.LatencyMs = time.Since(start).Milliseconds()
+	return result
+}
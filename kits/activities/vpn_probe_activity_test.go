@@ -0,0 +1,41 @@
+package activities
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestProbeVPN(t *testing.T) {
+	broker := NewInMemoryBroker()
+	SetMessagingTransportFactory(broker.Factory())
+	defer SetMessagingTransportFactory(NewRESTMessagingTransport)
+
+	ctx := context.Background()
+
+	r := probeVPN(ctx, ProbeTarget{Host: "broker-dc2", VPN: "orders"}, "c1")
+	if !r.Published || !r.Consumed || !r.Verified || r.Error != "" {
+		t.Errorf("expected canary consumed, got %+v", r)
+	}
+	if !strings.HasSuffix(r.Topic, "orders/c1") {
+		t.Errorf("unexpected topic %s", r.Topic)
+	}
+
+	broker.Blackhole["orders"] = true
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	r = probeVPN(ctx, ProbeTarget{Host: "broker-dc2", VPN: "orders"}, "c2")
+	if !r.Published || r.Consumed || !strings.HasPrefix(r.Error, "consume") {
+		t.Errorf("expected canary lost, got %+v", r)
+	}
+
+	broker.Down["payments"] = true
+	r = probeVPN(context.Background(), ProbeTarget{Host: "broker-dc2", VPN: "payments"}, "c3")
+	if r.Published || !strings.HasPrefix(r.Error, "connect") {
+		t.Errorf("expected connection refused, got %+v", r)
+	}
+
+	if _, err := NewRESTMessagingTransport(ProbeTarget{Host: "broker-dc2", VPN: "orders"}); err == nil {
+		t.Errorf("expected REST transport to require a probe queue")
+	}
+}
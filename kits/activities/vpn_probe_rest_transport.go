@@ -0,0 +1,194 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RESTMessagingTransport publishes with Solace REST messaging and consumes
+// from a pre-provisioned probe queue (subscribed to the canary topics) by
+// browsing it over SEMP v2 and deleting the canary once found.
+//
+// SEMP does not expose message payloads or correlation ids, so the canary is
+// the first message spooled on the probe queue after Subscribe with the size
+// of the canary payload. Other messages are left on the queue, and Await
+// reports the canary as not verified since a message of the same size
+// spooled meanwhile would be taken for it.
+type RESTMessagingTransport struct {
+	target     ProbeTarget
+	restURL    string
+	sempURL    string // SEMP v2 root, followed by the API (monitor, action) and msgsPath
+	msgsPath   string
+	httpClient *http.Client
+}
+
+func NewRESTMessagingTransport(target ProbeTarget) (MessagingTransport, error) {
+	if target.Options.Queue == "" {
+		return nil, fmt.Errorf("probe queue is required by the REST messaging transport")
+	}
+	port := target.Options.RestPort
+	if port == 0 {
+		port = config.PROBE_DEFAULT_REST_PORT
+	}
+	return &RESTMessagingTransport{
+		target:  target,
+		restURL: fmt.Sprintf("%s://%s:%d", config.CURRENT_HTTP_SCHEME, target.Host, port),
+		sempURL: fmt.Sprintf("%s://%s:%d/SEMP/v2/", config.CURRENT_HTTP_SCHEME, target.Host, target.SEMPPort),
+		msgsPath: fmt.Sprintf(
+			"/msgVpns/%s/queues/%s/msgs",
+			url.PathEscape(target.VPN), url.PathEscape(target.Options.Queue),
+		),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type queueMsg struct {
+	MsgID          int64 `json:"msgId"`
+	AttachmentSize int64 `json:"attachmentSize"`
+	ContentSize    int64 `json:"contentSize"`
+}
+
+type queueMsgsPage struct {
+	Data []queueMsg `json:"data"`
+	Meta struct {
+		Paging struct {
+			NextPageURI string `json:"nextPageUri"`
+		} `json:"paging"`
+	} `json:"meta"`
+}
+
+// spooledMsgs lists the messages spooled on the probe queue.
+func (t *RESTMessagingTransport) spooledMsgs(ctx context.Context) ([]queueMsg, error) {
+	var msgs []queueMsg
+	next := t.sempURL + "monitor" + t.msgsPath + "?count=100&select=msgId,attachmentSize,contentSize"
+	for next != "" {
+		var page queueMsgsPage
+		if err := sempV2Get(ctx, next, t.target.SEMPAuth, &page); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, page.Data...)
+		next = page.Meta.Paging.NextPageURI
+	}
+	return msgs, nil
+}
+
+func (t *RESTMessagingTransport) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	msgs, err := t.spooledMsgs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var last int64
+	for _, m := range msgs {
+		if m.MsgID > last {
+			last = m.MsgID
+		}
+	}
+	return &restQueueSubscription{transport: t, lastMsgID: last}, nil
+}
+
+func (t *RESTMessagingTransport) Publish(ctx context.Context, topic string, msg CanaryMessage) error {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		t.restURL+"/TOPIC/"+topic,
+		bytes.NewReader(msg.Payload),
+	)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.target.Options.Username, t.target.Options.Password)
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("Solace-Delivery-Mode", "persistent")
+	req.Header.Set("Solace-Correlation-ID", msg.ID)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("REST publish on %s failed: %s", topic, resp.Status)
+	}
+	return nil
+}
+
+func (t *RESTMessagingTransport) Close() error { return nil }
+
+type restQueueSubscription struct {
+	transport *RESTMessagingTransport
+	lastMsgID int64
+}
+
+func (s *restQueueSubscription) Await(ctx context.Context, msg CanaryMessage) (bool, error) {
+	ticker := time.NewTicker(config.PROBE_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	// REST messaging publishes a text/plain body as the binary attachment
+	size := int64(len(msg.Payload))
+	for {
+		msgs, err := s.transport.spooledMsgs(ctx)
+		if err != nil {
+			return false, err
+		}
+		for _, m := range msgs {
+			if m.MsgID > s.lastMsgID && m.AttachmentSize+m.ContentSize == size {
+				return false, s.consume(ctx, m.MsgID)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// consume removes the canary from the probe queue.
+func (s *restQueueSubscription) consume(ctx context.Context, msgID int64) error {
+	u := fmt.Sprintf("%saction%s/%d/delete", s.transport.sempURL, s.transport.msgsPath, msgID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewBufferString("{}"))
+	if err != nil {
+		return err
+	}
+	applyBasicAuth(req, s.transport.target.SEMPAuth)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := models.AuditRecord{
+		Action:      "DeleteProbeQueueMsg",
+		Host:        s.transport.target.Host,
+		Port:        s.transport.target.SEMPPort,
+		VPN:         s.transport.target.VPN,
+		SEMPUser:    s.transport.target.SEMPAuth.Username,
+		Method:      http.MethodPut,
+		URL:         u,
+		RequestBody: "{}",
+	}
+
+	resp, err := s.transport.httpClient.Do(req)
+	if err != nil {
+		rec.Error = err.Error()
+		recordAudit(ctx, rec)
+		return err
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	rec.StatusCode = resp.StatusCode
+	rec.ResponseBody = string(respBody)
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("deleting canary %d from probe queue failed: %s", msgID, resp.Status)
+		rec.Error = err.Error()
+	}
+	recordAudit(ctx, rec)
+	return err
+}
+
+func (s *restQueueSubscription) Close() error { return nil }
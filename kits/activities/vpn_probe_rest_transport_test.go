@@ -0,0 +1,81 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"kits-worker/kits/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRESTMessagingTransport_ConsumesCanaryOnly(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		spooled     = []queueMsg{{MsgID: 5, AttachmentSize: 3}}
+		correlation string
+		deleted     []int64
+	)
+	const msgsPath = "/SEMP/v2/%s/msgVpns/orders/queues/probe/msgs"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/TOPIC/kits/dr/canary/orders/"):
+			body, _ := io.ReadAll(r.Body)
+			correlation = r.Header.Get("Solace-Correlation-ID")
+			// another publisher spools a message of its own before the canary
+			spooled = append(spooled,
+				queueMsg{MsgID: 6, AttachmentSize: int64(len(body)) + 10},
+				queueMsg{MsgID: 7, AttachmentSize: int64(len(body))},
+			)
+		case r.Method == http.MethodGet && r.URL.Path == strings.Replace(msgsPath, "%s", "monitor", 1):
+			json.NewEncoder(w).Encode(queueMsgsPage{Data: spooled})
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, strings.Replace(msgsPath, "%s", "action", 1)+"/"):
+			segs := strings.Split(r.URL.Path, "/")
+			id, _ := strconv.ParseInt(segs[len(segs)-2], 10, 64)
+			deleted = append(deleted, id)
+			for i, m := range spooled {
+				if m.MsgID == id {
+					spooled = append(spooled[:i], spooled[i+1:]...)
+					break
+				}
+			}
+			w.Write([]byte(`{"meta":{"responseCode":200}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	r := probeVPN(context.Background(), ProbeTarget{
+		Host:     u.Hostname(),
+		SEMPPort: port,
+		VPN:      "orders",
+		Options:  models.ProbeOptions{RestPort: port, Queue: "probe"},
+	}, "c1")
+
+	if !r.Published || !r.Consumed || r.Error != "" {
+		t.Fatalf("expected canary consumed, got %+v", r)
+	}
+	if r.Verified {
+		t.Errorf("expected a canary matched by size not to be reported verified")
+	}
+	if correlation != "c1" {
+		t.Errorf("expected the canary to carry its correlation id, got %q", correlation)
+	}
+	if len(deleted) != 1 || deleted[0] != 7 {
+		t.Errorf("expected only the canary to be deleted, got %v", deleted)
+	}
+	if len(spooled) != 2 {
+		t.Errorf("expected the other messages to stay spooled, got %v", spooled)
+	}
+}
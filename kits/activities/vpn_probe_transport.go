@@ -0,0 +1,156 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"kits-worker/kits/models"
+	"sync"
+)
+
+// CanaryMessage is the message published by the probe.
+type CanaryMessage struct {
+	ID      string
+	Payload []byte
+}
+
+// MessagingTransport publishes and consumes messages on a single message VPN.
+type MessagingTransport interface {
+	// Subscribe starts consuming topic; it is called before the canary is published.
+	Subscribe(ctx context.Context, topic string) (Subscription, error)
+	Publish(ctx context.Context, topic string, msg CanaryMessage) error
+	Close() error
+}
+
+// Subscription consumes the messages of a MessagingTransport.Subscribe call.
+type Subscription interface {
+	// Await blocks until the canary msg is consumed or ctx is done. verified
+	// is false when the transport could not check the id or payload of the
+	// message it consumed.
+	Await(ctx context.Context, msg CanaryMessage) (verified bool, err error)
+	Close() error
+}
+
+// ProbeTarget is the broker and VPN a transport connects to.
+type ProbeTarget struct {
+	Host     string
+	SEMPPort int
+	VPN      string
+	Options  models.ProbeOptions
+	SEMPAuth models.BasicAuth
+}
+
+// MessagingTransportFactory connects a transport to a probe target.
+type MessagingTransportFactory func(target ProbeTarget) (MessagingTransport, error)
+
+var (
+	transportFactoryMu sync.RWMutex
+	transportFactory   MessagingTransportFactory = NewRESTMessagingTransport
+)
+
+// SetMessagingTransportFactory replaces the transport used by the probe.
+func SetMessagingTransportFactory(f MessagingTransportFactory) {
+	transportFactoryMu.Lock()
+	defer transportFactoryMu.Unlock()
+	transportFactory = f
+}
+
+func currentMessagingTransportFactory() MessagingTransportFactory {
+	transportFactoryMu.RLock()
+	defer transportFactoryMu.RUnlock()
+	return transportFactory
+}
+
+// InMemoryBroker is a fake message broker shared by InMemoryTransport
+// connections. Topics match exactly.
+type InMemoryBroker struct {
+	mu   sync.Mutex
+	subs map[string][]chan CanaryMessage
+	// Down VPNs refuse connections; Blackhole VPNs accept but drop messages.
+	Down      map[string]bool
+	Blackhole map[string]bool
+}
+
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{
+		subs:      make(map[string][]chan CanaryMessage),
+		Down:      make(map[string]bool),
+		Blackhole: make(map[string]bool),
+	}
+}
+
+// Factory returns a MessagingTransportFactory connecting to this broker.
+func (b *InMemoryBroker) Factory() MessagingTransportFactory {
+	return func(target ProbeTarget) (MessagingTransport, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.Down[target.VPN] {
+			return nil, fmt.Errorf("vpn %s is not accepting connections", target.VPN)
+		}
+		return &InMemoryTransport{broker: b, vpn: target.VPN}, nil
+	}
+}
+
+// InMemoryTransport is a MessagingTransport backed by an InMemoryBroker.
+type InMemoryTransport struct {
+	broker *InMemoryBroker
+	vpn    string
+}
+
+func (t *InMemoryTransport) key(topic string) string { return t.vpn + "|" + topic }
+
+func (t *InMemoryTransport) Subscribe(ctx context.Context, topic string) (Subscription, error) {
+	ch := make(chan CanaryMessage, 16)
+	t.broker.mu.Lock()
+	t.broker.subs[t.key(topic)] = append(t.broker.subs[t.key(topic)], ch)
+	t.broker.mu.Unlock()
+	return &inMemorySubscription{broker: t.broker, key: t.key(topic), ch: ch}, nil
+}
+
+func (t *InMemoryTransport) Publish(ctx context.Context, topic string, msg CanaryMessage) error {
+	t.broker.mu.Lock()
+	defer t.broker.mu.Unlock()
+	if t.broker.Blackhole[t.vpn] {
+		return nil
+	}
+	for _, ch := range t.broker.subs[t.key(topic)] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+	return nil
+}
+
+func (t *InMemoryTransport) Close() error { return nil }
+
+type inMemorySubscription struct {
+	broker *InMemoryBroker
+	key    string
+	ch     chan CanaryMessage
+}
+
+func (s *inMemorySubscription) Await(ctx context.Context, msg CanaryMessage) (bool, error) {
+	for {
+		select {
+		case got := <-s.ch:
+			if got.ID == msg.ID {
+				return true, nil
+			}
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+func (s *inMemorySubscription) Close() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	subs := s.broker.subs[s.key]
+	for i, ch := range subs {
+		if ch == s.ch {
+			s.broker.subs[s.key] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
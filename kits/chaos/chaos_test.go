@@ -0,0 +1,80 @@
+package chaos
+
+import (
+	"kits-worker/kits/activities"
+	"path/filepath"
+	"testing"
+)
+
+func TestScenarios(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "scenarios", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scenario found")
+	}
+
+	for _, file := range files {
+		s, err := LoadScenario(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(s.Name, func(t *testing.T) {
+			report, err := Run(s, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range Verify(s, report) {
+				t.Error(p)
+			}
+		})
+	}
+}
+
+func TestRunRestoresWorkerSettings(t *testing.T) {
+	s, err := LoadScenario(filepath.Join("testdata", "scenarios", "dns_flip_failover.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, snapshots := t.TempDir(), t.TempDir()
+	sink := activities.NewJSONLAuditSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	defer activities.SetReportDir(activities.SetReportDir(reports))
+	defer activities.SetSnapshotDir(activities.SetSnapshotDir(snapshots))
+	defer activities.SetAuditSinks(activities.SetAuditSinks(sink)...)
+
+	if _, err := Run(s, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if dir := activities.SetReportDir(reports); dir != reports {
+		t.Errorf("expected the report dir %s back, got %s", reports, dir)
+	}
+	if dir := activities.SetSnapshotDir(snapshots); dir != snapshots {
+		t.Errorf("expected the snapshot dir %s back, got %s", snapshots, dir)
+	}
+	if sinks := activities.SetAuditSinks(sink); len(sinks) != 1 || sinks[0] != sink {
+		t.Errorf("expected the audit sink back, got %v", sinks)
+	}
+	if resolvers := activities.SetDNSQuorumResolvers(nil); resolvers != nil {
+		t.Errorf("expected no quorum resolvers after the run, got %v", resolvers)
+	}
+}
+
+func TestValidateScenario(t *testing.T) {
+	cases := []struct {
+		name string
+		s    Scenario
+	}{
+		{"no vpn", Scenario{Name: "x"}},
+		{"bad role", Scenario{Name: "x", VPNs: map[string]VPNSetup{"orders": {DC1: "primary"}}}},
+		{"dns for unknown vpn", Scenario{Name: "x", VPNs: map[string]VPNSetup{"orders": {}}, DNS: map[string][]DNSRecord{"billing": nil}}},
+		{"event without action", Scenario{Name: "x", VPNs: map[string]VPNSetup{"orders": {}}, Events: []Event{{}}}},
+		{"step without vpn", Scenario{Name: "x", VPNs: map[string]VPNSetup{"orders": {}}, Events: []Event{{Fault: &FaultSpec{Step: "promote"}}}}},
+	}
+	for _, c := range cases {
+		if err := c.s.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", c.name)
+		}
+	}
+}
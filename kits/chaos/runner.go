@@ -0,0 +1,342 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"kits-worker/kits/simulator"
+	"kits-worker/kits/workflows"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultKitName = "kit-drill"
+	defaultRunFor  = 30 * time.Minute
+	// StatusCanceled is the report status of a run canceled after runFor.
+	StatusCanceled = "CANCELED"
+)
+
+// Report is the outcome of a scenario run.
+type Report struct {
+	Scenario string `json:"scenario"`
+	// Status is the run status, CANCELED if the run hit runFor.
+	Status string                  `json:"status"`
+	Error  string                  `json:"error,omitempty"`
+	Result *workflows.KitsDRResult `json:"result,omitempty"`
+	// Roles are the final replication roles per VPN and broker.
+	Roles map[string]map[string]string `json:"roles"`
+	// ActiveMates names the active mate of each site at the end of the run.
+	ActiveMates map[string]string `json:"activeMates"`
+}
+
+// Run executes KitsDRWorkflow with the real activities on the Temporal test
+// environment against a simulated kit set up by the scenario. Time is
+// simulated: timers and retries are skipped, SEMP calls are real HTTP calls
+// to the simulator. workDir receives kits.yaml, snapshots and the audit
+// trail; an empty workDir uses a temporary directory.
+func Run(s Scenario, workDir string) (*Report, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if workDir == "" {
+		dir, err := os.MkdirTemp("", "kits-chaos-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		workDir = dir
+	}
+
+	kit := simulator.NewKit(s.vpnNames()...)
+	defer kit.Close()
+	setupVPNs(kit, s)
+
+	kitName := s.Kit
+	if kitName == "" {
+		kitName = defaultKitName
+	}
+	topology := kit.Topology()
+	topology.DC1.VPNAddresses = s.siteAddresses("dc1")
+	topology.DC2.VPNAddresses = s.siteAddresses("dc2")
+	kitsPath := filepath.Join(workDir, "kits.yaml")
+	data, err := yaml.Marshal(models.KitsFile{Kits: map[string]models.Kit{kitName: topology}})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(kitsPath, data, 0o644); err != nil {
+		return nil, err
+	}
+	// the run stands in for a worker, put the process settings back after it
+	defer activities.SetReportDir(activities.SetReportDir(filepath.Join(workDir, "reports")))
+	defer activities.SetSnapshotDir(activities.SetSnapshotDir(filepath.Join(workDir, "snapshots")))
+	defer activities.SetAuditSinks(activities.SetAuditSinks(activities.NewJSONLAuditSink(filepath.Join(workDir, "audit.jsonl")))...)
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetTestTimeout(2 * time.Minute)
+	workflows.Register(env)
+
+	// the real quorum activity asks one fake resolver per scenario resolver
+	dns := newFakeDNS(s.resolverNames())
+	defer activities.SetDNSQuorumResolvers(activities.SetDNSQuorumResolvers(dns.resolvers()))
+
+	// apply the timeline: t=0 entries before start, the rest on the
+	// workflow clock
+	var setupErr error
+	at := func(d time.Duration, fn func()) {
+		if d <= 0 {
+			fn()
+			return
+		}
+		env.RegisterDelayedCallback(fn, d)
+	}
+	for vpn, records := range s.DNS {
+		for _, r := range records {
+			vpn, r := vpn, r
+			at(r.At, func() { dns.set(vpn, r) })
+		}
+	}
+	for i, e := range s.Events {
+		i, e := i, e
+		at(e.At, func() {
+			if err := applyEvent(kit, e); err != nil && setupErr == nil {
+				setupErr = fmt.Errorf("event %d: %w", i, err)
+			}
+		})
+	}
+	runFor := s.RunFor
+	if runFor <= 0 {
+		runFor = defaultRunFor
+	}
+	// children swallow the cancellation, the flag tells the run hit runFor
+	canceled := false
+	env.RegisterDelayedCallback(func() {
+		canceled = true
+		env.CancelWorkflow()
+	}, runFor)
+
+	env.ExecuteWorkflow(workflows.KitsDRWorkflow, workflows.KitsWorkflowInput{
+		KitsURL:          kitsPath,
+		Auth:             models.BasicAuth{Username: "admin", Password: "admin"},
+		ForceFailover:    s.Options.ForceFailover,
+		FailureThreshold: s.Options.FailureThreshold,
+		DNSQuorum: models.DNSQuorumOptions{
+			Quorum:      s.Options.DNSQuorum,
+			StablePolls: s.Options.DNSStablePolls,
+		},
+	})
+	if setupErr != nil {
+		return nil, setupErr
+	}
+
+	report := &Report{Scenario: s.Name}
+	werr := env.GetWorkflowError()
+	switch {
+	case werr == nil && !canceled:
+		var result workflows.KitsDRResult
+		if err := env.GetWorkflowResult(&result); err != nil {
+			return nil, err
+		}
+		report.Result = &result
+		report.Status = string(result.Status)
+	case canceled || temporal.IsCanceledError(werr):
+		report.Status = StatusCanceled
+	default:
+		report.Error = werr.Error()
+		var appErr *temporal.ApplicationError
+		var result workflows.KitsDRResult
+		if errors.As(werr, &appErr) && appErr.HasDetails() && appErr.Details(&result) == nil {
+			report.Result = &result
+			report.Status = string(result.Status)
+		}
+	}
+
+	report.Roles = make(map[string]map[string]string)
+	report.ActiveMates = make(map[string]string)
+	for _, dc := range []string{"dc1", "dc2"} {
+		b, _ := kit.ActiveMate(dc)
+		report.ActiveMates[dc] = b.Name
+	}
+	for _, vpn := range s.vpnNames() {
+		report.Roles[vpn] = make(map[string]string)
+		for _, b := range kit.Brokers() {
+			report.Roles[vpn][b.Name] = b.VPNRole(vpn)
+		}
+	}
+	return report, nil
+}
+
+func setupVPNs(kit *simulator.Kit, s Scenario) {
+	for vpn, v := range s.VPNs {
+		for dc, role := range map[string]string{"dc1": v.DC1, "dc2": v.DC2} {
+			m1, m2, _ := kit.Mates(dc)
+			for _, b := range []*simulator.Broker{m1, m2} {
+				vpn, v, role := vpn, v, role
+				b.Update(func(st *simulator.State) {
+					sv := st.VPNs[vpn]
+					if role != "" {
+						sv.Role = role
+					}
+					if v.BridgeDown {
+						sv.BridgeState = "Down"
+					}
+					if v.ConfigSync != "" {
+						sv.ConfigSync = v.ConfigSync
+					}
+					if sv.Role == "active" {
+						sv.ReplicationBacklog = v.ReplicationBacklog
+					}
+				})
+			}
+		}
+	}
+}
+
+// brokersOf resolves a broker name or a site name.
+func brokersOf(kit *simulator.Kit, name string) ([]*simulator.Broker, error) {
+	if m1, m2, err := kit.Mates(name); err == nil {
+		return []*simulator.Broker{m1, m2}, nil
+	}
+	b, err := kit.Broker(name)
+	if err != nil {
+		return nil, err
+	}
+	return []*simulator.Broker{b}, nil
+}
+
+func applyEvent(kit *simulator.Kit, e Event) error {
+	switch {
+	case e.Outage != "" || e.Restore != "":
+		name, down := e.Outage, true
+		if name == "" {
+			name, down = e.Restore, false
+		}
+		brokers, err := brokersOf(kit, name)
+		if err != nil {
+			return err
+		}
+		for _, b := range brokers {
+			b.SetDown(down)
+		}
+		return nil
+	case e.MateFailover != "":
+		return kit.MateFailover(e.MateFailover)
+	default:
+		return injectFault(kit, *e.Fault)
+	}
+}
+
+// stepDefaults maps failover steps to the requests they make and the broker
+// serving them by default.
+var stepDefaults = map[string]struct {
+	broker, method, path string
+}{
+	"status":    {"", http.MethodPost, "/SEMP"},
+	"discovery": {"", http.MethodGet, "/SEMP/v2/monitor/msgVpns"},
+	"demote":    {"dc1-mate1", http.MethodPatch, "/SEMP/v2/config/msgVpns/%s"},
+	"drain":     {"dc1-mate1", http.MethodGet, "/SEMP/v2/monitor/msgVpns/%s/queues"},
+	"promote":   {"dc2-mate1", http.MethodPatch, "/SEMP/v2/config/msgVpns/%s"},
+}
+
+func injectFault(kit *simulator.Kit, f FaultSpec) error {
+	fault := simulator.Fault{
+		Method:     f.Method,
+		PathPrefix: f.Path,
+		Status:     f.Status,
+		Reason:     f.Reason,
+		Times:      f.Times,
+	}
+	broker := f.Broker
+	if d, ok := stepDefaults[f.Step]; ok {
+		if broker == "" {
+			broker = d.broker
+		}
+		if fault.Method == "" {
+			fault.Method = d.method
+		}
+		if fault.PathPrefix == "" {
+			fault.PathPrefix = d.path
+			if strings.Contains(d.path, "%s") {
+				fault.PathPrefix = fmt.Sprintf(d.path, url.PathEscape(f.VPN))
+			}
+		}
+	}
+	if fault.Reason == "" {
+		fault.Reason = "injected by chaos scenario"
+	}
+	if broker == "" {
+		return fmt.Errorf("fault needs a broker")
+	}
+	brokers, err := brokersOf(kit, broker)
+	if err != nil {
+		return err
+	}
+	for _, b := range brokers {
+		b.InjectFault(fault)
+	}
+	return nil
+}
+
+// fakeDNS holds the scenario records of every resolver.
+type fakeDNS struct {
+	mu sync.Mutex
+	// records by resolver, then FQDN
+	records map[string]map[string]string
+}
+
+func newFakeDNS(resolvers []string) *fakeDNS {
+	d := &fakeDNS{records: make(map[string]map[string]string)}
+	for _, r := range resolvers {
+		d.records[r] = make(map[string]string)
+	}
+	return d
+}
+
+func (d *fakeDNS) set(vpn string, r DNSRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for resolver, records := range d.records {
+		if r.Resolver != "" && r.Resolver != resolver {
+			continue
+		}
+		records[vpn+config.VPN_PRIMARY_BROKER_FQDN_GLOBAL_DOMAIN] = r.A
+		records[vpn+config.VPN_BACKUP_BROKER_FQDN_GLOBAL_DOMAIN] = r.B
+	}
+}
+
+func (d *fakeDNS) resolvers() map[string]activities.DNSResolver {
+	out := make(map[string]activities.DNSResolver)
+	for name := range d.records {
+		out[name] = fakeResolver{dns: d, name: name}
+	}
+	return out
+}
+
+// fakeResolver is one resolver of a fakeDNS.
+type fakeResolver struct {
+	dns  *fakeDNS
+	name string
+}
+
+func (r fakeResolver) Resolve(_ context.Context, name string) (*models.DNSRecordSet, error) {
+	r.dns.mu.Lock()
+	defer r.dns.mu.Unlock()
+	ip := r.dns.records[r.name][name]
+	if ip == "" {
+		return nil, fmt.Errorf("lookup %s: %w", name, activities.ErrNoSuchHost)
+	}
+	return &models.DNSRecordSet{Name: name, Addresses: []string{ip}, Source: r.name}, nil
+}
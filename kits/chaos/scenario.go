@@ -0,0 +1,263 @@
+// Package chaos runs declarative DR drills: a YAML scenario sets up a
+// simulated kit, drives DNS records, broker outages and SEMP failures over
+// time, runs the real DR workflows against it and checks the final VPN roles
+// and the run report.
+package chaos
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a DR drill.
+//
+//	name: active-site-outage
+//	vpns:
+//	  orders: {}                   # active on dc1, standby on dc2
+//	dns:
+//	  orders:
+//	    - {at: 0s, a: 10.10.1.100, b: 10.10.1.101}
+//	    - {at: 1m, a: 10.20.1.100, b: 10.20.1.101}
+//	events:
+//	  - {at: 0s, outage: dc1}
+//	expect:
+//	  status: PARTIAL
+//	  roles:
+//	    orders: {dc2: active}
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+
+	// Kit is the kit name used in kits.yaml, kit-drill by default.
+	Kit  string              `yaml:"kit,omitempty"`
+	VPNs map[string]VPNSetup `yaml:"vpns"`
+
+	// DNS lists the records of the VPN FQDNs over time, per VPN. A VPN without
+	// records does not resolve.
+	DNS map[string][]DNSRecord `yaml:"dns,omitempty"`
+	// Resolvers names the DNS resolvers asked by the watcher quorum, a single
+	// one by default.
+	Resolvers []string `yaml:"resolvers,omitempty"`
+	// Sites are the VPN addresses of each DC written to kits.yaml,
+	// 10.10.0.0/16 for dc1 and 10.20.0.0/16 for dc2 by default. An empty
+	// list leaves the DC without addresses.
+	Sites map[string][]string `yaml:"sites,omitempty"`
+
+	Events []Event `yaml:"events,omitempty"`
+
+	Options Options `yaml:"options,omitempty"`
+
+	// RunFor bounds the simulated duration of the run, 30m by default. A run
+	// still going by then is canceled.
+	RunFor time.Duration `yaml:"runFor,omitempty"`
+
+	Expect Expectations `yaml:"expect"`
+}
+
+// VPNSetup is the initial state of a VPN on both sites.
+type VPNSetup struct {
+	// DC1 and DC2 are the replication roles, active and standby by default.
+	DC1 string `yaml:"dc1,omitempty"`
+	DC2 string `yaml:"dc2,omitempty"`
+
+	// ReplicationBacklog is the number of drain polls for which replication
+	// is still in progress after the VPN was demoted.
+	ReplicationBacklog int    `yaml:"replicationBacklog,omitempty"`
+	BridgeDown         bool   `yaml:"bridgeDown,omitempty"`
+	ConfigSync         string `yaml:"configSync,omitempty"`
+}
+
+// DNSRecord sets the primary (a) and backup (b) broker records of a VPN from
+// At on, on every resolver or only on Resolver.
+type DNSRecord struct {
+	At       time.Duration `yaml:"at"`
+	A        string        `yaml:"a"`
+	B        string        `yaml:"b"`
+	Resolver string        `yaml:"resolver,omitempty"`
+}
+
+// Event changes the simulated kit at a point in time. Exactly one action is
+// set. Outage and Restore accept a broker (dc1-mate1) or a whole site (dc1).
+type Event struct {
+	At time.Duration `yaml:"at"`
+
+	Outage       string     `yaml:"outage,omitempty"`
+	Restore      string     `yaml:"restore,omitempty"`
+	MateFailover string     `yaml:"mateFailover,omitempty"`
+	Fault        *FaultSpec `yaml:"fault,omitempty"`
+}
+
+// FaultSpec fails SEMP requests of a broker. Step selects the requests of a
+// failover step for a VPN; Method and Path select them explicitly.
+type FaultSpec struct {
+	Broker string `yaml:"broker,omitempty"`
+	// Step is one of status (SEMP v1), discovery, demote, drain, promote.
+	// demote defaults to dc1-mate1 and promote to dc2-mate1.
+	Step   string `yaml:"step,omitempty"`
+	VPN    string `yaml:"vpn,omitempty"`
+	Method string `yaml:"method,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	// Status is the HTTP status, 0 answers SEMP v1 with a failed
+	// execute-result.
+	Status int    `yaml:"status,omitempty"`
+	Reason string `yaml:"reason,omitempty"`
+	Times  int    `yaml:"times,omitempty"`
+}
+
+// Options are passed to the workflow input.
+type Options struct {
+	ForceFailover    bool     `yaml:"forceFailover,omitempty"`
+	FailureThreshold *float64 `yaml:"failureThreshold,omitempty"`
+	DNSQuorum        int      `yaml:"dnsQuorum,omitempty"`
+	DNSStablePolls   int      `yaml:"dnsStablePolls,omitempty"`
+}
+
+// Expectations are checked against the run report. Unset fields are not
+// checked.
+type Expectations struct {
+	// Status is the run status (SUCCESS, PARTIAL, FAILED) or CANCELED when
+	// the run did not finish within runFor.
+	Status string `yaml:"status,omitempty"`
+	// ErrorContains must be part of the workflow error.
+	ErrorContains string `yaml:"errorContains,omitempty"`
+	// Roles are the final replication roles per VPN and site (dc1, dc2) on
+	// the active mate of the site, or per broker (dc1-mate2).
+	Roles map[string]map[string]string `yaml:"roles,omitempty"`
+	// Mates are the reported mate statuses per broker (dc1-mate1: DOWN).
+	Mates map[string]string `yaml:"mates,omitempty"`
+	// Failovers are the reported failovers per VPN.
+	Failovers map[string]FailoverExpectation `yaml:"failovers,omitempty"`
+}
+
+// FailoverExpectation checks the failover report of a VPN.
+type FailoverExpectation struct {
+	// Reported false asserts that no failover was reported for the VPN.
+	Reported    *bool `yaml:"reported,omitempty"`
+	PreflightGo *bool `yaml:"preflightGo,omitempty"`
+	Overridden  *bool `yaml:"overridden,omitempty"`
+	// ActiveSkipped asserts whether a forced failover skipped the demotion
+	// of an unreachable active site.
+	ActiveSkipped *bool `yaml:"activeSkipped,omitempty"`
+	// ConfigChanges is the total number of config changes across sites.
+	ConfigChanges *int `yaml:"configChanges,omitempty"`
+	// DNSStablePolls is the number of polls the DNS change was seen before
+	// the failover.
+	DNSStablePolls *int `yaml:"dnsStablePolls,omitempty"`
+}
+
+// LoadScenario reads and validates a scenario file.
+func LoadScenario(path string) (Scenario, error) {
+	var s Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.Validate(); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+var (
+	roles = map[string]bool{"active": true, "standby": true}
+	steps = map[string]bool{"status": true, "discovery": true, "demote": true, "drain": true, "promote": true}
+)
+
+// Validate reports the first problem of the scenario.
+func (s *Scenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario name is required")
+	}
+	if len(s.VPNs) == 0 {
+		return fmt.Errorf("scenario %s: at least one vpn is required", s.Name)
+	}
+	for vpn, v := range s.VPNs {
+		for _, r := range []string{v.DC1, v.DC2} {
+			if r != "" && !roles[r] {
+				return fmt.Errorf("vpn %s: invalid role %q", vpn, r)
+			}
+		}
+	}
+	for dc := range s.Sites {
+		if dc != "dc1" && dc != "dc2" {
+			return fmt.Errorf("sites: unknown dc %q", dc)
+		}
+	}
+	resolvers := make(map[string]bool)
+	for _, r := range s.resolverNames() {
+		resolvers[r] = true
+	}
+	for vpn, records := range s.DNS {
+		if _, ok := s.VPNs[vpn]; !ok {
+			return fmt.Errorf("dns: unknown vpn %q", vpn)
+		}
+		for _, r := range records {
+			if r.Resolver != "" && !resolvers[r.Resolver] {
+				return fmt.Errorf("dns: vpn %s: unknown resolver %q", vpn, r.Resolver)
+			}
+		}
+	}
+	for i, e := range s.Events {
+		n := 0
+		for _, set := range []bool{e.Outage != "", e.Restore != "", e.MateFailover != "", e.Fault != nil} {
+			if set {
+				n++
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("event %d: exactly one of outage, restore, mateFailover, fault is required", i)
+		}
+		if f := e.Fault; f != nil {
+			if f.Step != "" && !steps[f.Step] {
+				return fmt.Errorf("event %d: unknown step %q", i, f.Step)
+			}
+			if f.Step == "" && f.Path == "" {
+				return fmt.Errorf("event %d: fault needs a step or a path", i)
+			}
+			if f.Step != "" && f.Step != "status" && f.Step != "discovery" && f.VPN == "" {
+				return fmt.Errorf("event %d: fault step %s needs a vpn", i, f.Step)
+			}
+		}
+	}
+	return nil
+}
+
+// vpnNames returns the scenario VPNs in a stable order.
+func (s *Scenario) vpnNames() []string {
+	names := make([]string, 0, len(s.VPNs))
+	for name := range s.VPNs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolverNames returns the resolvers of the quorum.
+func (s *Scenario) resolverNames() []string {
+	if len(s.Resolvers) == 0 {
+		return []string{"default"}
+	}
+	return s.Resolvers
+}
+
+// defaultSites are the VPN addresses of the DCs unless the scenario sets
+// them.
+var defaultSites = map[string][]string{"dc1": {"10.10.0.0/16"}, "dc2": {"10.20.0.0/16"}}
+
+// siteAddresses returns the VPN addresses of a DC.
+func (s *Scenario) siteAddresses(dc string) []string {
+	if addrs, ok := s.Sites[dc]; ok {
+		return addrs
+	}
+	return defaultSites[dc]
+}
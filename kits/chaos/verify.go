@@ -0,0 +1,108 @@
+package chaos
+
+import (
+	"fmt"
+	"kits-worker/kits/models"
+	"sort"
+	"strings"
+)
+
+// Verify checks the report against the scenario expectations and returns
+// the mismatches, sorted.
+func Verify(s Scenario, r *Report) []string {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	exp := s.Expect
+
+	if exp.Status != "" && r.Status != exp.Status {
+		fail("status: expected %s, got %s (%s)", exp.Status, r.Status, r.Error)
+	}
+	if exp.ErrorContains != "" && !strings.Contains(r.Error, exp.ErrorContains) {
+		fail("error: expected to contain %q, got %q", exp.ErrorContains, r.Error)
+	}
+
+	for vpn, want := range exp.Roles {
+		for where, role := range want {
+			broker := where
+			if active, ok := r.ActiveMates[where]; ok {
+				broker = active
+			}
+			got, ok := r.Roles[vpn][broker]
+			if !ok {
+				fail("roles: unknown vpn or broker %s/%s", vpn, where)
+				continue
+			}
+			if got != role {
+				fail("roles: vpn %s on %s (%s): expected %s, got %s", vpn, where, broker, role, got)
+			}
+		}
+	}
+
+	var outcomeMates map[string]string
+	failovers := make(map[string]models.VPNFailoverResult)
+	if r.Result != nil {
+		outcomeMates = make(map[string]string)
+		for _, k := range r.Result.Results {
+			for _, m := range k.Mates {
+				outcomeMates[m.DC+"-"+m.Mate] = string(m.Status)
+			}
+			for _, f := range k.Failovers {
+				failovers[f.VPN] = f
+			}
+		}
+	}
+
+	for broker, want := range exp.Mates {
+		if outcomeMates == nil {
+			fail("mates: no run result to check %s", broker)
+			continue
+		}
+		if got := outcomeMates[broker]; got != want {
+			fail("mates: %s: expected %s, got %s", broker, want, got)
+		}
+	}
+
+	for vpn, want := range exp.Failovers {
+		f, reported := failovers[vpn]
+		if want.Reported != nil && *want.Reported != reported {
+			fail("failovers: vpn %s: expected reported=%v", vpn, *want.Reported)
+			continue
+		}
+		if !reported {
+			if want.PreflightGo != nil || want.Overridden != nil || want.ActiveSkipped != nil || want.ConfigChanges != nil || want.DNSStablePolls != nil {
+				fail("failovers: vpn %s: no failover reported", vpn)
+			}
+			continue
+		}
+		if want.PreflightGo != nil && f.Preflight.Go != *want.PreflightGo {
+			fail("failovers: vpn %s: expected preflight go=%v, got %v %v", vpn, *want.PreflightGo, f.Preflight.Go, f.Preflight.Reasons)
+		}
+		if want.Overridden != nil && f.Preflight.Overridden != *want.Overridden {
+			fail("failovers: vpn %s: expected overridden=%v", vpn, *want.Overridden)
+		}
+		if want.ActiveSkipped != nil && f.ActiveSkipped != *want.ActiveSkipped {
+			fail("failovers: vpn %s: expected activeSkipped=%v", vpn, *want.ActiveSkipped)
+		}
+		if want.ConfigChanges != nil {
+			n := 0
+			for _, d := range f.ConfigDiffs {
+				n += len(d.Changes)
+			}
+			if n != *want.ConfigChanges {
+				fail("failovers: vpn %s: expected %d config changes, got %d", vpn, *want.ConfigChanges, n)
+			}
+		}
+		if want.DNSStablePolls != nil {
+			if f.DNSTrigger == nil {
+				fail("failovers: vpn %s: no DNS trigger reported", vpn)
+			} else if f.DNSTrigger.StablePolls != *want.DNSStablePolls {
+				fail("failovers: vpn %s: expected a DNS change stable for %d polls, got %d", vpn, *want.DNSStablePolls, f.DNSTrigger.StablePolls)
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,17 @@
+package config
+
+const (
+	// env var setting the JSONL file broker mutations are appended to,
+	// required by the worker. Every worker of the task queue must append to
+	// the same file on shared storage (ie an NFS volume), so that an audit
+	// query sees the mutations of all of them.
+	AUDIT_LOG_ENV = "KITS_AUDIT_LOG"
+
+	// task queue the audit queries run on. Only workers reading the shared
+	// audit log poll it.
+	AUDIT_TASK_QUEUE = "KITS_AUDIT_TASK_QUEUE"
+
+	// default audit log of tests and simulator runs, relative to
+	// os.TempDir(); the worker refuses to start without AUDIT_LOG_ENV.
+	AUDIT_LOG_DEFAULT_FILE = "kits-audit/audit.jsonl"
+)
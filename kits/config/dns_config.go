@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+const (
+	// env var listing the DNS servers queried directly (host[:port], comma
+	// separated), ie the authoritative or GSLB servers of the broker FQDNs.
+	// Unset uses the system resolver.
+	DNS_SERVERS_ENV = "KITS_DNS_SERVERS"
+
+	// env var naming the resolvers the DNS watcher quorum asks, ie one per DC:
+	// "dc1=10.10.0.53,10.10.0.54;dc2=10.20.0.53". Unset asks the resolver of
+	// KITS_DNS_SERVERS alone.
+	DNS_RESOLVERS_ENV = "KITS_DNS_RESOLVERS"
+
+	// env var listing the record types resolved (A, AAAA, comma separated).
+	DNS_RECORD_TYPES_ENV = "KITS_DNS_RECORD_TYPES"
+
+	// hosts file checked before DNS, empty to skip it
+	DNS_HOSTS_FILE_ENV     = "KITS_DNS_HOSTS_FILE"
+	DNS_HOSTS_FILE_DEFAULT = "/etc/hosts"
+
+	// Timeout of a single query to a DNS server.
+	DNS_QUERY_TIMEOUT = 3 * time.Second
+
+	// Longest CNAME chain followed.
+	DNS_MAX_CNAME_CHAIN = 8
+
+	// The DNS watcher polls at the TTL of the records, within these bounds.
+	DNS_WATCH_MIN_INTERVAL = 10 * time.Second
+	DNS_WATCH_MAX_INTERVAL = 60 * time.Second
+)
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// Defaults of the DR policy, overridable per run and on running DNS watchers.
+const (
+	// Activities of the DR run and of each kit.
+	DR_ACTIVITY_TIMEOUT = time.Minute
+
+	// DNS resolutions of the watchers.
+	DNS_WATCH_ACTIVITY_TIMEOUT      = 15 * time.Second
+	DNS_WATCH_ACTIVITY_MAX_ATTEMPTS = 5
+
+	// Steps of a VPN failover.
+	FAILOVER_ACTIVITY_TIMEOUT      = 30 * time.Second
+	FAILOVER_ACTIVITY_MAX_ATTEMPTS = 3
+
+	// Wait for the replication queue of the old active VPN to drain: 12
+	// checks 5s apart.
+	FAILOVER_DRAIN_TIMEOUT        = 70 * time.Second
+	FAILOVER_DRAIN_MAX_ATTEMPTS   = 12
+	FAILOVER_DRAIN_RETRY_INTERVAL = 5 * time.Second
+)
@@ -0,0 +1,7 @@
+package config
+
+const (
+	// Failover is refused while a standby queue uses more than this percentage of its spool quota,
+	// promoting it would reject publishers as soon as the VPN becomes active.
+	PREFLIGHT_MAX_QUEUE_SPOOL_USAGE_PCT = 90.0
+)
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+const (
+	// Canary messages are published on <prefix><vpn>/<id>; the probe queue must subscribe to <prefix>>.
+	PROBE_TOPIC_PREFIX = "kits/dr/canary/"
+
+	// Default Solace REST messaging port of the brokers.
+	PROBE_DEFAULT_REST_PORT = 9000
+
+	// How long the probe waits for the canary to be consumed.
+	PROBE_TIMEOUT = 10 * time.Second
+
+	// How often the REST transport browses the probe queue for the canary.
+	PROBE_POLL_INTERVAL = 500 * time.Millisecond
+)
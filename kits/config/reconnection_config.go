@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+const (
+	// How long clients are given to reconnect to the new active site after a failover.
+	RECONNECTION_CHECK_DEFAULT_WINDOW = 5 * time.Minute
+	// How often client connections and queue binds are polled during the window.
+	RECONNECTION_CHECK_DEFAULT_INTERVAL = 15 * time.Second
+)
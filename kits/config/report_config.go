@@ -0,0 +1,9 @@
+package config
+
+const (
+	// env var overriding the directory where reports (ie fleet inventory) are exported
+	REPORT_DIR_ENV = "KITS_REPORT_DIR"
+
+	// default report directory, relative to os.TempDir()
+	REPORT_DEFAULT_DIR = "kits-reports"
+)
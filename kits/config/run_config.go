@@ -0,0 +1,6 @@
+package config
+
+const (
+	// Default fraction of kits allowed to fail before a multi-kit run is reported FAILED instead of PARTIAL.
+	DEFAULT_FAILURE_THRESHOLD = 0.5
+)
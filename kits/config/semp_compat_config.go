@@ -0,0 +1,12 @@
+package config
+
+const (
+	// Minimum SEMP v2 API version (as reported by /SEMP/v2/monitor/about/api) per operation.
+	// discovery relies on where= filters on replicationEnabled/replicationRole,
+	// failover additionally PATCHes replicationRole on msgVpns.
+	SEMP_MIN_VERSION_DISCOVERY = "2.12"
+	SEMP_MIN_VERSION_FAILOVER  = "2.12"
+
+	// Comma separated list of platforms DR operations are allowed against.
+	SEMP_SUPPORTED_PLATFORMS = "VMR,Appliance"
+)
@@ -0,0 +1,13 @@
+package config
+
+const (
+	// env var setting the directory VPN config snapshots are stored in,
+	// required by the worker. It must be shared storage mounted at the same
+	// path on every worker: the diff of two snapshots runs on whichever
+	// worker picks it up.
+	SNAPSHOT_DIR_ENV = "KITS_SNAPSHOT_DIR"
+
+	// default snapshot directory of tests and simulator runs, relative to
+	// os.TempDir(); the worker refuses to start without SNAPSHOT_DIR_ENV.
+	SNAPSHOT_DEFAULT_DIR = "kits-snapshots"
+)
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+const (
+	// env var overriding the local topology cache directory
+	TOPOLOGY_CACHE_DIR_ENV = "KITS_TOPOLOGY_CACHE_DIR"
+
+	// default cache directory, relative to os.TempDir()
+	TOPOLOGY_CACHE_DEFAULT_DIR = "kits-topology-cache"
+
+	// how often a running KitsDRWorkflow checks whether the topology changed
+	TOPOLOGY_CHECK_INTERVAL = 10 * time.Minute
+)
@@ -0,0 +1,10 @@
+package config
+
+const (
+	// env var pointing at a file of trusted ed25519 public keys (one base64 key or PEM block per entry)
+	// used to verify detached signatures of the kits topology artifact
+	TOPOLOGY_PUBLIC_KEYS_ENV = "KITS_TOPOLOGY_PUBLIC_KEYS"
+
+	TOPOLOGY_SHA256_SIDECAR_SUFFIX    = ".sha256"
+	TOPOLOGY_SIGNATURE_SIDECAR_SUFFIX = ".sig"
+)
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+const (
+	// How long a VPN failover waits for the failover of the same VPN in
+	// flight elsewhere before giving up.
+	VPN_LOCK_WAIT_TIMEOUT = 10 * time.Minute
+
+	// A VPN lock neither released nor renewed within the lease is taken
+	// back, ie from a failover that was terminated.
+	VPN_LOCK_LEASE = 30 * time.Minute
+
+	// How often the failover holding a VPN lock renews its lease, so that a
+	// failover outlasting the lease, ie on a slow drain, keeps the lock.
+	VPN_LOCK_RENEW_INTERVAL = 5 * time.Minute
+)
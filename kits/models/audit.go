@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// AuditRecord is one broker mutation (SEMP write) performed by the worker.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	VPN  string `json:"vpn"`
+
+	// SEMPUser is the broker user the write was performed with.
+	SEMPUser string `json:"sempUser"`
+
+	WorkflowType string `json:"workflowType,omitempty"`
+	WorkflowID   string `json:"workflowId,omitempty"`
+	RunID        string `json:"runId,omitempty"`
+	ActivityType string `json:"activityType,omitempty"`
+	Attempt      int32  `json:"attempt,omitempty"`
+
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	RequestBody  string `json:"requestBody,omitempty"`
+	StatusCode   int    `json:"statusCode,omitempty"`
+	ResponseBody string `json:"responseBody,omitempty"`
+
+	RoleBefore string `json:"roleBefore,omitempty"`
+	RoleAfter  string `json:"roleAfter,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// AuditQuery selects audit records. Empty fields match everything.
+type AuditQuery struct {
+	VPN  string    `json:"vpn,omitempty"`
+	From time.Time `json:"from,omitempty"`
+	To   time.Time `json:"to,omitempty"`
+}
+
+// Matches reports whether the record is selected by the query; From is
+// inclusive and To exclusive.
+func (q AuditQuery) Matches(r AuditRecord) bool {
+	if q.VPN != "" && r.VPN != q.VPN {
+		return false
+	}
+	if !q.From.IsZero() && r.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !r.Time.Before(q.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package models
+
+// BrokerInventory is the inventory record of a single mate.
+type BrokerInventory struct {
+	AboutResult
+
+	RedundancyMode        string `json:"redundancyMode,omitempty"`
+	RedundancyStatus      string `json:"redundancyStatus,omitempty"`
+	ActiveStandbyRole     string `json:"activeStandbyRole,omitempty"`
+	ConfigSyncAdminStatus string `json:"configSyncAdminStatus,omitempty"`
+	ConfigSyncOperStatus  string `json:"configSyncOperStatus,omitempty"`
+
+	VPNCount        int `json:"vpnCount"`
+	ActiveVPNCount  int `json:"activeVpnCount"`
+	StandbyVPNCount int `json:"standbyVpnCount"`
+
+	// Error is set when the mate, or its VPN count, could not be inventoried.
+	Error string `json:"error,omitempty"`
+}
+
+type KitInventory struct {
+	Kit          string            `json:"kit"`
+	SuccessCount int               `json:"successCount"`
+	TotalCount   int               `json:"totalCount"`
+	Mates        []BrokerInventory `json:"mates"`
+}
+
+type FleetInventory struct {
+	Topology     TopologyVersion `json:"topology"`
+	Kits         []KitInventory  `json:"kits"`
+	SuccessCount int             `json:"successCount"`
+	TotalCount   int             `json:"totalCount"`
+
+	// Exported report files, written on the worker.
+	JSONPath string `json:"jsonPath,omitempty"`
+	CSVPath  string `json:"csvPath,omitempty"`
+}
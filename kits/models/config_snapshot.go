@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Config snapshot object kinds.
+const (
+	ConfigKindMsgVpn        = "msgVpn"
+	ConfigKindQueue         = "queue"
+	ConfigKindClientProfile = "clientProfile"
+	ConfigKindACLProfile    = "aclProfile"
+)
+
+// VPNConfigSnapshot is the SEMP v2 configuration of a message VPN on one
+// broker: Objects[kind][name] holds the attributes of each object.
+type VPNConfigSnapshot struct {
+	VPN     string                                       `json:"vpn"`
+	Host    string                                       `json:"host"`
+	Port    int                                          `json:"port"`
+	DC      string                                       `json:"dc"`
+	Phase   string                                       `json:"phase"`
+	TakenAt time.Time                                    `json:"takenAt"`
+	Objects map[string]map[string]map[string]interface{} `json:"objects"`
+}
+
+// ConfigSnapshotRef describes a snapshot stored at Path in the snapshot
+// directory shared by the workers.
+type ConfigSnapshotRef struct {
+	VPN     string    `json:"vpn"`
+	Host    string    `json:"host"`
+	Port    int       `json:"port"`
+	DC      string    `json:"dc"`
+	Phase   string    `json:"phase"`
+	TakenAt time.Time `json:"takenAt"`
+	Path    string    `json:"path"`
+	Objects int       `json:"objects"`
+	// Error is set when the snapshot could not be taken.
+	Error string `json:"error,omitempty"`
+}
+
+// ConfigChange is a single difference between two snapshots. Field is empty
+// when a whole object was added or removed.
+type ConfigChange struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Change string      `json:"change"` // added, removed, changed
+	Field  string      `json:"field,omitempty"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// ConfigDiff lists what changed on one broker across a failover.
+type ConfigDiff struct {
+	VPN     string         `json:"vpn"`
+	Host    string         `json:"host"`
+	DC      string         `json:"dc"`
+	Before  string         `json:"before"`
+	After   string         `json:"after"`
+	Changes []ConfigChange `json:"changes"`
+	// Error is set when a snapshot or the diff of the broker failed, the
+	// changes are then unknown.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// ControlActionType is an operator action on a running DR workflow.
+type ControlActionType string
+
+const (
+	// ControlPause stops acting on DNS changes, ie during a maintenance
+	// window; the records are still polled.
+	ControlPause ControlActionType = "pause"
+	// ControlResume acts on DNS changes again from the next poll; a change
+	// still pending must be seen stable anew.
+	ControlResume ControlActionType = "resume"
+	// ControlForceFailover fails the VPN over without waiting for DNS.
+	ControlForceFailover ControlActionType = "forceFailover"
+	// ControlExcludeVPN stops watching the VPN, it is not failed over.
+	ControlExcludeVPN ControlActionType = "excludeVPN"
+	// ControlApproveFailover releases a failover held by a maintenance
+	// window in approval mode.
+	ControlApproveFailover ControlActionType = "approveFailover"
+)
+
+// ControlRequest is the argument of the control updates. VPN selects the
+// VPN of a kit, every VPN of the kit if empty; a watcher only accepts its
+// own VPN.
+type ControlRequest struct {
+	VPN    string `json:"vpn,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ControlAction is an accepted operator action.
+type ControlAction struct {
+	// ID numbers the actions of a kit workflow, its watchers report the
+	// outcome of each under this ID.
+	ID     int               `json:"id,omitempty"`
+	Action ControlActionType `json:"action"`
+	VPN    string            `json:"vpn,omitempty"`
+	Reason string            `json:"reason,omitempty"`
+	At     time.Time         `json:"at"`
+}
+
+// WatcherState is the state of a DNS watcher as seen by its queries.
+type WatcherState struct {
+	VPN    string `json:"vpn"`
+	Paused bool   `json:"paused"`
+	// Forced is set once a failover was forced, Excluded once the VPN was
+	// excluded.
+	Forced   bool `json:"forced"`
+	Excluded bool `json:"excluded"`
+	// FailoverStarted is set once the watcher started the failover, which
+	// can no longer be stopped.
+	FailoverStarted bool `json:"failoverStarted"`
+	// Maintenance is the maintenance window in force at the last poll, and
+	// AwaitingApproval set while it holds a failover.
+	Maintenance      string          `json:"maintenance,omitempty"`
+	AwaitingApproval bool            `json:"awaitingApproval"`
+	Policy           DRPolicy        `json:"policy"`
+	Actions          []ControlAction `json:"actions,omitempty"`
+}
+
+// KitControlState is the state of a kit workflow as seen by its queries.
+type KitControlState struct {
+	Kit string `json:"kit"`
+	// Paused is set while every VPN of the kit is paused.
+	Paused bool `json:"paused"`
+	// Watchers are the VPNs with a DNS watcher, Excluded the VPNs excluded
+	// by an operator.
+	Watchers []string        `json:"watchers,omitempty"`
+	Excluded []string        `json:"excluded,omitempty"`
+	Actions  []ControlAction `json:"actions,omitempty"`
+	// Outcomes tell which watchers applied the actions.
+	Outcomes []ControlOutcome `json:"outcomes,omitempty"`
+}
+
+// ControlOutcome is the outcome of an action a kit workflow forwarded to the
+// watcher of a VPN.
+type ControlOutcome struct {
+	ID     int               `json:"id"`
+	VPN    string            `json:"vpn"`
+	Action ControlActionType `json:"action"`
+	// Error tells why the watcher did not apply the action, empty once
+	// applied.
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// DNSRecordType is a DNS record type the resolver asks for.
+type DNSRecordType string
+
+const (
+	DNSRecordA    DNSRecordType = "A"
+	DNSRecordAAAA DNSRecordType = "AAAA"
+)
+
+// DNSRecordSet is the resolved address set of a name.
+type DNSRecordSet struct {
+	Name string `json:"name"`
+	// CNAMEs is the alias chain followed from Name, in order; the last entry
+	// owns the addresses.
+	CNAMEs []string `json:"cnames,omitempty"`
+	// Addresses are the A and AAAA records, sorted, IPv4 first.
+	Addresses []string `json:"addresses"`
+	// TTL is the smallest TTL of the chain and the addresses in seconds, 0
+	// when the source has none (hosts file, system resolver).
+	TTL uint32 `json:"ttl"`
+	// Source is the DNS server that answered, "hosts" or "system".
+	Source string `json:"source"`
+}
+
+// Canonical is the name owning the addresses.
+func (s DNSRecordSet) Canonical() string {
+	if len(s.CNAMEs) == 0 {
+		return s.Name
+	}
+	return s.CNAMEs[len(s.CNAMEs)-1]
+}
+
+// SameAddresses reports whether both sets hold the same addresses. Sets are
+// compared as resolved, the CNAME chain and TTL are ignored.
+func (s DNSRecordSet) SameAddresses(o DNSRecordSet) bool {
+	if len(s.Addresses) != len(o.Addresses) {
+		return false
+	}
+	for i := range s.Addresses {
+		if s.Addresses[i] != o.Addresses[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s DNSRecordSet) String() string {
+	return strings.Join(s.Addresses, ",")
+}
+
+// DNSQuorumOptions decide when the DNS watcher considers the VPN records
+// changed.
+type DNSQuorumOptions struct {
+	// Quorum is the number of resolvers that must return the same record
+	// set, a majority of the resolvers configured on the worker if 0.
+	Quorum int `json:"quorum,omitempty"`
+	// StablePolls is the number of consecutive polls the changed records
+	// must be seen before the failover starts, 1 if 0.
+	StablePolls int `json:"stablePolls,omitempty"`
+}
+
+// DNSResolverAnswer is the answer of one resolver of the quorum.
+type DNSResolverAnswer struct {
+	Resolver string        `json:"resolver"`
+	Set      *DNSRecordSet `json:"set,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// DNSQuorumAnswer is what the resolvers of the quorum returned for a name.
+type DNSQuorumAnswer struct {
+	Name    string              `json:"name"`
+	Answers []DNSResolverAnswer `json:"answers"`
+	// Quorum is the number of votes required and Votes the votes of the most
+	// returned set.
+	Quorum int `json:"quorum"`
+	Votes  int `json:"votes"`
+	// Agreed is the set returned by at least Quorum resolvers, nil without
+	// agreement.
+	Agreed *DNSRecordSet `json:"agreed,omitempty"`
+}
+
+// DNSTrigger is the DNS change that started a failover.
+type DNSTrigger struct {
+	A DNSQuorumAnswer `json:"a"`
+	B DNSQuorumAnswer `json:"b"`
+	// Site is the DC both records pointed to, empty when the records were
+	// only seen changing.
+	Site string `json:"site,omitempty"`
+	// StablePolls is the number of consecutive polls the change was seen.
+	StablePolls int `json:"stablePolls"`
+}
+
+// ParseSiteAddress parses a VPN address of a DC, an IP or a CIDR prefix.
+func ParseSiteAddress(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// SiteOf returns the DC every address of the set belongs to, or "" when an
+// address belongs to no DC or the addresses span several DCs.
+func SiteOf(set DNSRecordSet, sites map[string][]string) string {
+	dcs := make([]string, 0, len(sites))
+	for dc := range sites {
+		dcs = append(dcs, dc)
+	}
+	sort.Strings(dcs)
+
+	site := ""
+	for _, a := range set.Addresses {
+		addr, err := netip.ParseAddr(a)
+		if err != nil {
+			return ""
+		}
+		owner := ""
+		for _, dc := range dcs {
+			for _, s := range sites[dc] {
+				if p, err := ParseSiteAddress(s); err == nil && p.Contains(addr) {
+					if owner != "" && owner != dc {
+						return ""
+					}
+					owner = dc
+				}
+			}
+		}
+		if owner == "" || site != "" && site != owner {
+			return ""
+		}
+		site = owner
+	}
+	return site
+}
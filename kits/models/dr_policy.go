@@ -0,0 +1,30 @@
+package models
+
+// DRPolicy tunes the timeouts, retries and polling intervals of the DR
+// workflows. Zero values fall back to the config defaults.
+type DRPolicy struct {
+	// ActivityTimeoutSeconds bounds the activities of the DR run and of each
+	// kit (topology download, mate checks, VPN discovery).
+	ActivityTimeoutSeconds int `json:"activityTimeoutSeconds,omitempty"`
+
+	// DNSPollMinSeconds and DNSPollMaxSeconds bound the polling interval of
+	// the DNS watchers, which poll at the TTL of the records. Failed or
+	// inconclusive polls are retried after the minimum.
+	DNSPollMinSeconds int `json:"dnsPollMinSeconds,omitempty"`
+	DNSPollMaxSeconds int `json:"dnsPollMaxSeconds,omitempty"`
+	// WatchTimeoutSeconds and WatchMaxAttempts apply to each DNS resolution
+	// of the watchers.
+	WatchTimeoutSeconds int `json:"watchTimeoutSeconds,omitempty"`
+	WatchMaxAttempts    int `json:"watchMaxAttempts,omitempty"`
+
+	// FailoverTimeoutSeconds and FailoverMaxAttempts apply to each step of a
+	// VPN failover.
+	FailoverTimeoutSeconds int `json:"failoverTimeoutSeconds,omitempty"`
+	FailoverMaxAttempts    int `json:"failoverMaxAttempts,omitempty"`
+	// DrainTimeoutSeconds bounds one check of the replication queue of the
+	// old active VPN; it is checked up to DrainMaxAttempts times,
+	// DrainIntervalSeconds apart, before the failover gives up.
+	DrainTimeoutSeconds  int `json:"drainTimeoutSeconds,omitempty"`
+	DrainMaxAttempts     int `json:"drainMaxAttempts,omitempty"`
+	DrainIntervalSeconds int `json:"drainIntervalSeconds,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// PreflightCheck is a single failover pre-flight check against one site.
+type PreflightCheck struct {
+	Name   string `json:"name"`
+	DC     string `json:"dc"`
+	Host   string `json:"host"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// FailoverPreflight is the go/no-go verdict for promoting a standby VPN.
+type FailoverPreflight struct {
+	VPN     string           `json:"vpn"`
+	Go      bool             `json:"go"`
+	Reasons []string         `json:"reasons,omitempty"`
+	Checks  []PreflightCheck `json:"checks"`
+	// Overridden is set when the failover proceeded despite a no-go verdict.
+	Overridden bool `json:"overridden,omitempty"`
+	// ActiveUnreachable is set when the active site is absent or does not
+	// answer SEMP. A forced failover then promotes the standby site without
+	// demoting and draining the active one.
+	ActiveUnreachable bool `json:"activeUnreachable,omitempty"`
+}
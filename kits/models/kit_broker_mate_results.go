@@ -3,8 +3,19 @@ package models
 type MateStatus string
 
 const (
-	Active  MateStatus = "ACTIVE"
+	// Active: a virtual router is Local Active and the message spool is AD-Active.
+	Active MateStatus = "ACTIVE"
+	// Standby: the mate is the active one, this broker is ready to take over.
 	Standby MateStatus = "STANDBY"
+	// ADStandby: a virtual router is Local Active but the message spool is
+	// still AD-Standby, so guaranteed messaging is not served.
+	ADStandby MateStatus = "AD_STANDBY"
+	// Down: the broker answered (or not at all) but neither it nor its mate is active.
+	Down MateStatus = "DOWN"
+	// Unknown: the reply could not be interpreted.
+	Unknown MateStatus = "UNKNOWN"
+	// RedundancyDisabled: standalone broker, redundancy is not configured.
+	RedundancyDisabled MateStatus = "REDUNDANCY_DISABLED"
 )
 
 type MateResult struct {
@@ -14,6 +25,26 @@ type MateResult struct {
 	Host   string
 	Port   int
 	Status MateStatus
+	// Raw redundancy, config-sync and message spool states as reported by the broker.
+	RedundancyStatus string `json:",omitempty"`
+	Activity         string `json:",omitempty"`
+	ConfigSync       string `json:",omitempty"`
+	MessageSpool     string `json:",omitempty"`
+	// Error is set when the mate could not be checked.
+	Error string `json:",omitempty"`
+}
+
+// Serving reports whether the mate serves clients for its site: it is the
+// active broker of its pair, or a standalone broker with an AD-Active spool.
+func (m MateResult) Serving() bool {
+	switch m.Status {
+	case Active:
+		return true
+	case RedundancyDisabled:
+		return m.MessageSpool == "AD-Active"
+	default:
+		return false
+	}
 }
 
 type VPNResult struct {
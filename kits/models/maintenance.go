@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// MaintenanceMode is what a maintenance window does to automated failover.
+type MaintenanceMode string
+
+const (
+	// MaintenanceSuppress ignores DNS changes during the window, as if the
+	// watchers were paused.
+	MaintenanceSuppress MaintenanceMode = "suppress"
+	// MaintenanceApproval holds a failover detected during the window until
+	// an operator approves it.
+	MaintenanceApproval MaintenanceMode = "approval"
+)
+
+// MaintenanceWindow is a planned period, ie a re-IP or broker patching,
+// during which automated failover is suppressed or requires approval. A
+// window is either the explicit range [Start, End) or recurs on Cron for
+// DurationMinutes. Forced failovers are never held.
+type MaintenanceWindow struct {
+	Name string `json:"name"`
+	// Kit and VPN select the kits and VPNs the window applies to, every kit
+	// or VPN if empty.
+	Kit string `json:"kit,omitempty"`
+	VPN string `json:"vpn,omitempty"`
+
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	// Cron is a standard 5 field expression (minute hour day-of-month month
+	// day-of-week) or a descriptor such as @daily, evaluated in TimeZone,
+	// UTC if empty.
+	Cron            string `json:"cron,omitempty"`
+	DurationMinutes int    `json:"durationMinutes,omitempty"`
+	TimeZone        string `json:"timeZone,omitempty"`
+
+	// Mode is suppress if empty.
+	Mode MaintenanceMode `json:"mode,omitempty"`
+}
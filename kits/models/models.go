@@ -5,13 +5,18 @@ type KitsFile struct {
 }
 
 type Kit struct {
-	DC1 DC `yaml:"dc1"`
-	DC2 DC `yaml:"dc2"`
+	DC1    DC                `yaml:"dc1"`
+	DC2    DC                `yaml:"dc2"`
+	Labels map[string]string `yaml:"labels,omitempty"`
 }
 
 type DC struct {
 	Mate1 Endpoint `yaml:"mate1"`
 	Mate2 Endpoint `yaml:"mate2"`
+	// VPNAddresses are the addresses (IPs or CIDR prefixes) the VPN FQDNs
+	// resolve to while this DC serves the VPNs. The resolved mate hosts are
+	// used when empty.
+	VPNAddresses []string `yaml:"vpnAddresses,omitempty"`
 }
 
 type Endpoint struct {
@@ -32,3 +37,19 @@ type AboutResult struct {
 	Build       string `json:"buildVersion,omitempty"`
 	Description string `json:"description,omitempty"`
 }
+
+// TopologyVersion identifies exactly which topology a run was based on.
+type TopologyVersion struct {
+	Source         string `json:"source"`
+	Entry          string `json:"entry,omitempty"`
+	Digest         string `json:"digest"`
+	ArtifactDigest string `json:"artifactDigest"`
+	ETag           string `json:"etag,omitempty"`
+	LastModified   string `json:"lastModified,omitempty"`
+	Cached         bool   `json:"cached"`
+}
+
+type Topology struct {
+	Version TopologyVersion `json:"version"`
+	Kits    map[string]Kit  `json:"kits"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// ProbeOptions enables the synthetic publish/subscribe probe run on the
+// newly active VPN after a failover.
+type ProbeOptions struct {
+	// Client credentials of the VPN used to publish the canary.
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// RestPort is the REST messaging port, config.PROBE_DEFAULT_REST_PORT if 0.
+	RestPort int `json:"restPort,omitempty"`
+	// Queue is a pre-provisioned queue subscribed to the canary topics.
+	Queue string `json:"queue"`
+}
+
+// ProbeResult tells whether a canary published on the VPN was consumed.
+type ProbeResult struct {
+	VPN       string `json:"vpn"`
+	Host      string `json:"host"`
+	Topic     string `json:"topic"`
+	ID        string `json:"id"`
+	Published bool   `json:"published"`
+	Consumed  bool   `json:"consumed"`
+	// Verified is false when the consumed message could not be matched by
+	// its id or payload, only by its size (REST messaging transport).
+	Verified  bool   `json:"verified"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
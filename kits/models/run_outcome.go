@@ -0,0 +1,27 @@
+package models
+
+// RunStatus is the final status of a multi-kit run.
+type RunStatus string
+
+const (
+	RunSuccess RunStatus = "SUCCESS"
+	RunPartial RunStatus = "PARTIAL"
+	RunFailed  RunStatus = "FAILED"
+)
+
+// KitOutcome aggregates what happened to a single kit and its mates.
+type KitOutcome struct {
+	Kit          string       `json:"kit"`
+	Status       RunStatus    `json:"status"`
+	Error        string       `json:"error,omitempty"`
+	Mates        []MateResult `json:"mates"`
+	SuccessCount int          `json:"successCount"`
+	FailureCount int          `json:"failureCount"`
+	// Failovers reports every VPN failover executed for the kit.
+	Failovers []VPNFailoverResult `json:"failovers,omitempty"`
+	// Actions are the operator actions accepted while the kit ran.
+	Actions []ControlAction `json:"actions,omitempty"`
+	// About lists what the mates answered on the SEMP about endpoints,
+	// reported by KitSolaceAPIWorkflow.
+	About []AboutResult `json:"about,omitempty"`
+}
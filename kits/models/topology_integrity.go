@@ -0,0 +1,14 @@
+package models
+
+// TopologyIntegrity describes how a downloaded topology artifact must be
+// verified before it is trusted.
+type TopologyIntegrity struct {
+	// SHA256 is the expected hex digest of the artifact as published.
+	SHA256 string `json:"sha256,omitempty"`
+	// SHA256Sidecar fetches "<kitsURL>.sha256" and compares against it.
+	SHA256Sidecar bool `json:"sha256Sidecar,omitempty"`
+	// RequireSignature fails unless "<kitsURL>.sig" is a valid ed25519
+	// signature from one of the public keys configured on the worker.
+	// Signatures are always checked when the worker has keys configured.
+	RequireSignature bool `json:"requireSignature,omitempty"`
+}
@@ -0,0 +1,84 @@
+package models
+
+// FailoverOptions are the operator choices carried from the DR run down to
+// every VPN failover.
+type FailoverOptions struct {
+	// Kit is the kit of the VPN, set by KitDRWorkflow. It names the
+	// failover workflow and the lock of the VPN.
+	Kit string `json:"kit,omitempty"`
+	// Force promotes the standby VPN even when the pre-flight says no-go.
+	Force bool `json:"force,omitempty"`
+	// ReconnectionCheck enables the post-failover client reconnection check.
+	ReconnectionCheck *ReconnectionCheck `json:"reconnectionCheck,omitempty"`
+	// Probe enables the synthetic publish/subscribe probe on the new active VPN.
+	Probe *ProbeOptions `json:"probe,omitempty"`
+	// DNSQuorum decides when the DNS watcher acts on changed records.
+	DNSQuorum DNSQuorumOptions `json:"dnsQuorum,omitempty"`
+	// SiteAddresses are the VPN addresses of each DC of the kit, set by
+	// KitDRWorkflow. The DNS watcher fails over toward the DC the records
+	// point to.
+	SiteAddresses map[string][]string `json:"siteAddresses,omitempty"`
+	// Policy sets the timeouts, retries and polling intervals of the
+	// watchers and failovers.
+	Policy DRPolicy `json:"policy,omitempty"`
+	// Maintenance are the maintenance windows of the kit, the watcher
+	// applies the ones of its VPN.
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// ReconnectionCheck configures how long clients are given to follow the
+// failover. Zero values fall back to the config defaults.
+type ReconnectionCheck struct {
+	WindowSeconds   int `json:"windowSeconds,omitempty"`
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+}
+
+// VPNConnections is a snapshot of the client activity of a VPN on one broker.
+type VPNConnections struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Clients    int    `json:"clients"`
+	Queues     int    `json:"queues"`
+	QueueBinds int    `json:"queueBinds"`
+}
+
+// ReconnectionReport tells whether applications followed the failover.
+type ReconnectionReport struct {
+	// Before is the old active site just before the failover, nil when it was unreachable.
+	Before *VPNConnections `json:"before,omitempty"`
+	// NewActiveBefore is the new active site before its promotion, nil when
+	// it was unreachable.
+	NewActiveBefore *VPNConnections `json:"newActiveBefore,omitempty"`
+	// NewActive and OldActive are the last snapshots taken during the window.
+	NewActive *VPNConnections `json:"newActive,omitempty"`
+	OldActive *VPNConnections `json:"oldActive,omitempty"`
+
+	// ClientsMoved is the number of clients the new active site gained
+	// since NewActiveBefore.
+	ClientsMoved   int  `json:"clientsMoved"`
+	TrafficResumed bool `json:"trafficResumed"`
+	OldSiteDrained bool `json:"oldSiteDrained"`
+	Polls          int  `json:"polls"`
+}
+
+// VPNFailoverResult is the result of a single VPN failover.
+type VPNFailoverResult struct {
+	VPN          string              `json:"vpn"`
+	Preflight    FailoverPreflight   `json:"preflight"`
+	Reconnection *ReconnectionReport `json:"reconnection,omitempty"`
+	Probe        *ProbeResult        `json:"probe,omitempty"`
+	// ConfigDiffs lists the config changes of the VPN on each site across the failover.
+	ConfigDiffs []ConfigDiff `json:"configDiffs,omitempty"`
+	// DNSTrigger is the DNS change the watcher failed over on.
+	DNSTrigger *DNSTrigger `json:"dnsTrigger,omitempty"`
+	// Forced is the operator action the failover was forced by.
+	Forced *ControlAction `json:"forced,omitempty"`
+	// Approval is the operator action that released a failover held by a
+	// maintenance window.
+	Approval *ControlAction `json:"approval,omitempty"`
+	// ActiveSkipped is set when a forced failover did not demote and drain
+	// the active site because the pre-flight could not reach it.
+	ActiveSkipped bool `json:"activeSkipped,omitempty"`
+	// Error is set when the failover failed or its pre-flight was no-go.
+	Error string `json:"error,omitempty"`
+}
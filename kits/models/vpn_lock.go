@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// VPNLockState is the state of the lock serializing the failovers of a VPN.
+type VPNLockState struct {
+	Key    string `json:"key"`
+	Holder string `json:"holder,omitempty"`
+	// Since is when the holder acquired the lock.
+	Since time.Time `json:"since,omitempty"`
+	// Renewed is when the holder last renewed its lease.
+	Renewed time.Time `json:"renewed,omitempty"`
+	Waiting []string  `json:"waiting,omitempty"`
+}
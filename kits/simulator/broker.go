@@ -0,0 +1,323 @@
+// Package simulator is an in-process fake Solace broker speaking the subset
+// of SEMP v1 and SEMP v2 used by the worker. It holds per-VPN replication
+// state and can inject latency, errors and outages, so DR scenarios can be
+// tested offline.
+package simulator
+
+import (
+	"io"
+	"kits-worker/kits/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Redundancy is the high availability state reported by SEMP v1.
+type Redundancy struct {
+	ConfigStatus     string // Enabled, Disabled
+	RedundancyStatus string // Up, Down
+	Mode             string // Active/Standby
+	Role             string // Primary, Backup
+	MateRouterName   string
+	PrimaryActivity  string // Local Active, Mate Active, Shutdown, ...
+	BackupActivity   string
+	SpoolStatus      string // AD-Active, AD-Standby
+	ConfigSyncAdmin  string
+	ConfigSyncOper   string
+	ReplicationAdmin string
+	ReplicationOper  string
+}
+
+// VPN is a message VPN of the simulated broker.
+type VPN struct {
+	Name               string
+	Enabled            bool
+	ReplicationEnabled bool
+	Role               string // active, standby
+	BridgeState        string // Up, Down
+	ConfigSync         string // In-Sync, Out-Of-Sync
+	Connections        int
+	// ReplicationBacklog is the number of monitor polls for which the
+	// replication data queue still reports txMsgRate > 0.
+	ReplicationBacklog int
+	Queues             map[string]*Queue
+}
+
+// Queue is a queue of a simulated VPN.
+type Queue struct {
+	Name             string
+	BindCount        int
+	MsgSpoolUsage    float64 // bytes
+	MaxMsgSpoolUsage float64 // MB
+	Msgs             []int64
+}
+
+// State is the mutable state of a simulated broker.
+type State struct {
+	Platform    string
+	SempVersion string
+	Load        string
+	Redundancy  Redundancy
+	VPNs        map[string]*VPN
+}
+
+// Fault makes matching requests fail. Method and PathPrefix are optional
+// filters; Times limits how often the fault fires (0 means forever).
+type Fault struct {
+	Method     string
+	PathPrefix string
+	// Status is the HTTP status returned; 0 keeps 200 and, for SEMP v1,
+	// answers with a failed execute-result carrying Reason.
+	Status int
+	Reason string
+	Times  int
+}
+
+// Request is a request received by the simulator.
+type Request struct {
+	Time   time.Time
+	Method string
+	Path   string
+	Query  string
+	Body   string
+}
+
+// Broker is a simulated broker listening on a local httptest server.
+type Broker struct {
+	Name string
+
+	// Username and Password enable basic authentication when set.
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	state     State
+	faults    []*Fault
+	latency   time.Duration
+	down      bool
+	requests  []Request
+	nextMsgID int64
+
+	srv *httptest.Server
+}
+
+// NewBroker starts a standalone active broker on a local port.
+func NewBroker(name string) *Broker {
+	b := &Broker{
+		Name: name,
+		state: State{
+			Platform:    "VMR",
+			SempVersion: "2.36",
+			Load:        "soltr_10.4.1.112",
+			Redundancy: Redundancy{
+				ConfigStatus:     "Enabled",
+				RedundancyStatus: "Up",
+				Mode:             "Active/Standby",
+				Role:             "Primary",
+				PrimaryActivity:  "Local Active",
+				BackupActivity:   "Shutdown",
+				SpoolStatus:      "AD-Active",
+				ConfigSyncAdmin:  "Enabled",
+				ConfigSyncOper:   "Up",
+				ReplicationAdmin: "Enabled",
+				ReplicationOper:  "Up",
+			},
+			VPNs: make(map[string]*VPN),
+		},
+		nextMsgID: 1000,
+	}
+	b.srv = httptest.NewUnstartedServer(http.HandlerFunc(b.serveHTTP))
+	if l, err := net.Listen("tcp", net.JoinHostPort(nextLoopback(), "0")); err == nil {
+		b.srv.Listener.Close()
+		b.srv.Listener = l
+	}
+	b.srv.Start()
+	return b
+}
+
+var loopbacks atomic.Uint32
+
+// nextLoopback hands out 127.0.0.2 to 127.0.0.254 in turn. kits.yaml refuses
+// a host used by two mates, so every broker gets its own address; where the
+// address is not routable (macOS without aliases) NewBroker falls back to
+// 127.0.0.1.
+func nextLoopback() string {
+	n := loopbacks.Add(1) - 1
+	return "127.0.0." + strconv.Itoa(int(2+n%253))
+}
+
+// Close stops the broker.
+func (b *Broker) Close() { b.srv.Close() }
+
+// URL is the base URL of the broker.
+func (b *Broker) URL() string { return b.srv.URL }
+
+// Endpoint is the kits.yaml endpoint of the broker.
+func (b *Broker) Endpoint() models.Endpoint {
+	u, _ := url.Parse(b.srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return models.Endpoint{Host: u.Hostname(), Port: port}
+}
+
+// Update mutates the broker state under its lock.
+func (b *Broker) Update(fn func(s *State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fn(&b.state)
+}
+
+// AddVPN adds a replicated VPN with the given replication role.
+func (b *Broker) AddVPN(name, role string) {
+	b.Update(func(s *State) {
+		s.VPNs[name] = &VPN{
+			Name:               name,
+			Enabled:            true,
+			ReplicationEnabled: true,
+			Role:               role,
+			BridgeState:        "Up",
+			ConfigSync:         "In-Sync",
+			Queues:             make(map[string]*Queue),
+		}
+	})
+}
+
+// VPNRole returns the replication role of a VPN, empty if unknown.
+func (b *Broker) VPNRole(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if v, ok := b.state.VPNs[name]; ok {
+		return v.Role
+	}
+	return ""
+}
+
+// SetLatency delays every response.
+func (b *Broker) SetLatency(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latency = d
+}
+
+// SetDown simulates an outage: connections are closed without a response.
+func (b *Broker) SetDown(down bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.down = down
+}
+
+// InjectFault adds a fault; faults are evaluated in insertion order.
+func (b *Broker) InjectFault(f Fault) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults = append(b.faults, &f)
+}
+
+// ClearFaults removes every fault, latency and outage.
+func (b *Broker) ClearFaults() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults = nil
+	b.latency = 0
+	b.down = false
+}
+
+// Requests returns the requests received so far.
+func (b *Broker) Requests() []Request {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Request(nil), b.requests...)
+}
+
+// matchFault returns the first fault matching the request, consuming one use.
+// Callers hold b.mu.
+func (b *Broker) matchFault(r *http.Request) *Fault {
+	for i, f := range b.faults {
+		if f.Method != "" && f.Method != r.Method {
+			continue
+		}
+		if f.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, f.PathPrefix) {
+			continue
+		}
+		match := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				b.faults = append(b.faults[:i], b.faults[i+1:]...)
+			}
+		}
+		return &match
+	}
+	return nil
+}
+
+func (b *Broker) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	b.mu.Lock()
+	b.requests = append(b.requests, Request{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Body:   string(body),
+	})
+	latency, down := b.latency, b.down
+	fault := b.matchFault(r)
+	b.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if down {
+		hijackAndClose(w)
+		return
+	}
+
+	if b.Username != "" {
+		if u, p, ok := r.BasicAuth(); !ok || u != b.Username || p != b.Password {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if fault != nil && fault.Status != 0 {
+		http.Error(w, fault.Reason, fault.Status)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/SEMP" && r.Method == http.MethodPost:
+		b.serveSEMPv1(w, body, fault)
+	case strings.HasPrefix(r.URL.Path, "/SEMP/v2/"):
+		b.serveSEMPv2(w, r, body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func hijackAndClose(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "broker down", http.StatusServiceUnavailable)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,132 @@
+package simulator
+
+import (
+	"fmt"
+	"kits-worker/kits/models"
+)
+
+// Kit is a simulated DR kit: two HA pairs, one per DC. DC1 holds the active
+// replication role of every VPN and DC2 the standby one; in each DC mate1
+// is the primary and the active mate.
+type Kit struct {
+	DC1Mate1 *Broker
+	DC1Mate2 *Broker
+	DC2Mate1 *Broker
+	DC2Mate2 *Broker
+}
+
+// NewKit starts the four brokers of a kit hosting the given VPNs.
+func NewKit(vpns ...string) *Kit {
+	k := &Kit{
+		DC1Mate1: NewBroker("dc1-mate1"),
+		DC1Mate2: NewBroker("dc1-mate2"),
+		DC2Mate1: NewBroker("dc2-mate1"),
+		DC2Mate2: NewBroker("dc2-mate2"),
+	}
+	for dc, pair := range [][2]*Broker{{k.DC1Mate1, k.DC1Mate2}, {k.DC2Mate1, k.DC2Mate2}} {
+		role := "active"
+		if dc == 1 {
+			role = "standby"
+		}
+		pairUp(pair[0], pair[1])
+		for _, b := range pair {
+			for _, vpn := range vpns {
+				b.AddVPN(vpn, role)
+			}
+		}
+	}
+	return k
+}
+
+// pairUp makes primary the active mate of backup.
+func pairUp(primary, backup *Broker) {
+	primary.Update(func(s *State) {
+		s.Redundancy.Role = "Primary"
+		s.Redundancy.MateRouterName = backup.Name
+	})
+	backup.Update(func(s *State) {
+		s.Redundancy.Role = "Backup"
+		s.Redundancy.MateRouterName = primary.Name
+		s.Redundancy.PrimaryActivity = "Mate Active"
+		s.Redundancy.BackupActivity = "Shutdown"
+		s.Redundancy.SpoolStatus = "AD-Standby"
+	})
+}
+
+// Brokers returns the brokers of the kit, DC1 first.
+func (k *Kit) Brokers() []*Broker {
+	return []*Broker{k.DC1Mate1, k.DC1Mate2, k.DC2Mate1, k.DC2Mate2}
+}
+
+// Topology is the kits.yaml entry of the kit.
+func (k *Kit) Topology() models.Kit {
+	return models.Kit{
+		DC1: models.DC{Mate1: k.DC1Mate1.Endpoint(), Mate2: k.DC1Mate2.Endpoint()},
+		DC2: models.DC{Mate1: k.DC2Mate1.Endpoint(), Mate2: k.DC2Mate2.Endpoint()},
+	}
+}
+
+// Mates returns the HA pair of a DC ("dc1" or "dc2").
+func (k *Kit) Mates(dc string) (*Broker, *Broker, error) {
+	switch dc {
+	case "dc1":
+		return k.DC1Mate1, k.DC1Mate2, nil
+	case "dc2":
+		return k.DC2Mate1, k.DC2Mate2, nil
+	}
+	return nil, nil, fmt.Errorf("unknown dc %q", dc)
+}
+
+// ActiveMate returns the mate of a DC owning the message spool.
+func (k *Kit) ActiveMate(dc string) (*Broker, error) {
+	m1, m2, err := k.Mates(dc)
+	if err != nil {
+		return nil, err
+	}
+	m2.mu.Lock()
+	defer m2.mu.Unlock()
+	if m2.state.Redundancy.SpoolStatus == "AD-Active" {
+		return m2, nil
+	}
+	return m1, nil
+}
+
+// Broker returns a broker by name (dc1-mate1, ...).
+func (k *Kit) Broker(name string) (*Broker, error) {
+	for _, b := range k.Brokers() {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown broker %q", name)
+}
+
+// MateFailover makes the standby mate of a DC take over the message spool,
+// as an HA failover within the pair would.
+func (k *Kit) MateFailover(dc string) error {
+	m1, m2, err := k.Mates(dc)
+	if err != nil {
+		return err
+	}
+	active, standby := m1, m2
+	if a, _ := k.ActiveMate(dc); a == m2 {
+		active, standby = m2, m1
+	}
+
+	active.Update(func(s *State) {
+		s.Redundancy.PrimaryActivity, s.Redundancy.BackupActivity = "Mate Active", "Shutdown"
+		s.Redundancy.SpoolStatus = "AD-Standby"
+	})
+	standby.Update(func(s *State) {
+		s.Redundancy.PrimaryActivity, s.Redundancy.BackupActivity = "Local Active", "Shutdown"
+		s.Redundancy.SpoolStatus = "AD-Active"
+	})
+	return nil
+}
+
+// Close stops every broker of the kit.
+func (k *Kit) Close() {
+	for _, b := range k.Brokers() {
+		b.Close()
+	}
+}
@@ -0,0 +1,148 @@
+package simulator
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type sempV1VPNName struct {
+	VPNName     string    `xml:"vpn-name"`
+	Replication *struct{} `xml:"replication"`
+}
+
+type sempV1Request struct {
+	XMLName xml.Name `xml:"rpc"`
+	Show    *struct {
+		Version    *struct{} `xml:"version"`
+		Redundancy *struct{} `xml:"redundancy"`
+		ConfigSync *struct {
+			Database *struct {
+				MessageVPN *sempV1VPNName `xml:"message-vpn"`
+			} `xml:"database"`
+		} `xml:"config-sync"`
+		MessageSpool *struct{}      `xml:"message-spool"`
+		Replication  *struct{}      `xml:"replication"`
+		MessageVPN   *sempV1VPNName `xml:"message-vpn"`
+	} `xml:"show"`
+}
+
+func (b *Broker) serveSEMPv1(w http.ResponseWriter, body []byte, fault *Fault) {
+	w.Header().Set("Content-Type", "application/xml")
+
+	var req sempV1Request
+	if err := xml.Unmarshal(body, &req); err != nil || req.Show == nil {
+		fmt.Fprintf(w, `<rpc-reply semp-version="soltr/10_4"><parse-error>%s</parse-error>`+
+			`<execute-result code="fail" reason="parse error" reason-code="1"/></rpc-reply>`, xmlEscape(fmt.Sprint(err)))
+		return
+	}
+	if fault != nil {
+		fmt.Fprintf(w, `<rpc-reply semp-version="soltr/10_4"><rpc><show/></rpc>`+
+			`<execute-result code="fail" reason="%s" reason-code="6"/></rpc-reply>`, xmlEscape(fault.Reason))
+		return
+	}
+
+	b.mu.Lock()
+	inner, err := b.sempV1Show(req)
+	b.mu.Unlock()
+	if err != nil {
+		fmt.Fprintf(w, `<rpc-reply semp-version="soltr/10_4"><rpc><show/></rpc>`+
+			`<execute-result code="fail" reason="%s" reason-code="6"/></rpc-reply>`, xmlEscape(err.Error()))
+		return
+	}
+	fmt.Fprintf(w, `<rpc-reply semp-version="soltr/10_4"><rpc><show>%s</show></rpc><execute-result code="ok"/></rpc-reply>`, inner)
+}
+
+// sempV1Show renders the show reply; callers hold b.mu.
+func (b *Broker) sempV1Show(req sempV1Request) (string, error) {
+	s := &b.state
+	r := s.Redundancy
+	show := req.Show
+
+	switch {
+	case show.Version != nil:
+		return fmt.Sprintf(
+			`<version><description>Solace PubSub+ %s</description><current-load>%s</current-load></version>`,
+			xmlEscape(s.Platform), xmlEscape(s.Load),
+		), nil
+
+	case show.Redundancy != nil:
+		router := func(activity string) string {
+			if activity == "" {
+				return ""
+			}
+			return `<status><activity>` + xmlEscape(activity) + `</activity></status>`
+		}
+		return fmt.Sprintf(
+			`<redundancy><config-status>%s</config-status><redundancy-status>%s</redundancy-status>`+
+				`<redundancy-mode>%s</redundancy-mode><active-standby-role>%s</active-standby-role>`+
+				`<mate-router-name>%s</mate-router-name>`+
+				`<virtual-routers><primary>%s</primary><backup>%s</backup></virtual-routers></redundancy>`,
+			xmlEscape(r.ConfigStatus), xmlEscape(r.RedundancyStatus), xmlEscape(r.Mode), xmlEscape(r.Role),
+			xmlEscape(r.MateRouterName), router(r.PrimaryActivity), router(r.BackupActivity),
+		), nil
+
+	case show.ConfigSync != nil && show.ConfigSync.Database != nil && show.ConfigSync.Database.MessageVPN != nil:
+		name := show.ConfigSync.Database.MessageVPN.VPNName
+		v, ok := s.VPNs[name]
+		if !ok {
+			return "", fmt.Errorf("Unknown message VPN %s", name)
+		}
+		return fmt.Sprintf(
+			`<config-sync><database><local><tables><table><type>Vpn</type><name>%s</name>`+
+				`<sync-state>%s</sync-state><ownership>Master</ownership></table></tables></local></database></config-sync>`,
+			xmlEscape(v.Name), xmlEscape(v.ConfigSync),
+		), nil
+
+	case show.ConfigSync != nil:
+		return fmt.Sprintf(
+			`<config-sync><status><admin-status>%s</admin-status><oper-status>%s</oper-status></status></config-sync>`,
+			xmlEscape(r.ConfigSyncAdmin), xmlEscape(r.ConfigSyncOper),
+		), nil
+
+	case show.MessageSpool != nil:
+		return fmt.Sprintf(
+			`<message-spool><message-spool-info><config-status>Enabled (%s)</config-status>`+
+				`<operational-status>%s</operational-status></message-spool-info></message-spool>`,
+			xmlEscape(r.Role), xmlEscape(r.SpoolStatus),
+		), nil
+
+	case show.Replication != nil:
+		return fmt.Sprintf(
+			`<replication><replication-info><admin-status>%s</admin-status><oper-status>%s</oper-status></replication-info></replication>`,
+			xmlEscape(r.ReplicationAdmin), xmlEscape(r.ReplicationOper),
+		), nil
+
+	case show.MessageVPN != nil:
+		name := show.MessageVPN.VPNName
+		v, ok := s.VPNs[name]
+		if !ok {
+			return "", fmt.Errorf("Unknown message VPN %s", name)
+		}
+		if show.MessageVPN.Replication != nil {
+			queueState := "Bound"
+			if v.BridgeState != "Up" {
+				queueState = "Unbound"
+			}
+			return fmt.Sprintf(
+				`<message-vpn><replication><message-vpns><message-vpn><vpn-name>%s</vpn-name>`+
+					`<admin-state>Enabled</admin-state><config-state>%s</config-state><local-status>Up</local-status>`+
+					`<bridge-state>%s</bridge-state><queue-state>%s</queue-state></message-vpn></message-vpns></replication></message-vpn>`,
+				xmlEscape(v.Name), strings.Title(v.Role), xmlEscape(v.BridgeState), queueState,
+			), nil
+		}
+		return fmt.Sprintf(
+			`<message-vpn><vpn><name>%s</name><enabled>%t</enabled><operational>%t</operational>`+
+				`<local-status>Up</local-status><connections>%d</connections></vpn></message-vpn>`,
+			xmlEscape(v.Name), v.Enabled, v.Enabled, v.Connections,
+		), nil
+	}
+	return "", fmt.Errorf("unsupported show command")
+}
+
+func xmlEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,422 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReplicationQueue is the queue carrying replicated messages of a VPN.
+const ReplicationQueue = "#MSGVPN_REPLICATION_DATA_QUEUE"
+
+// defaultCount is the SEMP v2 page size when the request has no count.
+const defaultCount = 10
+
+type object = map[string]interface{}
+
+type sempV2Error struct {
+	status int
+	desc   string
+}
+
+func (b *Broker) serveSEMPv2(w http.ResponseWriter, r *http.Request, body []byte) {
+	segs, err := pathSegments(strings.TrimPrefix(r.URL.EscapedPath(), "/SEMP/v2/"))
+	if err != nil {
+		writeSEMPv2Error(w, r, sempV2Error{http.StatusBadRequest, err.Error()})
+		return
+	}
+
+	b.mu.Lock()
+	data, serr := b.route(r, segs, body)
+	b.mu.Unlock()
+	if serr != nil {
+		writeSEMPv2Error(w, r, *serr)
+		return
+	}
+
+	meta := object{
+		"request":      object{"method": r.Method, "uri": b.srv.URL + r.URL.RequestURI()},
+		"responseCode": http.StatusOK,
+	}
+	if list, ok := data.([]object); ok {
+		page, next, err := b.paginate(r, list)
+		if err != nil {
+			writeSEMPv2Error(w, r, sempV2Error{http.StatusBadRequest, err.Error()})
+			return
+		}
+		if next != "" {
+			meta["paging"] = object{"cursorQuery": next, "nextPageUri": next}
+		}
+		data = page
+	} else if obj, ok := data.(object); ok {
+		data = selectAttrs(obj, r.URL.Query().Get("select"))
+	}
+
+	resp := object{"data": data, "meta": meta}
+	if len(segs) == 2 && segs[0] == "monitor" && segs[1] == "about" {
+		resp["links"] = object{"apiUri": b.srv.URL + "/SEMP/v2/monitor/about/api"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// route dispatches a SEMP v2 request to the object or collection it names;
+// callers hold b.mu.
+func (b *Broker) route(r *http.Request, segs []string, body []byte) (interface{}, *sempV2Error) {
+	notFound := &sempV2Error{http.StatusBadRequest, "Could not find match for " + r.URL.Path}
+	if len(segs) < 2 {
+		return nil, notFound
+	}
+	api, rest := segs[0], segs[1:]
+
+	if api == "monitor" && rest[0] == "about" {
+		switch {
+		case len(rest) == 1:
+			return object{}, nil
+		case len(rest) == 2 && rest[1] == "api":
+			return object{"platform": b.state.Platform, "sempVersion": b.state.SempVersion}, nil
+		}
+		return nil, notFound
+	}
+
+	if rest[0] != "msgVpns" {
+		return nil, notFound
+	}
+	if len(rest) == 1 {
+		if r.Method != http.MethodGet {
+			return nil, &sempV2Error{http.StatusMethodNotAllowed, "method not allowed"}
+		}
+		var list []object
+		for _, v := range b.state.VPNs {
+			list = append(list, vpnObject(api, v))
+		}
+		return list, nil
+	}
+
+	v, ok := b.state.VPNs[rest[1]]
+	if !ok {
+		return nil, &sempV2Error{http.StatusBadRequest, "Could not find match for msgVpnName " + rest[1]}
+	}
+
+	switch {
+	case len(rest) == 2 && r.Method == http.MethodGet:
+		return vpnObject(api, v), nil
+
+	case len(rest) == 2 && r.Method == http.MethodPatch && api == "config":
+		var patch object
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, &sempV2Error{http.StatusBadRequest, "invalid JSON: " + err.Error()}
+		}
+		if err := patchVPN(v, patch); err != nil {
+			return nil, err
+		}
+		return vpnObject(api, v), nil
+
+	case len(rest) == 3 && rest[2] == "queues" && r.Method == http.MethodGet:
+		var list []object
+		for _, q := range v.Queues {
+			list = append(list, queueObject(api, q))
+		}
+		if api == "monitor" && v.ReplicationEnabled {
+			list = append(list, replicationQueueObject(v))
+		}
+		return list, nil
+
+	case len(rest) == 3 && rest[2] == "clientProfiles" && r.Method == http.MethodGet && api == "config":
+		return []object{{"msgVpnName": v.Name, "clientProfileName": "default", "allowGuaranteedMsgSendEnabled": true}}, nil
+
+	case len(rest) == 3 && rest[2] == "aclProfiles" && r.Method == http.MethodGet && api == "config":
+		return []object{{"msgVpnName": v.Name, "aclProfileName": "default", "clientConnectDefaultAction": "allow"}}, nil
+
+	case len(rest) == 5 && rest[2] == "queues" && rest[4] == "msgs" && r.Method == http.MethodGet && api == "monitor":
+		q, ok := v.Queues[rest[3]]
+		if !ok {
+			return nil, &sempV2Error{http.StatusBadRequest, "Could not find match for queueName " + rest[3]}
+		}
+		list := []object{}
+		for _, id := range q.Msgs {
+			list = append(list, object{"msgVpnName": v.Name, "queueName": q.Name, "msgId": id})
+		}
+		return list, nil
+
+	case len(rest) == 7 && rest[2] == "queues" && rest[4] == "msgs" && rest[6] == "delete" && r.Method == http.MethodPut && api == "action":
+		q, ok := v.Queues[rest[3]]
+		if !ok {
+			return nil, &sempV2Error{http.StatusBadRequest, "Could not find match for queueName " + rest[3]}
+		}
+		id, err := strconv.ParseInt(rest[5], 10, 64)
+		if err != nil {
+			return nil, &sempV2Error{http.StatusBadRequest, "invalid msgId " + rest[5]}
+		}
+		for i, m := range q.Msgs {
+			if m == id {
+				q.Msgs = append(q.Msgs[:i], q.Msgs[i+1:]...)
+				return object{}, nil
+			}
+		}
+		return nil, &sempV2Error{http.StatusBadRequest, fmt.Sprintf("Could not find match for msgId %d", id)}
+	}
+	return nil, notFound
+}
+
+func vpnObject(api string, v *VPN) object {
+	obj := object{
+		"msgVpnName":         v.Name,
+		"enabled":            v.Enabled,
+		"replicationEnabled": v.ReplicationEnabled,
+		"replicationRole":    v.Role,
+	}
+	if api == "monitor" {
+		state := "down"
+		if v.Enabled {
+			state = "up"
+		}
+		obj["state"] = state
+		obj["msgVpnConnections"] = v.Connections
+		obj["replicationBridgeUp"] = v.BridgeState == "Up"
+	}
+	return obj
+}
+
+func patchVPN(v *VPN, patch object) *sempV2Error {
+	for k, val := range patch {
+		switch k {
+		case "replicationRole":
+			role, _ := val.(string)
+			if role != "active" && role != "standby" {
+				return &sempV2Error{http.StatusBadRequest, fmt.Sprintf("invalid replicationRole %v", val)}
+			}
+			v.Role = role
+		case "enabled", "replicationEnabled":
+			on, ok := val.(bool)
+			if !ok {
+				return &sempV2Error{http.StatusBadRequest, fmt.Sprintf("invalid %s %v", k, val)}
+			}
+			if k == "enabled" {
+				v.Enabled = on
+			} else {
+				v.ReplicationEnabled = on
+			}
+		default:
+			return &sempV2Error{http.StatusBadRequest, "unsupported attribute " + k}
+		}
+	}
+	return nil
+}
+
+func queueObject(api string, q *Queue) object {
+	obj := object{
+		"queueName":        q.Name,
+		"maxMsgSpoolUsage": q.MaxMsgSpoolUsage,
+		"ingressEnabled":   true,
+		"egressEnabled":    true,
+	}
+	if api == "monitor" {
+		obj["bindCount"] = q.BindCount
+		obj["msgSpoolUsage"] = q.MsgSpoolUsage
+		obj["spooledMsgCount"] = len(q.Msgs)
+		obj["txMsgRate"] = 0
+	}
+	return obj
+}
+
+// replicationQueueObject reports the replication data queue; every poll
+// consumes one unit of the VPN backlog.
+func replicationQueueObject(v *VPN) object {
+	rate := 0
+	if v.ReplicationBacklog > 0 {
+		rate = 1000
+		v.ReplicationBacklog--
+	}
+	return object{
+		"queueName":        ReplicationQueue,
+		"maxMsgSpoolUsage": 0,
+		"bindCount":        0,
+		"msgSpoolUsage":    0,
+		"txMsgRate":        rate,
+	}
+}
+
+// paginate applies where, select, count and cursor to a collection.
+func (b *Broker) paginate(r *http.Request, list []object) ([]object, string, error) {
+	q := r.URL.Query()
+
+	var filtered []object
+	for _, obj := range list {
+		ok, err := matchWhere(obj, q["where"])
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			filtered = append(filtered, obj)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return objectKey(filtered[i]) < objectKey(filtered[j]) })
+
+	count := defaultCount
+	if c := q.Get("count"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			return nil, "", fmt.Errorf("invalid count %q", c)
+		}
+		count = n
+	}
+	offset := 0
+	if c := q.Get("cursor"); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 0 {
+			return nil, "", fmt.Errorf("invalid cursor %q", c)
+		}
+		offset = n
+	}
+	if offset > len(filtered) {
+		offset = len(filtered)
+	}
+	end := offset + count
+	next := ""
+	if end < len(filtered) {
+		q.Set("cursor", strconv.Itoa(end))
+		next = b.srv.URL + r.URL.EscapedPath() + "?" + q.Encode()
+	} else {
+		end = len(filtered)
+	}
+
+	page := []object{}
+	for _, obj := range filtered[offset:end] {
+		page = append(page, selectAttrs(obj, q.Get("select")))
+	}
+	return page, next, nil
+}
+
+// objectKey orders collections by their naming attribute.
+func objectKey(obj object) string {
+	for _, k := range []string{"queueName", "clientProfileName", "aclProfileName"} {
+		if s, ok := obj[k].(string); ok {
+			return s
+		}
+	}
+	if id, ok := obj["msgId"].(int64); ok {
+		return fmt.Sprintf("%020d", id)
+	}
+	s, _ := obj["msgVpnName"].(string)
+	return s
+}
+
+func selectAttrs(obj object, sel string) object {
+	if sel == "" {
+		return obj
+	}
+	out := object{}
+	for _, k := range strings.Split(sel, ",") {
+		if v, ok := obj[k]; ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+var whereOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// matchWhere evaluates SEMP v2 where conditions, all of which must hold.
+// String values accept * wildcards with == and !=.
+func matchWhere(obj object, wheres []string) (bool, error) {
+	for _, where := range wheres {
+		for _, cond := range strings.Split(where, ",") {
+			ok, err := matchCondition(obj, cond)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func matchCondition(obj object, cond string) (bool, error) {
+	for _, op := range whereOps {
+		i := strings.Index(cond, op)
+		if i < 1 {
+			continue
+		}
+		attr, want := cond[:i], cond[i+len(op):]
+		got, ok := obj[attr]
+		if !ok {
+			return false, fmt.Errorf("unknown attribute %q in where", attr)
+		}
+
+		var cmp int
+		switch g := got.(type) {
+		case string:
+			if op == "==" || op == "!=" {
+				m, err := path.Match(want, g)
+				if err != nil {
+					return false, fmt.Errorf("invalid where value %q", want)
+				}
+				return m == (op == "=="), nil
+			}
+			cmp = strings.Compare(g, want)
+		case bool:
+			cmp = strings.Compare(strconv.FormatBool(g), want)
+		default:
+			gf, err := strconv.ParseFloat(fmt.Sprint(g), 64)
+			if err != nil {
+				return false, err
+			}
+			wf, err := strconv.ParseFloat(want, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid where value %q", want)
+			}
+			switch {
+			case gf < wf:
+				cmp = -1
+			case gf > wf:
+				cmp = 1
+			}
+		}
+
+		switch op {
+		case "==":
+			return cmp == 0, nil
+		case "!=":
+			return cmp != 0, nil
+		case "<":
+			return cmp < 0, nil
+		case ">":
+			return cmp > 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	}
+	return false, fmt.Errorf("invalid where condition %q", cond)
+}
+
+func pathSegments(escaped string) ([]string, error) {
+	var segs []string
+	for _, s := range strings.Split(strings.Trim(escaped, "/"), "/") {
+		u, err := url.PathUnescape(s)
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, u)
+	}
+	return segs, nil
+}
+
+func writeSEMPv2Error(w http.ResponseWriter, r *http.Request, e sempV2Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(object{
+		"meta": object{
+			"error":        object{"description": e.desc},
+			"request":      object{"method": r.Method, "uri": r.URL.RequestURI()},
+			"responseCode": e.status,
+		},
+	})
+}
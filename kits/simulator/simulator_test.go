@@ -0,0 +1,200 @@
+package simulator
+
+import (
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func newActivityEnv(t *testing.T) *testsuite.TestActivityEnvironment {
+	activities.SetAuditSinks(activities.NewJSONLAuditSink(filepath.Join(t.TempDir(), "audit.jsonl")))
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(activities.CheckMateStatusActivity)
+	env.RegisterActivity(activities.BrokerSEMPApiAboutActivity)
+	env.RegisterActivity(activities.GetRoleVPNsActivity)
+	env.RegisterActivity(activities.SetVPNReplicationRoleActivity)
+	env.RegisterActivity(activities.WaitForReplicationDrainActivity)
+	env.RegisterActivity(activities.ValidateFinalRolesActivity)
+	env.RegisterActivity(activities.FailoverPreflightActivity)
+	return env
+}
+
+func TestKitMateStatus(t *testing.T) {
+	kit := NewKit("orders")
+	defer kit.Close()
+	env := newActivityEnv(t)
+	topo := kit.Topology()
+
+	check := func(ep models.Endpoint) models.MateStatus {
+		t.Helper()
+		val, err := env.ExecuteActivity(activities.CheckMateStatusActivity, "kit1", "dc1", "mate", ep, models.BasicAuth{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res models.MateResult
+		if err := val.Get(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Status
+	}
+
+	if s := check(topo.DC1.Mate1); s != models.Active {
+		t.Errorf("dc1 mate1: expected %s, got %s", models.Active, s)
+	}
+	if s := check(topo.DC1.Mate2); s != models.Standby {
+		t.Errorf("dc1 mate2: expected %s, got %s", models.Standby, s)
+	}
+
+	if err := kit.MateFailover("dc1"); err != nil {
+		t.Fatal(err)
+	}
+	if s := check(topo.DC1.Mate1); s != models.Standby {
+		t.Errorf("dc1 mate1 after HA failover: expected %s, got %s", models.Standby, s)
+	}
+	if s := check(topo.DC1.Mate2); s != models.Active {
+		t.Errorf("dc1 mate2 after HA failover: expected %s, got %s", models.Active, s)
+	}
+
+	kit.DC2Mate1.SetDown(true)
+	val, err := env.ExecuteActivity(activities.CheckMateStatusActivity, "kit1", "dc2", "mate1", topo.DC2.Mate1, models.BasicAuth{})
+	if err == nil {
+		var res models.MateResult
+		val.Get(&res)
+		if res.Status == models.Active {
+			t.Errorf("expected a down broker not to be reported active")
+		}
+	}
+}
+
+func TestSEMPAbout(t *testing.T) {
+	b := NewBroker("solo")
+	defer b.Close()
+	env := newActivityEnv(t)
+
+	val, err := env.ExecuteActivity(activities.BrokerSEMPApiAboutActivity, "kit1", "dc1", "mate1", b.Endpoint(), models.BasicAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var about activities.AboutResponse
+	if err := val.Get(&about); err != nil {
+		t.Fatal(err)
+	}
+	if about.Platform != "VMR" || about.SempVersion != "2.36" || about.ApiVersion != "v2" {
+		t.Errorf("unexpected about %+v", about)
+	}
+}
+
+func TestVPNFailoverOnSimulator(t *testing.T) {
+	kit := NewKit("orders", "billing", "#config-sync")
+	defer kit.Close()
+	env := newActivityEnv(t)
+	topo := kit.Topology()
+	auth := models.BasicAuth{}
+
+	val, err := env.ExecuteActivity(activities.GetRoleVPNsActivity, topo.DC1.Mate1.Host, topo.DC1.Mate1.Port, "active", auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vpns []string
+	if err := val.Get(&vpns); err != nil {
+		t.Fatal(err)
+	}
+	if len(vpns) != 2 || vpns[0] != "billing" || vpns[1] != "orders" {
+		t.Fatalf("expected [billing orders] without system VPNs, got %v", vpns)
+	}
+
+	active := &models.MateResult{DC: "dc1", Host: topo.DC1.Mate1.Host, Port: topo.DC1.Mate1.Port, Status: models.Active}
+	standby := &models.MateResult{DC: "dc2", Host: topo.DC2.Mate1.Host, Port: topo.DC2.Mate1.Port, Status: models.Active}
+
+	kit.DC1Mate1.Update(func(s *State) { s.VPNs["orders"].BridgeState = "Down" })
+	val, err = env.ExecuteActivity(activities.FailoverPreflightActivity, "orders", active, standby, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pre models.FailoverPreflight
+	if err := val.Get(&pre); err != nil {
+		t.Fatal(err)
+	}
+	if pre.Go {
+		t.Errorf("expected no-go with the replication bridge down, got %+v", pre)
+	}
+	kit.DC1Mate1.Update(func(s *State) { s.VPNs["orders"].BridgeState = "Up" })
+
+	if _, err := env.ExecuteActivity(activities.SetVPNReplicationRoleActivity, active.Host, active.Port, "orders", "standby", auth); err != nil {
+		t.Fatal(err)
+	}
+	if role := kit.DC1Mate1.VPNRole("orders"); role != "standby" {
+		t.Fatalf("expected dc1 orders standby, got %q", role)
+	}
+
+	kit.DC1Mate1.Update(func(s *State) { s.VPNs["orders"].ReplicationBacklog = 2 })
+	for i := 0; i < 2; i++ {
+		if _, err := env.ExecuteActivity(activities.WaitForReplicationDrainActivity, active.Host, active.Port, "orders", auth); err == nil {
+			t.Fatalf("poll %d: expected replication still in progress", i)
+		}
+	}
+	if _, err := env.ExecuteActivity(activities.WaitForReplicationDrainActivity, active.Host, active.Port, "orders", auth); err != nil {
+		t.Fatalf("expected drained replication queue, got %v", err)
+	}
+
+	if _, err := env.ExecuteActivity(activities.ValidateFinalRolesActivity, "orders", active, standby, auth); err == nil {
+		t.Fatalf("expected validation to fail before dc2 is promoted")
+	}
+	if _, err := env.ExecuteActivity(activities.SetVPNReplicationRoleActivity, standby.Host, standby.Port, "orders", "active", auth); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.ExecuteActivity(activities.ValidateFinalRolesActivity, "orders", active, standby, auth); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	b := NewBroker("solo")
+	defer b.Close()
+	b.AddVPN("orders", "active")
+	env := newActivityEnv(t)
+	ep := b.Endpoint()
+
+	b.InjectFault(Fault{Method: http.MethodPatch, PathPrefix: "/SEMP/v2/config/msgVpns/orders", Status: http.StatusInternalServerError, Times: 1})
+	if _, err := env.ExecuteActivity(activities.SetVPNReplicationRoleActivity, ep.Host, ep.Port, "orders", "standby", models.BasicAuth{}); err == nil {
+		t.Fatalf("expected the injected PATCH failure")
+	}
+	if role := b.VPNRole("orders"); role != "active" {
+		t.Fatalf("expected role unchanged by the failed PATCH, got %q", role)
+	}
+	if _, err := env.ExecuteActivity(activities.SetVPNReplicationRoleActivity, ep.Host, ep.Port, "orders", "standby", models.BasicAuth{}); err != nil {
+		t.Fatalf("expected the fault to fire once, got %v", err)
+	}
+
+	b.InjectFault(Fault{PathPrefix: "/SEMP", Reason: "Permission Denied"})
+	if _, err := env.ExecuteActivity(activities.CheckMateStatusActivity, "kit1", "dc1", "mate1", ep, models.BasicAuth{}); err == nil {
+		t.Errorf("expected a failed SEMP v1 execute-result")
+	}
+	b.ClearFaults()
+
+	b.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := env.ExecuteActivity(activities.CheckMateStatusActivity, "kit1", "dc1", "mate1", ep, models.BasicAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("expected injected latency")
+	}
+
+	var patches int
+	for _, r := range b.Requests() {
+		if r.Method == http.MethodPatch {
+			patches++
+		}
+	}
+	if patches != 2 {
+		t.Errorf("expected 2 PATCH requests logged, got %d", patches)
+	}
+}
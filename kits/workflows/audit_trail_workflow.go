@@ -0,0 +1,43 @@
+package workflows
+
+import (
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// AuditTrailWorkflow returns the broker mutations recorded by the workers for
+// a VPN and/or time range, oldest first. The query runs on
+// config.AUDIT_TASK_QUEUE, where a worker reading the shared audit log
+// (KITS_AUDIT_LOG) answers it.
+//
+// Example Temporal UI input:
+//
+//	{
+//	  "vpn": "orders",
+//	  "from": "2026-01-19T00:00:00Z",
+//	  "to": "2026-01-20T00:00:00Z"
+//	}
+func AuditTrailWorkflow(
+	ctx workflow.Context,
+	query models.AuditQuery,
+) ([]models.AuditRecord, error) {
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:           config.AUDIT_TASK_QUEUE,
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 3,
+		},
+	})
+
+	var records []models.AuditRecord
+	if err := workflow.ExecuteActivity(ctx, activities.QueryAuditTrailActivity, query).Get(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,42 @@
+package workflows
+
+import (
+	"context"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestAuditTrailWorkflow_QueriesOnAuditTaskQueue(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(AuditTrailWorkflow)
+	env.RegisterActivity(activities.QueryAuditTrailActivity)
+
+	var taskQueue string
+	env.OnActivity(activities.QueryAuditTrailActivity, mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, q models.AuditQuery) ([]models.AuditRecord, error) {
+			taskQueue = activity.GetInfo(ctx).TaskQueue
+			return []models.AuditRecord{{Action: "SetReplicationRole", VPN: q.VPN}}, nil
+		})
+
+	env.ExecuteWorkflow(AuditTrailWorkflow, models.AuditQuery{VPN: "orders"})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var records []models.AuditRecord
+	if err := env.GetWorkflowResult(&records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].VPN != "orders" {
+		t.Errorf("unexpected records %+v", records)
+	}
+	if taskQueue != config.AUDIT_TASK_QUEUE {
+		t.Errorf("expected the query on %s, got %q", config.AUDIT_TASK_QUEUE, taskQueue)
+	}
+}
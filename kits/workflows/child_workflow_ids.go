@@ -0,0 +1,38 @@
+package workflows
+
+import (
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/workflow"
+)
+
+// The DR children are named after their kit and VPN, so that a kit or VPN
+// already handled by another run is not started twice: starting a child
+// whose ID is running fails with a ChildWorkflowExecutionAlreadyStarted
+// error, a closed one may be run again.
+
+func kitDRWorkflowID(kit string) string {
+	return "kit-dr/" + kit
+}
+
+func vpnDNSWatchWorkflowID(kit, vpn string) string {
+	return "vpn-dns-watch/" + vpnLockKey(kit, vpn)
+}
+
+func vpnFailoverWorkflowID(kit, vpn string) string {
+	return "vpn-failover/" + vpnLockKey(kit, vpn)
+}
+
+// childWorkflowIDsVersion marks runs naming their DR children, the ones
+// started before keep the IDs generated by the SDK.
+const childWorkflowIDsVersion = "child-workflow-ids"
+
+// withChildWorkflowID starts the children of ctx under id.
+func withChildWorkflowID(ctx workflow.Context, id string) workflow.Context {
+	if workflow.GetVersion(ctx, childWorkflowIDsVersion, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return ctx
+	}
+	return workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:            id,
+		WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+	})
+}
@@ -0,0 +1,482 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"kits-worker/kits/models"
+	"slices"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Updates, signal and query controlling running DNS watchers and kit
+// workflows. The updates take a models.ControlRequest and return the state
+// once the action is applied; the name of each update is its action.
+const (
+	UpdatePause         = string(models.ControlPause)
+	UpdateResume        = string(models.ControlResume)
+	UpdateForceFailover = string(models.ControlForceFailover)
+	UpdateExcludeVPN    = string(models.ControlExcludeVPN)
+	UpdateApprove       = string(models.ControlApproveFailover)
+
+	// QueryState returns the models.WatcherState of a watcher and the
+	// models.KitControlState of a kit workflow.
+	QueryState = "state"
+
+	// controlSignal carries the models.ControlAction a kit workflow forwards
+	// to its watchers, and controlOutcomeSignal the models.ControlOutcome a
+	// watcher reports back.
+	controlSignal        = "control"
+	controlOutcomeSignal = "controlOutcome"
+)
+
+var controlActions = []models.ControlActionType{
+	models.ControlPause,
+	models.ControlResume,
+	models.ControlForceFailover,
+	models.ControlExcludeVPN,
+	models.ControlApproveFailover,
+}
+
+// watcherControl holds the operator actions of a DNS watcher.
+type watcherControl struct {
+	state    models.WatcherState
+	opts     *models.FailoverOptions
+	forced   *models.ControlAction
+	approval *models.ControlAction
+	// wake ends the current wait once, ie to act on an approval at once
+	wake bool
+	// reporting counts the outcomes being reported to the kit workflow
+	reporting int
+}
+
+// setWatcherControlHandlers registers the control updates, the query and
+// the signal of the kit workflow on a watcher.
+func setWatcherControlHandlers(ctx workflow.Context, vpn string, opts *models.FailoverOptions) (*watcherControl, error) {
+	c := &watcherControl{state: models.WatcherState{VPN: vpn}, opts: opts}
+
+	for _, action := range controlActions {
+		if err := workflow.SetUpdateHandlerWithOptions(ctx, string(action),
+			func(ctx workflow.Context, req models.ControlRequest) (models.WatcherState, error) {
+				c.apply(ctx, models.ControlAction{Action: action, VPN: vpn, Reason: req.Reason, At: workflow.Now(ctx)})
+				return c.snapshot(), nil
+			},
+			workflow.UpdateHandlerOptions{
+				Validator: func(ctx workflow.Context, req models.ControlRequest) error {
+					return c.validate(action, req)
+				},
+			},
+		); err != nil {
+			return nil, err
+		}
+	}
+	if err := workflow.SetQueryHandler(ctx, QueryState, func() (models.WatcherState, error) {
+		return c.snapshot(), nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ch := workflow.GetSignalChannel(ctx, controlSignal)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			var action models.ControlAction
+			ch.Receive(ctx, &action)
+			err := c.validate(action.Action, models.ControlRequest{VPN: action.VPN})
+			if err != nil {
+				workflow.GetLogger(ctx).Warn("Control action of the kit ignored", "vpn", vpn, "action", action.Action, "error", err)
+			} else {
+				c.apply(ctx, action)
+			}
+			if action.ID != 0 {
+				c.report(ctx, action, err)
+			}
+		}
+	})
+	return c, nil
+}
+
+// report tells the kit workflow whether the action it forwarded was
+// applied.
+func (c *watcherControl) report(ctx workflow.Context, action models.ControlAction, err error) {
+	parent := workflow.GetInfo(ctx).ParentWorkflowExecution
+	if parent == nil {
+		return
+	}
+	outcome := models.ControlOutcome{ID: action.ID, VPN: c.state.VPN, Action: action.Action}
+	if err != nil {
+		outcome.Error = err.Error()
+	}
+	c.reporting++
+	defer func() { c.reporting-- }()
+	if err := workflow.SignalExternalWorkflow(ctx, parent.ID, parent.RunID, controlOutcomeSignal, outcome).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Control outcome not reported", "vpn", c.state.VPN, "action", action.Action, "error", err)
+	}
+}
+
+// flush waits until the outcomes being reported reached the kit workflow,
+// before the watcher completes.
+func (c *watcherControl) flush(ctx workflow.Context) {
+	_ = workflow.Await(ctx, func() bool { return c.reporting == 0 })
+}
+
+func (c *watcherControl) validate(action models.ControlActionType, req models.ControlRequest) error {
+	switch {
+	case req.VPN != "" && req.VPN != c.state.VPN:
+		return fmt.Errorf("watcher of vpn %s cannot control vpn %s", c.state.VPN, req.VPN)
+	case c.state.Excluded:
+		return fmt.Errorf("vpn %s is excluded", c.state.VPN)
+	}
+	switch action {
+	case models.ControlPause:
+		if c.state.Paused {
+			return fmt.Errorf("vpn %s is already paused", c.state.VPN)
+		}
+	case models.ControlResume:
+		if !c.state.Paused {
+			return fmt.Errorf("vpn %s is not paused", c.state.VPN)
+		}
+	case models.ControlForceFailover, models.ControlExcludeVPN:
+		if c.state.FailoverStarted || c.state.Forced {
+			return fmt.Errorf("failover of vpn %s already started", c.state.VPN)
+		}
+	case models.ControlApproveFailover:
+		if !c.state.AwaitingApproval {
+			return fmt.Errorf("vpn %s has no failover awaiting approval", c.state.VPN)
+		}
+	default:
+		return fmt.Errorf("unknown control action %q", action)
+	}
+	return nil
+}
+
+func (c *watcherControl) apply(ctx workflow.Context, action models.ControlAction) {
+	switch action.Action {
+	case models.ControlPause:
+		c.state.Paused = true
+	case models.ControlResume:
+		c.state.Paused = false
+	case models.ControlForceFailover:
+		c.state.Forced = true
+		c.forced = &action
+	case models.ControlExcludeVPN:
+		c.state.Excluded = true
+	case models.ControlApproveFailover:
+		c.state.AwaitingApproval = false
+		c.approval = &action
+		c.wake = true
+	}
+	c.state.Actions = append(c.state.Actions, action)
+	workflow.GetLogger(ctx).Info("Control action applied", "vpn", c.state.VPN, "action", action.Action, "reason", action.Reason)
+}
+
+func (c *watcherControl) snapshot() models.WatcherState {
+	s := c.state
+	s.Policy = c.opts.Policy
+	s.Actions = slices.Clone(s.Actions)
+	return s
+}
+
+// suppressed reports whether DNS changes are ignored, while paused or
+// within a maintenance window in suppress mode.
+func (c *watcherControl) suppressed(ctx workflow.Context) (bool, error) {
+	w, err := c.maintenance(ctx)
+	if err != nil {
+		return false, err
+	}
+	return c.state.Paused || w != nil && w.Mode != models.MaintenanceApproval, nil
+}
+
+// held reports whether a failover the DNS calls for waits for approval,
+// within a maintenance window in approval mode until an operator approves.
+// An approval releases the next failover.
+func (c *watcherControl) held(ctx workflow.Context) (bool, error) {
+	w, err := c.maintenance(ctx)
+	if err != nil {
+		return false, err
+	}
+	c.state.AwaitingApproval = w != nil && w.Mode == models.MaintenanceApproval && c.approval == nil
+	if c.state.AwaitingApproval {
+		workflow.GetLogger(ctx).Warn("Failover held by maintenance window, awaiting approval", "vpn", c.state.VPN, "window", w.Name)
+	}
+	return c.state.AwaitingApproval, nil
+}
+
+// maintenance returns the maintenance window in force, in workflow time.
+func (c *watcherControl) maintenance(ctx workflow.Context) (*models.MaintenanceWindow, error) {
+	w, err := activeMaintenance(c.opts.Maintenance, c.state.VPN, workflow.Now(ctx))
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Maintenance windows cannot be evaluated", "vpn", c.state.VPN, "error", err)
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidInput", err)
+	}
+	name := ""
+	if w != nil {
+		name = w.Name
+	}
+	if w == nil || w.Mode != models.MaintenanceApproval {
+		c.state.AwaitingApproval = false
+	}
+	if name != c.state.Maintenance {
+		workflow.GetLogger(ctx).Info("Maintenance window changed", "vpn", c.state.VPN, "from", c.state.Maintenance, "to", name)
+		c.state.Maintenance = name
+	}
+	return w, nil
+}
+
+// interrupted reports whether an operator ended the watch.
+func (c *watcherControl) interrupted() bool {
+	return c.state.Forced || c.state.Excluded
+}
+
+// wait sleeps for d or until an operator ends the watch or approves the
+// failover.
+func (c *watcherControl) wait(ctx workflow.Context, d time.Duration) error {
+	_, err := workflow.AwaitWithTimeout(ctx, d, func() bool {
+		return c.interrupted() || c.wake
+	})
+	c.wake = false
+	return err
+}
+
+// finish ends an interrupted watch: an excluded VPN returns no result, a
+// forced failover runs at once.
+func (c *watcherControl) finish(ctx workflow.Context, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth) (*models.VPNFailoverResult, error) {
+	if c.state.Excluded {
+		workflow.GetLogger(ctx).Warn("VPN excluded, stopping DNS watcher without failover", "vpn", vpn)
+		return nil, nil
+	}
+	result, err := executeFailover(ctx, c, vpn, active, standby, auth)
+	if err != nil {
+		return nil, err
+	}
+	result.Forced = c.forced
+	return result, nil
+}
+
+// kitControl holds the operator actions of a kit workflow and forwards them
+// to its watchers.
+type kitControl struct {
+	state    models.KitControlState
+	watchers map[string]workflow.ChildWorkflowFuture
+	// caughtUp is set once the actions accepted before the watchers started
+	// were forwarded, later actions are forwarded as they come.
+	caughtUp bool
+	// lastID is the ID of the last action, outcomes are the ones reported
+	// by the watchers and not collected yet
+	lastID   int
+	outcomes []models.ControlOutcome
+}
+
+// setKitControlHandlers registers the control updates, the query and the
+// outcome signal on a kit workflow. Once the watchers started, an action is
+// forwarded first and only accepted when a watcher applied it. Actions
+// accepted before the watchers start are forwarded once they started, their
+// outcomes are reported in the state.
+func setKitControlHandlers(ctx workflow.Context, kit string) (*kitControl, error) {
+	k := &kitControl{state: models.KitControlState{Kit: kit}, watchers: make(map[string]workflow.ChildWorkflowFuture)}
+
+	for _, action := range controlActions {
+		if err := workflow.SetUpdateHandlerWithOptions(ctx, string(action),
+			func(ctx workflow.Context, req models.ControlRequest) (models.KitControlState, error) {
+				a := models.ControlAction{Action: action, VPN: req.VPN, Reason: req.Reason, At: workflow.Now(ctx)}
+				k.lastID++
+				a.ID = k.lastID
+				if !k.caughtUp {
+					k.record(ctx, a)
+					return k.snapshot(), nil
+				}
+				outcomes := k.forward(ctx, a)
+				if err := notApplied(outcomes); err != nil {
+					workflow.GetLogger(ctx).Warn("Control action not applied by any watcher", "kit", kit, "vpn", a.VPN, "action", a.Action, "error", err)
+					return models.KitControlState{}, err
+				}
+				k.record(ctx, a)
+				k.state.Outcomes = append(k.state.Outcomes, outcomes...)
+				return k.snapshot(), nil
+			},
+			workflow.UpdateHandlerOptions{
+				Validator: func(ctx workflow.Context, req models.ControlRequest) error {
+					return k.validate(action, req)
+				},
+			},
+		); err != nil {
+			return nil, err
+		}
+	}
+	if err := workflow.SetQueryHandler(ctx, QueryState, func() (models.KitControlState, error) {
+		return k.snapshot(), nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ch := workflow.GetSignalChannel(ctx, controlOutcomeSignal)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			var outcome models.ControlOutcome
+			ch.Receive(ctx, &outcome)
+			k.outcomes = append(k.outcomes, outcome)
+		}
+	})
+	return k, nil
+}
+
+func (k *kitControl) validate(action models.ControlActionType, req models.ControlRequest) error {
+	if !slices.Contains(controlActions, action) {
+		return fmt.Errorf("unknown control action %q", action)
+	}
+	if req.VPN == "" {
+		switch action {
+		case models.ControlExcludeVPN:
+			return fmt.Errorf("%s requires a vpn", action)
+		case models.ControlPause:
+			if k.state.Paused {
+				return fmt.Errorf("kit %s is already paused", k.state.Kit)
+			}
+		case models.ControlResume:
+			if !k.state.Paused {
+				return fmt.Errorf("kit %s is not paused", k.state.Kit)
+			}
+		}
+		return nil
+	}
+	if slices.Contains(k.state.Excluded, req.VPN) {
+		return fmt.Errorf("vpn %s is excluded", req.VPN)
+	}
+	if f, ok := k.watchers[req.VPN]; ok && f.IsReady() {
+		return fmt.Errorf("watcher of vpn %s already finished", req.VPN)
+	}
+	if !k.started() {
+		return nil
+	}
+	if _, ok := k.watchers[req.VPN]; !ok {
+		return fmt.Errorf("kit %s has no watcher for vpn %s", k.state.Kit, req.VPN)
+	}
+	return nil
+}
+
+func (k *kitControl) record(ctx workflow.Context, action models.ControlAction) {
+	if action.VPN == "" {
+		switch action.Action {
+		case models.ControlPause:
+			k.state.Paused = true
+		case models.ControlResume:
+			k.state.Paused = false
+		}
+	}
+	if action.Action == models.ControlExcludeVPN {
+		k.state.Excluded = append(k.state.Excluded, action.VPN)
+	}
+	k.state.Actions = append(k.state.Actions, action)
+	workflow.GetLogger(ctx).Info("Control action accepted", "kit", k.state.Kit, "vpn", action.VPN, "action", action.Action, "reason", action.Reason)
+}
+
+// forward signals the action to the watchers it applies to and, for an
+// action with an ID, returns their outcomes once they reported them.
+// Watchers that already finished are skipped.
+func (k *kitControl) forward(ctx workflow.Context, action models.ControlAction) []models.ControlOutcome {
+	var signaled []string
+	var outcomes []models.ControlOutcome
+	for _, vpn := range k.state.Watchers {
+		if action.VPN != "" && action.VPN != vpn {
+			continue
+		}
+		f := k.watchers[vpn]
+		if f.IsReady() {
+			continue
+		}
+		if err := f.SignalChildWorkflow(ctx, controlSignal, action).Get(ctx, nil); err != nil {
+			workflow.GetLogger(ctx).Warn("Control action not delivered", "kit", k.state.Kit, "vpn", vpn, "action", action.Action, "error", err)
+			outcomes = append(outcomes, models.ControlOutcome{ID: action.ID, VPN: vpn, Action: action.Action, Error: err.Error()})
+			continue
+		}
+		signaled = append(signaled, vpn)
+	}
+	if action.ID == 0 {
+		return nil
+	}
+
+	for _, vpn := range signaled {
+		f := k.watchers[vpn]
+		var outcome *models.ControlOutcome
+		_ = workflow.Await(ctx, func() bool {
+			outcome = k.collect(action.ID, vpn)
+			return outcome != nil || f.IsReady()
+		})
+		if outcome == nil {
+			// a watcher reports its outcomes before it completes
+			outcome = &models.ControlOutcome{ID: action.ID, VPN: vpn, Action: action.Action,
+				Error: fmt.Sprintf("watcher of vpn %s finished before applying the action", vpn)}
+		}
+		outcomes = append(outcomes, *outcome)
+	}
+	return outcomes
+}
+
+// collect takes the outcome reported by the watcher of vpn for the action
+// with the given ID.
+func (k *kitControl) collect(id int, vpn string) *models.ControlOutcome {
+	i := slices.IndexFunc(k.outcomes, func(o models.ControlOutcome) bool { return o.ID == id && o.VPN == vpn })
+	if i < 0 {
+		return nil
+	}
+	outcome := k.outcomes[i]
+	k.outcomes = slices.Delete(k.outcomes, i, i+1)
+	return &outcome
+}
+
+// notApplied returns why no watcher applied an action, nil when one did or
+// when none was running.
+func notApplied(outcomes []models.ControlOutcome) error {
+	var reasons []string
+	for _, o := range outcomes {
+		if o.Error == "" {
+			return nil
+		}
+		reasons = append(reasons, o.Error)
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(reasons, "; "))
+}
+
+// excluded reports whether vpn was excluded before its watcher started.
+func (k *kitControl) excluded(vpn string) bool {
+	return slices.Contains(k.state.Excluded, vpn)
+}
+
+// watch records the watcher of vpn.
+func (k *kitControl) watch(vpn string, f workflow.ChildWorkflowFuture) {
+	k.watchers[vpn] = f
+	k.state.Watchers = append(k.state.Watchers, vpn)
+}
+
+func (k *kitControl) started() bool {
+	return len(k.watchers) > 0
+}
+
+// catchUp forwards the actions accepted so far, in order, once the watchers
+// started. Actions accepted meanwhile are forwarded too.
+func (k *kitControl) catchUp(ctx workflow.Context) {
+	for i := 0; i < len(k.state.Actions); i++ {
+		k.state.Outcomes = append(k.state.Outcomes, k.forward(ctx, k.state.Actions[i])...)
+	}
+	k.caughtUp = true
+}
+
+// drain waits until the control updates in progress completed, before the
+// kit workflow returns.
+func (k *kitControl) drain(ctx workflow.Context) {
+	_ = workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) })
+}
+
+func (k *kitControl) snapshot() models.KitControlState {
+	s := k.state
+	s.Watchers = slices.Clone(s.Watchers)
+	s.Excluded = slices.Clone(s.Excluded)
+	s.Actions = slices.Clone(s.Actions)
+	s.Outcomes = slices.Clone(s.Outcomes)
+	return s
+}
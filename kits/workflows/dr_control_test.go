@@ -0,0 +1,196 @@
+package workflows
+
+import (
+	"context"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// control sends a control update at delay and records whether it was
+// rejected or failed.
+func control(env *testsuite.TestWorkflowEnvironment, delay time.Duration, update string, req models.ControlRequest, rejected *error) {
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(update, update+req.VPN+delay.String(), &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { *rejected = err },
+			OnAccept: func() {},
+			OnComplete: func(_ interface{}, err error) {
+				if err != nil {
+					*rejected = err
+				}
+			},
+		}, req)
+	}, delay)
+}
+
+// mockFailovers mocks the failover children and records when each started.
+func mockFailovers(env *testsuite.TestWorkflowEnvironment) map[string]time.Duration {
+	start := env.Now()
+	started := make(map[string]time.Duration)
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ workflow.Context, vpn string, _, _ *models.MateResult, _ models.BasicAuth, _ models.FailoverOptions) (*models.VPNFailoverResult, error) {
+			started[vpn] = env.Now().Sub(start)
+			return &models.VPNFailoverResult{VPN: vpn}, nil
+		})
+	return started
+}
+
+func watcherState(t *testing.T, env *testsuite.TestWorkflowEnvironment) models.WatcherState {
+	t.Helper()
+	val, err := env.QueryWorkflow(QueryState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state models.WatcherState
+	if err := val.Get(&state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestVPNDNSWatch_PauseResume(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders", []string{"10.20.1.1"}, []string{"10.20.1.2"})
+	started := mockFailovers(env)
+
+	var rejected, pauseRejected, otherVPN error
+	control(env, time.Second, UpdatePause, models.ControlRequest{Reason: "maintenance"}, &rejected)
+	control(env, 2*time.Second, UpdatePause, models.ControlRequest{}, &pauseRejected)
+	control(env, 3*time.Second, UpdatePause, models.ControlRequest{VPN: "billing"}, &otherVPN)
+	control(env, 25*time.Second, UpdateResume, models.ControlRequest{}, &rejected)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{SiteAddresses: testSites, DNSQuorum: models.DNSQuorumOptions{StablePolls: 2}})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if rejected != nil || pauseRejected == nil || otherVPN == nil {
+		t.Errorf("expected pause and resume accepted, a second pause and another vpn rejected, got %v, %v, %v", rejected, pauseRejected, otherVPN)
+	}
+	// the poll at 10s is paused, the count restarts at 30s
+	if started["orders"] != 40*time.Second {
+		t.Errorf("expected the failover after 40s, got %s", started["orders"])
+	}
+	state := watcherState(t, env)
+	if state.Paused || !state.FailoverStarted || len(state.Actions) != 2 || state.Actions[0].Reason != "maintenance" {
+		t.Errorf("unexpected state %+v", state)
+	}
+}
+
+func TestVPNDNSWatch_ForceFailover(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders", []string{"10.10.1.1"}, []string{"10.10.1.2"})
+	started := mockFailovers(env)
+
+	var rejected, again error
+	control(env, 5*time.Second, UpdateForceFailover, models.ControlRequest{VPN: "orders", Reason: "dc1 lost"}, &rejected)
+	control(env, 5*time.Second, UpdateExcludeVPN, models.ControlRequest{}, &again)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{SiteAddresses: testSites})
+
+	var result models.VPNFailoverResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if rejected != nil {
+		t.Fatal(rejected)
+	}
+	if started["orders"] != 5*time.Second {
+		t.Errorf("expected the failover as soon as forced, got %s", started["orders"])
+	}
+	if result.Forced == nil || result.Forced.Reason != "dc1 lost" || result.DNSTrigger != nil {
+		t.Errorf("expected the forced failover reported, got %+v", result)
+	}
+	if again == nil {
+		t.Error("expected the exclusion of a VPN failing over to be rejected")
+	}
+}
+
+func TestVPNDNSWatch_ExcludeVPN(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders", []string{"10.10.1.1"}, []string{"10.10.1.2"})
+	mockFailovers(env)
+
+	var rejected error
+	control(env, 5*time.Second, UpdateExcludeVPN, models.ControlRequest{}, &rejected)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{SiteAddresses: testSites})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if rejected != nil {
+		t.Fatal(rejected)
+	}
+	if state := watcherState(t, env); !state.Excluded {
+		t.Errorf("expected the vpn excluded, got %+v", state)
+	}
+	env.AssertWorkflowNotCalled(t, "VPNFailoverWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestKitDRWorkflow_Control(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, []string{"orders", "billing"})
+	// records stay on the active site, only operators end the watchers
+	env.OnActivity(activities.ResolveDNSQuorumActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, name string, _ int) (*models.DNSQuorumAnswer, error) {
+			return &models.DNSQuorumAnswer{Name: name, Quorum: 1, Votes: 1, Agreed: &models.DNSRecordSet{Name: name, Addresses: []string{"10.10.1.1"}}}, nil
+		})
+	started := mockFailovers(env)
+
+	var rejected, unknownVPN, noVPN, pausedVPN error
+	control(env, time.Second, UpdatePause, models.ControlRequest{Reason: "maintenance"}, &rejected)
+	control(env, 1500*time.Millisecond, UpdatePause, models.ControlRequest{VPN: "orders"}, &pausedVPN)
+	control(env, 2*time.Second, UpdateExcludeVPN, models.ControlRequest{VPN: "billing"}, &rejected)
+	control(env, 3*time.Second, UpdateExcludeVPN, models.ControlRequest{VPN: "payments"}, &unknownVPN)
+	control(env, 4*time.Second, UpdateExcludeVPN, models.ControlRequest{}, &noVPN)
+	control(env, 5*time.Second, UpdateForceFailover, models.ControlRequest{VPN: "orders"}, &rejected)
+
+	outcome := runKitDR(t, env)
+
+	if rejected != nil || unknownVPN == nil || noVPN == nil {
+		t.Errorf("expected the unknown vpn and the exclusion without vpn rejected only, got %v, %v, %v", rejected, unknownVPN, noVPN)
+	}
+	if pausedVPN == nil || !strings.Contains(pausedVPN.Error(), "already paused") {
+		t.Errorf("expected the pause of a paused watcher to fail, got %v", pausedVPN)
+	}
+	if len(outcome.Failovers) != 1 || outcome.Failovers[0].VPN != "orders" || outcome.Failovers[0].Forced == nil {
+		t.Errorf("expected only orders forced over, got %+v", outcome.Failovers)
+	}
+	if started["orders"] != 5*time.Second {
+		t.Errorf("expected the forced failover at 5s, got %s", started["orders"])
+	}
+	if len(outcome.Actions) != 3 {
+		t.Errorf("expected the accepted actions in the outcome, got %+v", outcome.Actions)
+	}
+
+	val, err := env.QueryWorkflow(QueryState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state models.KitControlState
+	if err := val.Get(&state); err != nil {
+		t.Fatal(err)
+	}
+	if !state.Paused || len(state.Watchers) != 2 || len(state.Excluded) != 1 || state.Excluded[0] != "billing" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	// the kit pause reached both watchers, the exclusion and the force one
+	if len(state.Outcomes) != 4 {
+		t.Fatalf("expected an outcome per watcher of each action, got %+v", state.Outcomes)
+	}
+	for _, o := range state.Outcomes {
+		if o.Error != "" || o.ID == 0 {
+			t.Errorf("expected every accepted action applied, got %+v", o)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// UpdateDRPolicy is the update changing the DR policy of a running DNS
+// watcher. The non-zero values of the argument replace the current ones and
+// the policy in force is returned. It applies from the next poll and to the
+// failover the watcher starts.
+const UpdateDRPolicy = "updatePolicy"
+
+// resolveDRPolicy validates the policy of a run and applies the defaults.
+func resolveDRPolicy(p models.DRPolicy) (models.DRPolicy, error) {
+	if err := validateDRPolicy(p); err != nil {
+		return models.DRPolicy{}, err
+	}
+	return drPolicy(p), nil
+}
+
+// drPolicy applies the config defaults to the unset values, ie of runs
+// started before the policy existed.
+func drPolicy(p models.DRPolicy) models.DRPolicy {
+	duration := func(v *int, def time.Duration) {
+		if *v == 0 {
+			*v = int(def / time.Second)
+		}
+	}
+	count := func(v *int, def int) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+	duration(&p.ActivityTimeoutSeconds, config.DR_ACTIVITY_TIMEOUT)
+	duration(&p.DNSPollMinSeconds, config.DNS_WATCH_MIN_INTERVAL)
+	duration(&p.DNSPollMaxSeconds, config.DNS_WATCH_MAX_INTERVAL)
+	duration(&p.WatchTimeoutSeconds, config.DNS_WATCH_ACTIVITY_TIMEOUT)
+	count(&p.WatchMaxAttempts, config.DNS_WATCH_ACTIVITY_MAX_ATTEMPTS)
+	duration(&p.FailoverTimeoutSeconds, config.FAILOVER_ACTIVITY_TIMEOUT)
+	count(&p.FailoverMaxAttempts, config.FAILOVER_ACTIVITY_MAX_ATTEMPTS)
+	duration(&p.DrainTimeoutSeconds, config.FAILOVER_DRAIN_TIMEOUT)
+	count(&p.DrainMaxAttempts, config.FAILOVER_DRAIN_MAX_ATTEMPTS)
+	duration(&p.DrainIntervalSeconds, config.FAILOVER_DRAIN_RETRY_INTERVAL)
+	return p
+}
+
+// validateDRPolicy rejects negative values and a DNS polling range that is
+// empty once the defaults are applied.
+func validateDRPolicy(p models.DRPolicy) error {
+	var errs []error
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"activityTimeoutSeconds", p.ActivityTimeoutSeconds},
+		{"dnsPollMinSeconds", p.DNSPollMinSeconds},
+		{"dnsPollMaxSeconds", p.DNSPollMaxSeconds},
+		{"watchTimeoutSeconds", p.WatchTimeoutSeconds},
+		{"watchMaxAttempts", p.WatchMaxAttempts},
+		{"failoverTimeoutSeconds", p.FailoverTimeoutSeconds},
+		{"failoverMaxAttempts", p.FailoverMaxAttempts},
+		{"drainTimeoutSeconds", p.DrainTimeoutSeconds},
+		{"drainMaxAttempts", p.DrainMaxAttempts},
+		{"drainIntervalSeconds", p.DrainIntervalSeconds},
+	} {
+		if f.value < 0 {
+			errs = append(errs, fmt.Errorf("policy.%s must not be negative, got %d", f.name, f.value))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if d := drPolicy(p); d.DNSPollMinSeconds > d.DNSPollMaxSeconds {
+		return fmt.Errorf("policy.dnsPollMinSeconds (%d) must not exceed dnsPollMaxSeconds (%d)", d.DNSPollMinSeconds, d.DNSPollMaxSeconds)
+	}
+	return nil
+}
+
+// validateDNSQuorum rejects negative quorum settings, zero keeps the
+// defaults of the watchers.
+func validateDNSQuorum(q models.DNSQuorumOptions) error {
+	if q.Quorum < 0 {
+		return fmt.Errorf("dnsQuorum.quorum must not be negative, got %d", q.Quorum)
+	}
+	if q.StablePolls < 0 {
+		return fmt.Errorf("dnsQuorum.stablePolls must not be negative, got %d", q.StablePolls)
+	}
+	return nil
+}
+
+// mergeDRPolicy returns current with the non-zero values of update.
+func mergeDRPolicy(current, update models.DRPolicy) models.DRPolicy {
+	set := func(v *int, u int) {
+		if u != 0 {
+			*v = u
+		}
+	}
+	set(&current.ActivityTimeoutSeconds, update.ActivityTimeoutSeconds)
+	set(&current.DNSPollMinSeconds, update.DNSPollMinSeconds)
+	set(&current.DNSPollMaxSeconds, update.DNSPollMaxSeconds)
+	set(&current.WatchTimeoutSeconds, update.WatchTimeoutSeconds)
+	set(&current.WatchMaxAttempts, update.WatchMaxAttempts)
+	set(&current.FailoverTimeoutSeconds, update.FailoverTimeoutSeconds)
+	set(&current.FailoverMaxAttempts, update.FailoverMaxAttempts)
+	set(&current.DrainTimeoutSeconds, update.DrainTimeoutSeconds)
+	set(&current.DrainMaxAttempts, update.DrainMaxAttempts)
+	set(&current.DrainIntervalSeconds, update.DrainIntervalSeconds)
+	return current
+}
+
+// setDRPolicyUpdateHandler lets operators change the policy of a running
+// watcher. An update leaving an invalid policy is rejected before it is
+// recorded.
+func setDRPolicyUpdateHandler(ctx workflow.Context, policy *models.DRPolicy) error {
+	return workflow.SetUpdateHandlerWithOptions(ctx, UpdateDRPolicy,
+		func(ctx workflow.Context, update models.DRPolicy) (models.DRPolicy, error) {
+			*policy = mergeDRPolicy(*policy, update)
+			workflow.GetLogger(ctx).Info("DR policy updated", "policy", *policy)
+			return *policy, nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, update models.DRPolicy) error {
+				if err := validateDRPolicy(update); err != nil {
+					return err
+				}
+				return validateDRPolicy(mergeDRPolicy(*policy, update))
+			},
+		},
+	)
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// runActivityOptions are the activity options of the DR run and of each kit.
+func runActivityOptions(p models.DRPolicy) workflow.ActivityOptions {
+	return workflow.ActivityOptions{StartToCloseTimeout: seconds(p.ActivityTimeoutSeconds)}
+}
+
+// watchActivityOptions are the activity options of the DNS resolutions.
+func watchActivityOptions(p models.DRPolicy) workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: seconds(p.WatchTimeoutSeconds),
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts:    int32(p.WatchMaxAttempts),
+			InitialInterval:    time.Second * 5,
+			BackoffCoefficient: 2.0,
+		},
+	}
+}
+
+// failoverActivityOptions are the activity options of the failover steps.
+func failoverActivityOptions(p models.DRPolicy) workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: seconds(p.FailoverTimeoutSeconds),
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: int32(p.FailoverMaxAttempts),
+		},
+	}
+}
+
+// drainActivityOptions are the activity options of the replication queue
+// drain wait. The checks are evenly spaced, without backoff.
+func drainActivityOptions(p models.DRPolicy) workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: seconds(p.DrainTimeoutSeconds),
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts:    int32(p.DrainMaxAttempts),
+			InitialInterval:    seconds(p.DrainIntervalSeconds),
+			BackoffCoefficient: 1.0,
+		},
+	}
+}
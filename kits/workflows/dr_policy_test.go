@@ -0,0 +1,53 @@
+package workflows
+
+import (
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+)
+
+func TestResolveDRPolicy(t *testing.T) {
+	policy, err := resolveDRPolicy(models.DRPolicy{DNSPollMinSeconds: 5, DrainMaxAttempts: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.DNSPollMinSeconds != 5 || policy.DrainMaxAttempts != 20 {
+		t.Errorf("expected the given values to be kept, got %+v", policy)
+	}
+	if seconds(policy.DNSPollMaxSeconds) != config.DNS_WATCH_MAX_INTERVAL ||
+		seconds(policy.FailoverTimeoutSeconds) != config.FAILOVER_ACTIVITY_TIMEOUT ||
+		policy.WatchMaxAttempts != config.DNS_WATCH_ACTIVITY_MAX_ATTEMPTS {
+		t.Errorf("expected the defaults for unset values, got %+v", policy)
+	}
+
+	for _, c := range []struct {
+		policy models.DRPolicy
+		err    string
+	}{
+		{models.DRPolicy{FailoverMaxAttempts: -1}, "failoverMaxAttempts"},
+		{models.DRPolicy{DNSPollMinSeconds: 120}, "must not exceed"},
+		{models.DRPolicy{DNSPollMinSeconds: 30, DNSPollMaxSeconds: 20}, "must not exceed"},
+	} {
+		if _, err := resolveDRPolicy(c.policy); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%+v: expected %q, got %v", c.policy, c.err, err)
+		}
+	}
+}
+
+func TestMergeDRPolicy(t *testing.T) {
+	current := drPolicy(models.DRPolicy{})
+	merged := mergeDRPolicy(current, models.DRPolicy{WatchTimeoutSeconds: 45})
+	current.WatchTimeoutSeconds = 45
+	if merged != current {
+		t.Errorf("expected only watchTimeoutSeconds to change, got %+v", merged)
+	}
+}
+
+func TestDrainActivityOptions(t *testing.T) {
+	ao := drainActivityOptions(drPolicy(models.DRPolicy{}))
+	if ao.RetryPolicy.BackoffCoefficient != 1 || ao.RetryPolicy.InitialInterval != config.FAILOVER_DRAIN_RETRY_INTERVAL ||
+		ao.RetryPolicy.MaximumAttempts != config.FAILOVER_DRAIN_MAX_ATTEMPTS {
+		t.Errorf("expected %d drain checks %s apart, got %+v", config.FAILOVER_DRAIN_MAX_ATTEMPTS, config.FAILOVER_DRAIN_RETRY_INTERVAL, ao.RetryPolicy)
+	}
+}
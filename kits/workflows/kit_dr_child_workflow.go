@@ -2,7 +2,6 @@ package workflows
 
 import (
 	"errors"
-	"time"
 
 	"kits-worker/kits/activities"
 	"kits-worker/kits/models"
@@ -11,23 +10,42 @@ import (
 	"go.temporal.io/sdk/workflow"
 )
 
+// siteAddressesVersion marks runs resolving the VPN addresses of each DC for
+// the DNS watchers.
+const siteAddressesVersion = "site-addresses"
+
 func KitDRWorkflow(
 	ctx workflow.Context,
 	kitName string,
 	kit models.Kit,
 	auth models.BasicAuth,
-) ([]models.MateResult, error) {
+	opts models.FailoverOptions,
+) (models.KitOutcome, error) {
+
+	outcome := models.KitOutcome{Kit: kitName}
+
+	ctl, err := setKitControlHandlers(ctx, kitName)
+	if err != nil {
+		return outcome, err
+	}
+	opts.Kit = kitName
+	opts.Maintenance = maintenanceWindowsOf(opts.Maintenance, kitName)
 
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
+	// fail records a kit level failure; mate results gathered so far are kept
+	fail := func(err error) (models.KitOutcome, error) {
+		outcome.Error = err.Error()
+		ctl.drain(ctx)
+		outcome.Actions = ctl.snapshot().Actions
+		summarizeMates(&outcome)
+		return outcome, nil
 	}
+
+	ao := runActivityOptions(drPolicy(opts.Policy))
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
-	checkAO := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
-		RetryPolicy: &temporal.RetryPolicy{
-			MaximumAttempts: 1, // ⬅️ CRITICAL
-		},
+	checkAO := ao
+	checkAO.RetryPolicy = &temporal.RetryPolicy{
+		MaximumAttempts: 1, // ⬅️ CRITICAL
 	}
 
 	checkCtx := workflow.WithActivityOptions(ctx, checkAO)
@@ -49,6 +67,7 @@ func KitDRWorkflow(
 	}
 
 	var active []models.MateResult
+	servingDC := make(map[string]bool)
 
 	for _, mc := range mateChecks {
 		var r models.MateResult
@@ -62,16 +81,65 @@ func KitDRWorkflow(
 			auth,
 		).Get(checkCtx, &r)
 
-		if err == nil && r.Status == models.Active {
+		if err != nil {
+			// unreachable mates are DOWN, the error tells why
+			r = models.MateResult{
+				Kit:    kitName,
+				DC:     mc.dc,
+				Mate:   mc.mate,
+				Host:   mc.ep.Host,
+				Port:   mc.ep.Port,
+				Status: models.Down,
+				Error:  err.Error(),
+			}
+		}
+		outcome.Mates = append(outcome.Mates, r)
+
+		// a site is healthy when one of its mates serves clients; only the
+		// first serving mate of a site is used for VPN discovery
+		if r.Serving() && !servingDC[mc.dc] {
+			servingDC[mc.dc] = true
 			active = append(active, r)
 		}
 	}
 
+	for _, m := range outcome.Mates {
+		if m.Status == models.ADStandby {
+			workflow.GetLogger(ctx).Warn(
+				"Mate is Local Active but its message spool is AD-Standby",
+				"kit", kitName, "dc", m.DC, "mate", m.Mate,
+			)
+		}
+	}
+
 	if len(active) == 0 {
-		return nil, errors.New("no active mates found after 3 retries")
+		return fail(errors.New("no active mates found after 3 retries"))
 	}
 
-	has2ActiveMate := len(active) == 2
+	has2ActiveMate := servingDC["dc1"] && servingDC["dc2"]
+	workflow.GetLogger(ctx).Info(
+		"Site health evaluated",
+		"kit", kitName,
+		"dc1Serving", servingDC["dc1"],
+		"dc2Serving", servingDC["dc2"],
+	)
+
+	// Discovery relies on SEMP v2 where= filters on replication attributes
+	if workflow.GetVersion(ctx, sempCompatVersion, workflow.DefaultVersion, 1) == 1 {
+		activeRefs := make([]*models.MateResult, 0, len(active))
+		for i := range active {
+			activeRefs = append(activeRefs, &active[i])
+		}
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.CheckSEMPCompatibilityActivity,
+			activities.SEMPOperationDiscovery,
+			activeRefs,
+			auth,
+		).Get(ctx, nil); err != nil {
+			return fail(err)
+		}
+	}
 
 	/*
 		2. Build VPN maps from ACTIVE mates
@@ -90,7 +158,7 @@ func KitDRWorkflow(
 			"active",
 			auth,
 		).Get(ctx, &vpnsActive); err != nil {
-			return nil, err
+			return fail(err)
 		}
 
 		for _, vpn := range vpnsActive {
@@ -107,7 +175,7 @@ func KitDRWorkflow(
 			"standby",
 			auth,
 		).Get(ctx, &vpnsStandby); err != nil {
-			return nil, err
+			return fail(err)
 		}
 
 		for _, vpn := range vpnsStandby {
@@ -115,28 +183,45 @@ func KitDRWorkflow(
 		}
 	}
 
+	// the DNS watchers fail over toward the DC the VPN records point to
+	if workflow.GetVersion(ctx, siteAddressesVersion, workflow.DefaultVersion, 1) == 1 {
+		var sites map[string][]string
+		if err := workflow.ExecuteActivity(ctx, activities.ResolveSiteAddressesActivity, kit).Get(ctx, &sites); err != nil {
+			workflow.GetLogger(ctx).Warn("VPN addresses of the sites unknown, DNS watchers follow record changes", "kit", kitName, "error", err)
+		}
+		opts.SiteAddresses = sites
+	}
+
 	/*
 		3. Start DNS watcher child workflows
 	*/
 	var dnsFutures []workflow.ChildWorkflowFuture
+	var dnsVPNs []string
 
 	if has2ActiveMate {
 		// Normal path: VPNs from active map
-		for vpn := range vpnMapActive {
+		for _, vpn := range sortedVPNNames(vpnMapActive) {
 			vpnCopy := vpn
+			if ctl.excluded(vpnCopy) {
+				workflow.GetLogger(ctx).Warn("VPN excluded, not watched", "kit", kitName, "vpn", vpnCopy)
+				continue
+			}
 			m := vpnMapActive[vpnCopy]
 			activeMate := &m
 			standbyMate := vpnMapStandby[vpnCopy]
 
 			f := workflow.ExecuteChildWorkflow(
-				ctx,
+				withChildWorkflowID(ctx, vpnDNSWatchWorkflowID(kitName, vpnCopy)),
 				VPNDNSWatchAndExecuteVPNFailoverWorkflow,
 				vpnCopy,
 				activeMate,
 				standbyMate,
 				auth,
+				opts,
 			)
 			dnsFutures = append(dnsFutures, f)
+			dnsVPNs = append(dnsVPNs, vpnCopy)
+			ctl.watch(vpnCopy, f)
 		}
 	} else {
 		if len(vpnMapStandby) == 0 {
@@ -150,30 +235,64 @@ func KitDRWorkflow(
 					"Proceeding with activating Standby Site without access to Active Site",
 			)
 			// No active mates at all → VPNs from standby map
-			for vpn := range vpnMapStandby {
+			for _, vpn := range sortedVPNNames(vpnMapStandby) {
 				vpnCopy := vpn
+				if ctl.excluded(vpnCopy) {
+					workflow.GetLogger(ctx).Warn("VPN excluded, not watched", "kit", kitName, "vpn", vpnCopy)
+					continue
+				}
 				var activeMate *models.MateResult = nil
 				standbyMate := vpnMapStandby[vpnCopy]
 
 				f := workflow.ExecuteChildWorkflow(
-					ctx,
+					withChildWorkflowID(ctx, vpnDNSWatchWorkflowID(kitName, vpnCopy)),
 					VPNDNSWatchAndExecuteVPNFailoverWorkflow,
 					vpnCopy,
 					activeMate,
 					standbyMate,
 					auth,
+					opts,
 				)
 				dnsFutures = append(dnsFutures, f)
+				dnsVPNs = append(dnsVPNs, vpnCopy)
+				ctl.watch(vpnCopy, f)
 			}
 		}
 	}
 
+	// operator actions accepted so far reach the watchers
+	ctl.catchUp(ctx)
+
 	/*
 		4. Optional wait (safe even for long-running children)
 	*/
-	for _, f := range dnsFutures {
-		_ = f.Get(ctx, nil)
+	for i, f := range dnsFutures {
+		var failover *models.VPNFailoverResult
+		if err := f.Get(ctx, &failover); temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			workflow.GetLogger(ctx).Warn("VPN already watched by another run", "kit", kitName, "vpn", dnsVPNs[i],
+				"workflowID", vpnDNSWatchWorkflowID(kitName, dnsVPNs[i]))
+		} else if err != nil {
+			// a failed failover carries its partial report as error details
+			var appErr *temporal.ApplicationError
+			var partial models.VPNFailoverResult
+			if errors.As(err, &appErr) && appErr.HasDetails() && appErr.Details(&partial) == nil {
+				failover = &partial
+			}
+			workflow.GetLogger(ctx).Error("VPN failover failed", "kit", kitName, "vpn", dnsVPNs[i], "error", err)
+			if !temporal.IsCanceledError(err) {
+				if failover == nil {
+					failover = &models.VPNFailoverResult{VPN: dnsVPNs[i]}
+				}
+				failover.Error = err.Error()
+			}
+		}
+		if failover != nil {
+			outcome.Failovers = append(outcome.Failovers, *failover)
+		}
 	}
 
-	return active, nil
+	ctl.drain(ctx)
+	outcome.Actions = ctl.snapshot().Actions
+	summarizeMates(&outcome)
+	return outcome, nil
 }
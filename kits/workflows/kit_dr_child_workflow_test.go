@@ -0,0 +1,180 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+var testKit = models.Kit{
+	DC1: models.DC{Mate1: models.Endpoint{Host: "dc1-mate1", Port: 8080}, Mate2: models.Endpoint{Host: "dc1-mate2", Port: 8080}},
+	DC2: models.DC{Mate1: models.Endpoint{Host: "dc2-mate1", Port: 8080}, Mate2: models.Endpoint{Host: "dc2-mate2", Port: 8080}},
+}
+
+// testSites are the VPN addresses of the testKit DCs.
+var testSites = map[string][]string{"dc1": {"10.10.0.0/16"}, "dc2": {"10.20.0.0/16"}}
+
+// mockKit answers the mate checks, VPN discovery and site addresses of
+// testKit. Hosts in down are unreachable; dc1 holds the active VPNs and dc2
+// the standby ones.
+func mockKit(env *testsuite.TestWorkflowEnvironment, vpns []string, down ...string) {
+	isDown := make(map[string]bool)
+	for _, h := range down {
+		isDown[h] = true
+	}
+
+	env.OnActivity(activities.CheckMateStatusActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, kit, dc, mate string, ep models.Endpoint, _ models.BasicAuth) (models.MateResult, error) {
+			if isDown[ep.Host] {
+				return models.MateResult{}, errors.New("dial tcp " + ep.Host + ": connection refused")
+			}
+			status := models.Active
+			if mate == "mate2" {
+				status = models.Standby
+			}
+			return models.MateResult{Kit: kit, DC: dc, Mate: mate, Host: ep.Host, Port: ep.Port, Status: status}, nil
+		})
+	env.OnActivity(activities.CheckSEMPCompatibilityActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]models.AboutResult{}, nil)
+	env.OnActivity(activities.GetRoleVPNsActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, host string, _ int, role string, _ models.BasicAuth) ([]string, error) {
+			if (host == "dc1-mate1") == (role == "active") {
+				return vpns, nil
+			}
+			return nil, nil
+		})
+	env.OnActivity(activities.ResolveSiteAddressesActivity, mock.Anything, mock.Anything).
+		Return(testSites, nil)
+}
+
+// watchCalls mocks the DNS watcher children and records the mates each
+// was started with.
+type watchCall struct {
+	vpn             string
+	active, standby *models.MateResult
+	opts            models.FailoverOptions
+}
+
+func mockWatchers(env *testsuite.TestWorkflowEnvironment) *[]watchCall {
+	var calls []watchCall
+	env.OnWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ workflow.Context, vpn string, active, standby *models.MateResult, _ models.BasicAuth, opts models.FailoverOptions) (*models.VPNFailoverResult, error) {
+			calls = append(calls, watchCall{vpn, active, standby, opts})
+			return &models.VPNFailoverResult{VPN: vpn}, nil
+		})
+	return &calls
+}
+
+func runKitDR(t *testing.T, env *testsuite.TestWorkflowEnvironment) models.KitOutcome {
+	t.Helper()
+	env.ExecuteWorkflow(KitDRWorkflow, "kit1", testKit, models.BasicAuth{}, models.FailoverOptions{})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var outcome models.KitOutcome
+	if err := env.GetWorkflowResult(&outcome); err != nil {
+		t.Fatal(err)
+	}
+	return outcome
+}
+
+func TestKitDRWorkflow_BothSitesUp(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, []string{"orders", "billing"})
+	calls := mockWatchers(env)
+
+	outcome := runKitDR(t, env)
+
+	if outcome.Status != models.RunSuccess || len(outcome.Mates) != 4 {
+		t.Errorf("unexpected outcome %+v", outcome)
+	}
+	if len(*calls) != 2 {
+		t.Fatalf("expected 2 DNS watchers, got %d", len(*calls))
+	}
+	for _, c := range *calls {
+		if c.active == nil || c.active.Host != "dc1-mate1" || c.standby == nil || c.standby.Host != "dc2-mate1" {
+			t.Errorf("vpn %s: unexpected mates %+v -> %+v", c.vpn, c.active, c.standby)
+		}
+		if got := c.opts.SiteAddresses["dc2"]; len(got) != 1 || got[0] != "10.20.0.0/16" {
+			t.Errorf("vpn %s: expected the site addresses passed to the watcher, got %v", c.vpn, c.opts.SiteAddresses)
+		}
+	}
+	if len(outcome.Failovers) != 2 || outcome.Failovers[0].VPN != "billing" || outcome.Failovers[1].VPN != "orders" {
+		t.Errorf("expected failovers in VPN order, got %+v", outcome.Failovers)
+	}
+}
+
+func TestKitDRWorkflow_ActiveSiteDown(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, []string{"orders"}, "dc1-mate1", "dc1-mate2")
+	calls := mockWatchers(env)
+
+	outcome := runKitDR(t, env)
+
+	if outcome.Status != models.RunPartial || outcome.FailureCount != 2 {
+		t.Errorf("unexpected outcome %+v", outcome)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("expected 1 DNS watcher, got %d", len(*calls))
+	}
+	if c := (*calls)[0]; c.vpn != "orders" || c.active != nil || c.standby.Host != "dc2-mate1" {
+		t.Errorf("expected orders to fail over to dc2 without active site, got %+v", c)
+	}
+}
+
+func TestKitDRWorkflow_StandbySiteDown(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, []string{"orders"}, "dc2-mate1", "dc2-mate2")
+	calls := mockWatchers(env)
+
+	outcome := runKitDR(t, env)
+
+	if outcome.Status != models.RunPartial {
+		t.Errorf("unexpected outcome %+v", outcome)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("expected no DNS watcher without a site to fail over to, got %+v", *calls)
+	}
+}
+
+func TestKitDRWorkflow_AllMatesDown(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, nil, "dc1-mate1", "dc1-mate2", "dc2-mate1", "dc2-mate2")
+	mockWatchers(env)
+
+	outcome := runKitDR(t, env)
+
+	if outcome.Status != models.RunFailed || outcome.Error == "" {
+		t.Errorf("unexpected outcome %+v", outcome)
+	}
+}
+
+func TestKitDRWorkflow_MaintenanceOfKit(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, []string{"orders"})
+	calls := mockWatchers(env)
+
+	env.ExecuteWorkflow(KitDRWorkflow, "kit1", testKit, models.BasicAuth{}, models.FailoverOptions{
+		Maintenance: []models.MaintenanceWindow{
+			{Name: "all", Cron: "@daily", DurationMinutes: 60},
+			{Name: "kit1", Kit: "kit1", Cron: "@daily", DurationMinutes: 60},
+			{Name: "kit2", Kit: "kit2", Cron: "@daily", DurationMinutes: 60},
+		},
+	})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("expected 1 DNS watcher, got %d", len(*calls))
+	}
+	if w := (*calls)[0].opts.Maintenance; len(w) != 2 || w[0].Name != "all" || w[1].Name != "kit1" {
+		t.Errorf("expected the windows of kit1 passed to the watcher, got %+v", w)
+	}
+}
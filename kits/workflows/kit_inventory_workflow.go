@@ -0,0 +1,90 @@
+package workflows
+
+import (
+	"fmt"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// KitInventoryWorkflow inventories the four mates of a kit in parallel. A mate
+// that cannot be reached is recorded with its error instead of failing the kit.
+func KitInventoryWorkflow(
+	ctx workflow.Context,
+	kitName string,
+	kit models.Kit,
+	auth models.BasicAuth,
+) (models.KitInventory, error) {
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second * 5,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    2,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	type mateJob struct {
+		dc, mate string
+		ep       models.Endpoint
+	}
+
+	jobs := []mateJob{
+		{"dc1", "mate1", kit.DC1.Mate1},
+		{"dc1", "mate2", kit.DC1.Mate2},
+		{"dc2", "mate1", kit.DC2.Mate1},
+		{"dc2", "mate2", kit.DC2.Mate2},
+	}
+
+	futures := make([]workflow.Future, 0, len(jobs))
+	for _, job := range jobs {
+		j := job // capture loop variable
+		f := workflow.ExecuteActivity(
+			ctx,
+			activities.BrokerInventoryActivity,
+			kitName,
+			j.dc,
+			j.mate,
+			j.ep,
+			auth,
+		)
+		futures = append(futures, f)
+	}
+
+	result := models.KitInventory{
+		Kit:        kitName,
+		TotalCount: len(jobs),
+	}
+
+	for i, f := range futures {
+		job := jobs[i]
+		var inv models.BrokerInventory
+		if err := f.Get(ctx, &inv); err != nil {
+			inv = models.BrokerInventory{
+				AboutResult: models.AboutResult{
+					Kit:  kitName,
+					DC:   job.dc,
+					Mate: job.mate,
+					Host: job.ep.Host,
+					Port: job.ep.Port,
+				},
+				Error: err.Error(),
+			}
+		} else {
+			result.SuccessCount++
+		}
+		result.Mates = append(result.Mates, inv)
+	}
+
+	workflow.GetLogger(ctx).Info(
+		fmt.Sprintf("Inventory success %d/%d for kit %s", result.SuccessCount, result.TotalCount, kitName),
+	)
+
+	return result, nil
+}
@@ -10,12 +10,16 @@ import (
 	"go.temporal.io/sdk/workflow"
 )
 
+// KitSolaceAPIWorkflow calls the SEMP about API on every mate of a kit. A mate
+// that does not answer is recorded as failed instead of failing the kit,
+// the kit status is SUCCESS, PARTIAL or FAILED depending on how many answered
+// and About lists the platform and SEMP versions of the mates that did.
 func KitSolaceAPIWorkflow(
 	ctx workflow.Context,
 	kitName string,
 	kit models.Kit,
 	auth models.BasicAuth,
-) error {
+) (models.KitOutcome, error) {
 
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute,
@@ -56,25 +60,50 @@ func KitSolaceAPIWorkflow(
 		futures = append(futures, f)
 	}
 
-	// Track success count
-	successCount := 0
+	outcome := models.KitOutcome{Kit: kitName}
 
 	for i, f := range futures {
 		var about *activities.AboutResponse
 		job := jobs[i]
+		mate := models.MateResult{
+			Kit:  kitName,
+			DC:   job.dc,
+			Mate: job.mate,
+			Host: job.ep.Host,
+			Port: job.ep.Port,
+		}
 		if err := f.Get(ctx, &about); err != nil {
-			return fmt.Errorf(
-				"BrokerSEMPApiAboutActivity failed for kit=%s dc=%s mate=%s host=%s: %w",
-				kitName, job.dc, job.mate, job.ep.Host, err,
+			workflow.GetLogger(ctx).Warn(
+				"BrokerSEMPApiAboutActivity failed",
+				"kit", kitName,
+				"dc", job.dc,
+				"mate", job.mate,
+				"host", job.ep.Host,
+				"error", err,
 			)
+			mate.Error = err.Error()
+		} else {
+			outcome.About = append(outcome.About, models.AboutResult{
+				Kit:         kitName,
+				DC:          job.dc,
+				Mate:        job.mate,
+				Host:        job.ep.Host,
+				Port:        job.ep.Port,
+				Platform:    about.Platform,
+				SempVersion: about.SempVersion,
+				ApiVersion:  about.ApiVersion,
+			})
 		}
-		successCount++
+		outcome.Mates = append(outcome.Mates, mate)
 	}
 
+	summarizeMates(&outcome)
+
 	// Final summary log only
 	workflow.GetLogger(ctx).Info(
-		fmt.Sprintf("Success %d/%d for kit %s", successCount, len(jobs), kitName),
+		fmt.Sprintf("Success %d/%d for kit %s", outcome.SuccessCount, len(jobs), kitName),
+		"status", outcome.Status,
 	)
 
-	return nil
+	return outcome, nil
 }
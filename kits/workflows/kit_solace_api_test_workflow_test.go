@@ -0,0 +1,44 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestKitSolaceAPIWorkflow_ReportsAbout(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(KitSolaceAPIWorkflow)
+	env.RegisterActivity(activities.BrokerSEMPApiAboutActivity)
+	env.OnActivity(activities.BrokerSEMPApiAboutActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, _, _, _ string, ep models.Endpoint, _ models.BasicAuth) (*activities.AboutResponse, error) {
+			if ep.Host == "dc2-mate2" {
+				return nil, errors.New("dial tcp dc2-mate2: connection refused")
+			}
+			return &activities.AboutResponse{Platform: "Appliance", SempVersion: "2.36", ApiVersion: "v2"}, nil
+		})
+
+	env.ExecuteWorkflow(KitSolaceAPIWorkflow, "kit-a", testKit, models.BasicAuth{})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var outcome models.KitOutcome
+	if err := env.GetWorkflowResult(&outcome); err != nil {
+		t.Fatal(err)
+	}
+
+	if outcome.Status != models.RunPartial || len(outcome.About) != 3 {
+		t.Fatalf("expected a partial outcome with 3 about results, got %+v", outcome)
+	}
+	for _, a := range outcome.About {
+		if a.Host == "dc2-mate2" || a.Platform != "Appliance" || a.SempVersion != "2.36" || a.ApiVersion != "v2" {
+			t.Errorf("unexpected about result %+v", a)
+		}
+	}
+}
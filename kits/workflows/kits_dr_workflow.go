@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
 	"kits-worker/kits/models"
 	"time"
 
@@ -9,10 +10,59 @@ import (
 	"go.temporal.io/sdk/workflow"
 )
 
+// topologyCheckVersion marks runs checking kitsURL for a new topology while
+// the kits run.
+const topologyCheckVersion = "topology-check"
+
 type KitsWorkflowInput struct {
 	KitsURL string           `json:"kitsURL"`
 	Filter  string           `json:"filter"`
 	Auth    models.BasicAuth `json:"solaceApiAuth"`
+
+	Integrity models.TopologyIntegrity `json:"topologyIntegrity"`
+
+	// FailureThreshold is the fraction of kits (0..1) allowed to fail while the run
+	// is still reported PARTIAL. Defaults to config.DEFAULT_FAILURE_THRESHOLD.
+	FailureThreshold *float64 `json:"failureThreshold,omitempty"`
+
+	// ForceFailover promotes standby VPNs even when the failover pre-flight
+	// (replication bridge, config-sync, standby spool) says no-go.
+	ForceFailover bool `json:"forceFailover,omitempty"`
+
+	// ReconnectionCheck enables the post-failover check that clients moved to
+	// the new active site. Nil disables it.
+	ReconnectionCheck *models.ReconnectionCheck `json:"reconnectionCheck,omitempty"`
+
+	// Probe enables the synthetic publish/subscribe probe after each failover.
+	Probe *models.ProbeOptions `json:"probe,omitempty"`
+
+	// DNSQuorum sets how many DNS resolvers must agree on a changed record
+	// and for how many polls before a VPN fails over.
+	DNSQuorum models.DNSQuorumOptions `json:"dnsQuorum,omitempty"`
+
+	// Policy overrides the default timeouts, retries and polling intervals
+	// of the run, its watchers and failovers.
+	Policy models.DRPolicy `json:"policy,omitempty"`
+
+	// Maintenance are the maintenance windows during which automated
+	// failover is suppressed or requires approval.
+	Maintenance []models.MaintenanceWindow `json:"maintenance,omitempty"`
+}
+
+// KitsDRResult is the result of KitsDRWorkflow. It replaced the bare
+// []models.MateResult the workflow returned before kit filters; the mates are
+// now listed per kit under Results.
+type KitsDRResult struct {
+	// Topology identifies the topology version the run was based on.
+	Topology models.TopologyVersion `json:"topology"`
+	// TopologyChanged is the first different version seen while the kits
+	// ran; they kept the topology they started from.
+	TopologyChanged *models.TopologyVersion `json:"topologyChanged,omitempty"`
+	// Kits is the list of kits the filter resolved to, sorted by name.
+	Kits []string `json:"kits"`
+
+	Status  models.RunStatus    `json:"status"`
+	Results []models.KitOutcome `json:"results"`
 }
 
 // KitsDRWorkflow executes a one-off disaster recovery across kits.
@@ -25,15 +75,81 @@ type KitsWorkflowInput struct {
 //   - Long-running executions will NOT re-evaluate:
 //   - kit topology changes
 //   - broker state changes
-//   - Any changes occurring after workflow start will not be detected.
+//   - kitsURL is checked every TOPOLOGY_CHECK_INTERVAL while the kits run (a conditional GET when unchanged);
+//     a new topology is logged and reported as topologyChanged, it is NOT applied to the running kits.
 //   - This DR Worklow listens for DNS vpn.<fqdn.com> (as defined for the moment in vpn_fqdn_config.go).
 //     This means that any other fqdn update is not relevant to this process.
+//   - A VPN is failed over once its -a and -b records both point to the standby DC: the vpnAddresses of the DC in
+//     kits.yaml (IPs or CIDR prefixes), or the addresses its mate hosts resolve to. Records pointing elsewhere
+//     (re-IP within the active DC, unknown addresses) are ignored.
 //
 // Input:
-//   - kitsURL: URL to the kits definition YAML.
-//   - filter: Optional kit name filter. If empty, all kits are processed.
+//   - kitsURL: location of the kits definition YAML. The scheme selects the source:
+//     a local file or directory (plain path, file:// or dir://), plain YAML, zip or
+//     tar.gz over http(s). Prefix the scheme with yaml+, zip+ or tgz+ to force the
+//     format and add #<path> to pick the file inside an archive or directory.
+//   - filter: Optional kit filter expression. If empty, all kits are processed. Terms are separated by
+//     commas: exact names (kit-prod-1), globs (kit-prod-*), regexes (/^kit-prod-[0-9]+$/), label
+//     selectors on kits.yaml labels (@env=prod, @env!=dev, @tier) and exclusions (!kit-prod-10).
 //   - solaceApiAuth: Basic authentication credentials for Solace SEMP APIs. This is for testing.
 //     Those parameters are kit specific and should NOT be passed PLAIN! They should be fetched from Vault for specific kits (ie HCV).
+//   - topologyIntegrity: Optional verification of the downloaded artifact: "sha256" (expected hex digest),
+//     "sha256Sidecar" (compare with <kitsURL>.sha256) and "requireSignature" (<kitsURL>.sig must be signed by
+//     a key configured on the worker via KITS_TOPOLOGY_PUBLIC_KEYS, which makes signatures mandatory anyway).
+//   - failureThreshold: Optional fraction of kits allowed to fail before the run is FAILED rather than PARTIAL.
+//   - forceFailover: Optional, true disasters only. Every VPN failover is preceded by a pre-flight checking the
+//     replication bridge, config-sync of the VPN on both sites and the standby queue spool levels; a no-go
+//     verdict refuses the failover unless this flag is set. A forced failover skips the demotion and drain of
+//     an active site the pre-flight could not reach.
+//   - reconnectionCheck: Optional {"windowSeconds": 300, "intervalSeconds": 15}. After each failover, polls the
+//     client connections and queue binds of the VPN on both sites until clients moved to the new active site and
+//     left the old one, and raises an alert in the logs if they did not within the window.
+//   - probe: Optional {"username", "password", "restPort", "queue"}. After each failover, publishes a canary on
+//     kits/dr/canary/<vpn>/<id> through REST messaging on the new active VPN and waits for it on the given queue,
+//     which must subscribe to kits/dr/canary/>.
+//   - dnsQuorum: Optional {"quorum": 2, "stablePolls": 3}. The DNS watchers ask every resolver configured on the
+//     worker (KITS_DNS_RESOLVERS) and only consider a record changed when quorum of them (a majority by default)
+//     return the same new set, and only fail over once both records kept their new set for stablePolls
+//     consecutive polls (1 by default).
+//   - policy: Optional timeouts, retries and polling intervals, unset values keep the defaults of dr_policy_config.go:
+//     {"activityTimeoutSeconds": 60, "dnsPollMinSeconds": 10, "dnsPollMaxSeconds": 60, "watchTimeoutSeconds": 15,
+//     "watchMaxAttempts": 5, "failoverTimeoutSeconds": 30, "failoverMaxAttempts": 3, "drainTimeoutSeconds": 70,
+//     "drainMaxAttempts": 12, "drainIntervalSeconds": 5}. A running DNS watcher takes a new policy through the
+//     updatePolicy update: its non-zero values replace the current ones from the next poll, the failover it starts
+//     included.
+//   - maintenance: Optional maintenance windows, ie re-IP or patching, evaluated in workflow time:
+//     [{"name": "patching", "kit": "kit-prod-1", "vpn": "orders", "cron": "0 2 * * SUN", "durationMinutes": 120,
+//     "timeZone": "Europe/Paris", "mode": "suppress"}, {"name": "re-ip", "start": "2026-03-01T22:00:00Z",
+//     "end": "2026-03-02T02:00:00Z", "mode": "approval"}]. kit and vpn narrow the window, it applies to every kit
+//     and VPN otherwise. During a suppress window the watchers ignore DNS changes; during an approval window a
+//     failover the DNS calls for waits for the approveFailover update. Forced failovers are never held.
+//
+// Running kits and watchers take operator actions as updates with {"vpn", "reason"}: pause (stop acting on DNS,
+// ie during maintenance), resume, forceFailover (fail over without waiting for DNS), excludeVPN (stop
+// watching the VPN without failover) and approveFailover (release a failover held by a maintenance window). Sent to a KitDRWorkflow they apply to the given VPN, or to every VPN of
+// the kit, and are forwarded to its watchers, including the ones started later. Once its watchers run, a kit
+// accepts an action only when a watcher applied it and fails the update with the reasons of the watchers
+// otherwise. The "state" query of both workflows shows the actions accepted, the kit one also the outcome
+// at each watcher, and each kit outcome lists them.
+//
+// Kit workflows, watchers and failovers are named kit-dr/<kit>, vpn-dns-watch/<kit>/<vpn> and
+// vpn-failover/<kit>/<vpn>: a kit or VPN already handled by another run is not started twice, the kit is
+// reported FAILED and the VPN left to the other run. Each failover also holds the lock of its VPN, the VPNLockWorkflow vpn-lock/<kit>/<vpn>, so
+// only one failover of a VPN is in flight across the namespace; a failover still waiting for it after
+// VPN_LOCK_WAIT_TIMEOUT fails with VPNLockBusy. The holder renews the VPN_LOCK_LEASE of the lock every
+// VPN_LOCK_RENEW_INTERVAL, so only a lock whose failover stopped running is taken back. A failover whose
+// lock was taken back anyway fails with VPNLockLost before its next change on the brokers.
+//
+// A failing kit does not stop the others: the result reports SUCCESS, PARTIAL or FAILED per mate,
+// per kit and overall; a kit with a failed or refused (no-go) failover is FAILED. A FAILED run fails the
+// workflow with a KitsRunFailed error carrying the result.
+// Every VPN failover is reported under its kit with the pre-flight verdict and the config diff of the VPN
+// on both sites, taken from snapshots stored in the directory shared by the workers (KITS_SNAPSHOT_DIR) and
+// only referenced by the failover history; a site whose snapshot or diff failed is reported with the error.
+//
+// Output: a KitsDRResult object {"topology", "kits", "status", "results"}. This is a breaking change for
+// callers of earlier versions, which returned a JSON array of mate results: the mates of each kit are now
+// under results[].mates. Runs completed before the upgrade keep the array in their history.
 //
 // Example Temporal UI input:
 //
@@ -48,57 +164,145 @@ type KitsWorkflowInput struct {
 func KitsDRWorkflow(
 	ctx workflow.Context,
 	input KitsWorkflowInput,
-) ([]models.MateResult, error) {
+) (*KitsDRResult, error) {
 
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Starting KitsDRWorkflow", "kitsURL", input.KitsURL, "filter", input.Filter)
 
+	threshold, err := resolveFailureThreshold(input.FailureThreshold)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidInput", err)
+	}
+	if err := validateDNSQuorum(input.DNSQuorum); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidInput", err)
+	}
+	policy, err := resolveDRPolicy(input.Policy)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidInput", err)
+	}
+	if err := validateMaintenanceWindows(input.Maintenance); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidInput", err)
+	}
+
 	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
+		StartToCloseTimeout: seconds(policy.ActivityTimeoutSeconds),
 		RetryPolicy: &temporal.RetryPolicy{
 			InitialInterval:    time.Second * 5,
 			BackoffCoefficient: 2.0,
 			MaximumInterval:    time.Minute,
 			MaximumAttempts:    5, // <-- fail activity after 5 retries
 			NonRetryableErrorTypes: []string{
-				"DecodeError",  // XML decode errors won't retry
-				"RequestError", // invalid request won't retry
+				"DecodeError",            // XML decode errors won't retry
+				"RequestError",           // invalid request won't retry
+				"TopologyInvalid",        // kits.yaml failed validation
+				"TopologySourceInvalid",  // kitsURL cannot be interpreted
+				"TopologyIntegrityError", // checksum or signature mismatch
+				"KitFilterInvalid",       // filter expression does not compile
 			},
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
 	// Download and parse kits
-	var kits map[string]models.Kit
+	var topology models.Topology
 	if err := workflow.ExecuteActivity(
 		ctx,
 		activities.DownloadAndParseKitsActivity,
 		input.KitsURL,
 		input.Filter,
-	).Get(ctx, &kits); err != nil {
+		input.Integrity,
+	).Get(ctx, &topology); err != nil {
 		logger.Error("DownloadAndParseKitsActivity failed", "error", err)
 		return nil, err
 	}
 
+	kits := topology.Kits
+	names := sortedKitNames(kits)
+	logger.Info(
+		"Kits resolved from filter",
+		"filter", input.Filter,
+		"kits", names,
+		"topologyDigest", topology.Version.Digest,
+	)
+
+	opts := models.FailoverOptions{
+		Force:             input.ForceFailover,
+		ReconnectionCheck: input.ReconnectionCheck,
+		Probe:             input.Probe,
+		DNSQuorum:         input.DNSQuorum,
+		Policy:            policy,
+		Maintenance:       input.Maintenance,
+	}
+
 	// Execute child workflows
 	var futures []workflow.ChildWorkflowFuture
-	for name, kit := range kits {
+	for _, name := range names {
 		futures = append(futures,
-			workflow.ExecuteChildWorkflow(ctx, KitDRWorkflow, name, kit, input.Auth),
+			workflow.ExecuteChildWorkflow(withChildWorkflowID(ctx, kitDRWorkflowID(name)), KitDRWorkflow, name, kits[name], input.Auth, opts),
 		)
 	}
 
-	// Collect results
-	var all []models.MateResult
-	for _, f := range futures {
-		var res []models.MateResult
-		if err := f.Get(ctx, &res); err != nil {
-			logger.Error("Child workflow failed", "error", err)
-			return nil, err
+	result := &KitsDRResult{Topology: topology.Version, Kits: names}
+
+	// Report topology changes while the kits run
+	if workflow.GetVersion(ctx, topologyCheckVersion, workflow.DefaultVersion, 1) == 1 {
+		wctx, stop := workflow.WithCancel(ctx)
+		defer stop()
+		workflow.Go(wctx, func(ctx workflow.Context) {
+			result.TopologyChanged = watchTopology(ctx, input, topology.Version)
+		})
+	}
+
+	// Collect results, a failed kit does not stop the others
+	for i, f := range futures {
+		var outcome models.KitOutcome
+		if err := f.Get(ctx, &outcome); temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			logger.Error("Kit already in DR in another run", "kit", names[i], "workflowID", kitDRWorkflowID(names[i]))
+			outcome = models.KitOutcome{Kit: names[i], Status: models.RunFailed, Error: "kit already in DR in another run: " + err.Error()}
+		} else if err != nil {
+			logger.Error("Child workflow failed", "kit", names[i], "error", err)
+			outcome = models.KitOutcome{Kit: names[i], Status: models.RunFailed, Error: err.Error()}
 		}
-		all = append(all, res...)
+		result.Results = append(result.Results, outcome)
 	}
+	result.Status = aggregateRunStatus(result.Results, threshold)
 
-	logger.Info("KitsDRWorkflow completed successfully", "totalResults", len(all))
-	return all, nil
+	logger.Info("KitsDRWorkflow completed", "status", result.Status, "kits", len(result.Results))
+	if result.Status == models.RunFailed {
+		return result, temporal.NewApplicationError("KitsDRWorkflow failed", "KitsRunFailed", result)
+	}
+	return result, nil
+}
+
+// watchTopology checks kitsURL every config.TOPOLOGY_CHECK_INTERVAL and
+// returns the first version that differs from started, nil once canceled.
+func watchTopology(ctx workflow.Context, input KitsWorkflowInput, started models.TopologyVersion) *models.TopologyVersion {
+	logger := workflow.GetLogger(ctx)
+	for {
+		if err := workflow.Sleep(ctx, config.TOPOLOGY_CHECK_INTERVAL); err != nil {
+			return nil
+		}
+		var current models.TopologyVersion
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.CheckTopologyVersionActivity,
+			input.KitsURL,
+			input.Integrity,
+		).Get(ctx, &current); err != nil {
+			if temporal.IsCanceledError(err) {
+				return nil
+			}
+			logger.Warn("Topology version check failed", "kitsURL", input.KitsURL, "error", err)
+			continue
+		}
+		if current.Digest != started.Digest {
+			logger.Warn(
+				"Topology changed since the run started, running kits keep their topology",
+				"kitsURL", input.KitsURL,
+				"digest", started.Digest,
+				"currentDigest", current.Digest,
+			)
+			return &current
+		}
+	}
 }
@@ -0,0 +1,153 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestKitsDRWorkflow(t *testing.T) {
+	topology := &models.Topology{
+		Version: models.TopologyVersion{Source: "file", Digest: "abc"},
+		Kits:    map[string]models.Kit{"kit-b": testKit, "kit-a": testKit, "kit-c": testKit},
+	}
+
+	cases := []struct {
+		name    string
+		failing []string
+		want    models.RunStatus
+	}{
+		{name: "all kits succeed", want: models.RunSuccess},
+		{name: "one kit fails", failing: []string{"kit-b"}, want: models.RunPartial},
+		{name: "too many kits fail", failing: []string{"kit-a", "kit-c"}, want: models.RunFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			env := newDRTestEnv(t)
+			env.OnActivity(activities.DownloadAndParseKitsActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(topology, nil)
+			for _, kit := range c.failing {
+				env.OnWorkflow(KitDRWorkflow, mock.Anything, kit, mock.Anything, mock.Anything, mock.Anything).
+					Return(models.KitOutcome{}, errors.New("kit workflow failed"))
+			}
+			env.OnWorkflow(KitDRWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(models.KitOutcome{Status: models.RunSuccess}, nil)
+
+			env.ExecuteWorkflow(KitsDRWorkflow, KitsWorkflowInput{KitsURL: "kits.yaml"})
+
+			var result KitsDRResult
+			err := env.GetWorkflowError()
+			var appErr *temporal.ApplicationError
+			switch {
+			case c.want == models.RunFailed:
+				if !errors.As(err, &appErr) || appErr.Type() != "KitsRunFailed" {
+					t.Fatalf("expected KitsRunFailed, got %v", err)
+				}
+				if err := appErr.Details(&result); err != nil {
+					t.Fatal(err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			default:
+				if err := env.GetWorkflowResult(&result); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if result.Status != c.want {
+				t.Errorf("expected %s, got %s", c.want, result.Status)
+			}
+			if len(result.Kits) != 3 || result.Kits[0] != "kit-a" || result.Kits[2] != "kit-c" {
+				t.Errorf("expected sorted kits, got %v", result.Kits)
+			}
+			if result.Topology.Digest != "abc" {
+				t.Errorf("expected topology version in the result, got %+v", result.Topology)
+			}
+		})
+	}
+}
+
+func TestKitsDRWorkflow_InvalidThreshold(t *testing.T) {
+	env := newDRTestEnv(t)
+	threshold := 1.5
+
+	env.ExecuteWorkflow(KitsDRWorkflow, KitsWorkflowInput{KitsURL: "kits.yaml", FailureThreshold: &threshold})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != "InvalidInput" {
+		t.Fatalf("expected InvalidInput, got %v", err)
+	}
+}
+
+func TestKitsDRWorkflow_InvalidDNSQuorum(t *testing.T) {
+	env := newDRTestEnv(t)
+
+	env.ExecuteWorkflow(KitsDRWorkflow, KitsWorkflowInput{KitsURL: "kits.yaml", DNSQuorum: models.DNSQuorumOptions{StablePolls: -1}})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != "InvalidInput" {
+		t.Fatalf("expected InvalidInput, got %v", err)
+	}
+}
+
+func TestKitsDRWorkflow_InvalidPolicy(t *testing.T) {
+	env := newDRTestEnv(t)
+
+	env.ExecuteWorkflow(KitsDRWorkflow, KitsWorkflowInput{KitsURL: "kits.yaml", Policy: models.DRPolicy{DNSPollMaxSeconds: 5}})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != "InvalidInput" {
+		t.Fatalf("expected InvalidInput, got %v", err)
+	}
+}
+
+func TestKitsDRWorkflow_InvalidMaintenance(t *testing.T) {
+	env := newDRTestEnv(t)
+
+	env.ExecuteWorkflow(KitsDRWorkflow, KitsWorkflowInput{KitsURL: "kits.yaml", Maintenance: []models.MaintenanceWindow{{Name: "patching", Cron: "0 2 * * SUN"}}})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != "InvalidInput" {
+		t.Fatalf("expected InvalidInput, got %v", err)
+	}
+}
+
+func TestKitsDRWorkflow_TopologyChanged(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.DownloadAndParseKitsActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.Topology{Version: models.TopologyVersion{Digest: "abc"}, Kits: map[string]models.Kit{"kit-a": testKit}}, nil)
+	checks := 0
+	env.OnActivity(activities.CheckTopologyVersionActivity, mock.Anything, "kits.yaml", mock.Anything).
+		Return(func(context.Context, string, models.TopologyIntegrity) (models.TopologyVersion, error) {
+			checks++
+			if checks < 2 {
+				return models.TopologyVersion{Digest: "abc"}, nil
+			}
+			return models.TopologyVersion{Digest: "def"}, nil
+		})
+	// the kit keeps watching for an hour
+	env.OnWorkflow(KitDRWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx workflow.Context, kit string, _ models.Kit, _ models.BasicAuth, _ models.FailoverOptions) (models.KitOutcome, error) {
+			return models.KitOutcome{Kit: kit, Status: models.RunSuccess}, workflow.Sleep(ctx, time.Hour)
+		})
+
+	env.ExecuteWorkflow(KitsDRWorkflow, KitsWorkflowInput{KitsURL: "kits.yaml"})
+
+	var result KitsDRResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.TopologyChanged == nil || result.TopologyChanged.Digest != "def" || checks != 2 {
+		t.Errorf("expected the new topology reported once seen, got %+v after %d checks", result.TopologyChanged, checks)
+	}
+	if result.Status != models.RunSuccess || result.Topology.Digest != "abc" {
+		t.Errorf("expected the kits to run on the topology they started from, got %+v", result)
+	}
+}
@@ -0,0 +1,110 @@
+package workflows
+
+import (
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+type KitsInventoryWorkflowInput struct {
+	KitsURL string           `json:"kitsURL"`
+	Filter  string           `json:"filter"`
+	Auth    models.BasicAuth `json:"solaceApiAuth"`
+
+	Integrity models.TopologyIntegrity `json:"topologyIntegrity"`
+}
+
+// KitsInventoryWorkflow builds a fleet inventory of every mate of every kit:
+// platform, SEMP version, broker release, redundancy mode, config-sync status
+// and message VPN counts. The report is returned and exported on the worker
+// as JSON and CSV (see KITS_REPORT_DIR).
+//
+// Input: same as KitsSolaceAPIWorkflow.
+//
+// Example Temporal UI input:
+//
+//	{
+//	  "kitsURL": "https://.../id-meshconfig-main_20260119_2.tar.gz",
+//	  "filter": "fss-dce-sg-*",
+//	  "solaceApiAuth": {
+//	    "Username": "admin",
+//	    "Password": "admin"
+//	  }
+//	}
+func KitsInventoryWorkflow(
+	ctx workflow.Context,
+	input KitsInventoryWorkflowInput,
+) (*models.FleetInventory, error) {
+
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting KitsInventoryWorkflow", "kitsURL", input.KitsURL, "filter", input.Filter)
+
+	ao := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    5,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, ao)
+
+	// Download and parse kits
+	var topology models.Topology
+	if err := workflow.ExecuteActivity(
+		ctx,
+		activities.DownloadAndParseKitsActivity,
+		input.KitsURL,
+		input.Filter,
+		input.Integrity,
+	).Get(ctx, &topology); err != nil {
+		logger.Error("DownloadAndParseKitsActivity failed", "error", err)
+		return nil, err
+	}
+
+	names := sortedKitNames(topology.Kits)
+
+	// Execute child workflows
+	var futures []workflow.ChildWorkflowFuture
+	for _, name := range names {
+		futures = append(futures,
+			workflow.ExecuteChildWorkflow(ctx, KitInventoryWorkflow, name, topology.Kits[name], input.Auth),
+		)
+	}
+
+	inventory := models.FleetInventory{Topology: topology.Version}
+	for i, f := range futures {
+		var kitInv models.KitInventory
+		if err := f.Get(ctx, &kitInv); err != nil {
+			logger.Error("Child workflow failed", "kit", names[i], "error", err)
+			kitInv = models.KitInventory{Kit: names[i], TotalCount: 4}
+		}
+		inventory.Kits = append(inventory.Kits, kitInv)
+		inventory.SuccessCount += kitInv.SuccessCount
+		inventory.TotalCount += kitInv.TotalCount
+	}
+
+	var paths []string
+	if err := workflow.ExecuteActivity(
+		ctx,
+		activities.ExportFleetInventoryActivity,
+		workflow.GetInfo(ctx).WorkflowExecution.ID,
+		inventory,
+	).Get(ctx, &paths); err != nil {
+		logger.Error("ExportFleetInventoryActivity failed", "error", err)
+	} else if len(paths) == 2 {
+		inventory.JSONPath, inventory.CSVPath = paths[0], paths[1]
+	}
+
+	logger.Info(
+		"KitsInventoryWorkflow completed",
+		"kits", len(inventory.Kits),
+		"success", inventory.SuccessCount,
+		"total", inventory.TotalCount,
+	)
+	return &inventory, nil
+}
@@ -13,15 +13,41 @@ type KitsSolaceAPIWorkflowInput struct {
 	KitsURL string           `json:"kitsURL"`
 	Filter  string           `json:"filter"`
 	Auth    models.BasicAuth `json:"solaceApiAuth"`
+
+	Integrity models.TopologyIntegrity `json:"topologyIntegrity"`
+
+	// FailureThreshold is the fraction of kits (0..1) allowed to fail while the run
+	// is still reported PARTIAL. Defaults to config.DEFAULT_FAILURE_THRESHOLD.
+	FailureThreshold *float64 `json:"failureThreshold,omitempty"`
+}
+
+type KitsSolaceAPIResult struct {
+	// Topology identifies the topology version the run was based on.
+	Topology models.TopologyVersion `json:"topology"`
+	// Kits is the list of kits the filter resolved to, sorted by name.
+	Kits []string `json:"kits"`
+
+	Status  models.RunStatus    `json:"status"`
+	Results []models.KitOutcome `json:"results"`
 }
 
 // KitsSolaceAPIWorkflow executes a one-off test against SolaceAPI for each kits and returns sempVersion
 //
 // Input:
-//   - kitsURL: URL to the kits definition YAML.
-//   - filter: Optional kit name filter. If empty, all kits are processed.
+//   - kitsURL: location of the kits definition YAML. The scheme selects the source:
+//     a local file or directory (plain path, file:// or dir://), plain YAML, zip or
+//     tar.gz over http(s). Prefix the scheme with yaml+, zip+ or tgz+ to force the
+//     format and add #<path> to pick the file inside an archive or directory.
+//   - filter: Optional kit filter expression, same syntax as KitsDRWorkflow. If empty, all kits are processed.
 //   - solaceApiAuth: Basic authentication credentials for Solace SEMP APIs. This is for testing.
 //     Those parameters are kit specific and should not be passed PLAIN? They should be fetched from Vault for specific kits (ie HCV).
+//   - topologyIntegrity: Optional verification of the downloaded artifact: "sha256" (expected hex digest),
+//     "sha256Sidecar" (compare with <kitsURL>.sha256) and "requireSignature" (<kitsURL>.sig must be signed by
+//     a key configured on the worker via KITS_TOPOLOGY_PUBLIC_KEYS, which makes signatures mandatory anyway).
+//   - failureThreshold: Optional fraction of kits allowed to fail before the run is FAILED rather than PARTIAL.
+//
+// All kits run to completion: the result reports SUCCESS, PARTIAL or FAILED per mate, per kit and overall.
+// A FAILED run fails the workflow with a KitsRunFailed error carrying the result as details.
 //
 // Example Temporal UI input:
 //
@@ -36,11 +62,16 @@ type KitsSolaceAPIWorkflowInput struct {
 func KitsSolaceAPIWorkflow(
 	ctx workflow.Context,
 	input KitsSolaceAPIWorkflowInput,
-) error {
+) (*KitsSolaceAPIResult, error) {
 
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Starting KitsSolaceAPIWorkflow", "kitsURL", input.KitsURL, "filter", input.Filter)
 
+	threshold, err := resolveFailureThreshold(input.FailureThreshold)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidInput", err)
+	}
+
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute,
 		RetryPolicy: &temporal.RetryPolicy{
@@ -53,33 +84,50 @@ func KitsSolaceAPIWorkflow(
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
 	// Download and parse kits
-	var kits map[string]models.Kit
+	var topology models.Topology
 	if err := workflow.ExecuteActivity(
 		ctx,
 		activities.DownloadAndParseKitsActivity,
 		input.KitsURL,
 		input.Filter,
-	).Get(ctx, &kits); err != nil {
+		input.Integrity,
+	).Get(ctx, &topology); err != nil {
 		logger.Error("DownloadAndParseKitsActivity failed", "error", err)
-		return err
+		return nil, err
 	}
 
+	kits := topology.Kits
+	names := sortedKitNames(kits)
+	logger.Info(
+		"Kits resolved from filter",
+		"filter", input.Filter,
+		"kits", names,
+		"topologyDigest", topology.Version.Digest,
+	)
+
 	// Execute child workflows
 	var futures []workflow.ChildWorkflowFuture
-	for name, kit := range kits {
+	for _, name := range names {
 		futures = append(futures,
-			workflow.ExecuteChildWorkflow(ctx, KitSolaceAPIWorkflow, name, kit, input.Auth),
+			workflow.ExecuteChildWorkflow(ctx, KitSolaceAPIWorkflow, name, kits[name], input.Auth),
 		)
 	}
 
-	// Wait for all children
-	for _, f := range futures {
-		if err := f.Get(ctx, nil); err != nil {
-			logger.Error("Child workflow failed", "error", err)
-			return err
+	// Wait for all children, a failed kit does not stop the others
+	result := &KitsSolaceAPIResult{Topology: topology.Version, Kits: names}
+	for i, f := range futures {
+		var outcome models.KitOutcome
+		if err := f.Get(ctx, &outcome); err != nil {
+			logger.Error("Child workflow failed", "kit", names[i], "error", err)
+			outcome = models.KitOutcome{Kit: names[i], Status: models.RunFailed, Error: err.Error()}
 		}
+		result.Results = append(result.Results, outcome)
 	}
+	result.Status = aggregateRunStatus(result.Results, threshold)
 
-	logger.Info("KitsSolaceAPIWorkflow completed successfully")
-	return nil
+	logger.Info("KitsSolaceAPIWorkflow completed", "status", result.Status)
+	if result.Status == models.RunFailed {
+		return result, temporal.NewApplicationError("KitsSolaceAPIWorkflow failed", "KitsRunFailed", result)
+	}
+	return result, nil
 }
@@ -0,0 +1,28 @@
+package workflows
+
+import (
+	"kits-worker/kits/models"
+	"sort"
+)
+
+// sortedKitNames returns the kit names in a stable order. Ranging over the
+// map directly would start children in a random order and break replay.
+func sortedKitNames(kits map[string]models.Kit) []string {
+	names := make([]string, 0, len(kits))
+	for name := range kits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedVPNNames returns the VPN names of a discovery map in a stable order,
+// for the same reason.
+func sortedVPNNames(vpns map[string]models.MateResult) []string {
+	names := make([]string, 0, len(vpns))
+	for name := range vpns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
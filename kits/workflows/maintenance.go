@@ -0,0 +1,124 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"kits-worker/kits/models"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// validateMaintenanceWindows checks each window is either a range or a
+// schedule with a duration, in a known time zone and mode.
+func validateMaintenanceWindows(windows []models.MaintenanceWindow) error {
+	var errs []error
+	for i, w := range windows {
+		if err := validateMaintenanceWindow(w); err != nil {
+			errs = append(errs, fmt.Errorf("maintenance[%d] %s: %w", i, w.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateMaintenanceWindow(w models.MaintenanceWindow) error {
+	if w.Name == "" {
+		return errors.New("name is required")
+	}
+	switch w.Mode {
+	case "", models.MaintenanceSuppress, models.MaintenanceApproval:
+	default:
+		return fmt.Errorf("mode must be %s or %s, got %q", models.MaintenanceSuppress, models.MaintenanceApproval, w.Mode)
+	}
+
+	ranged := !w.Start.IsZero() || !w.End.IsZero()
+	switch {
+	case ranged && w.Cron != "":
+		return errors.New("start/end and cron are exclusive")
+	case ranged:
+		if !w.End.After(w.Start) {
+			return fmt.Errorf("end %s must be after start %s", w.End, w.Start)
+		}
+		return nil
+	case w.Cron == "":
+		return errors.New("start/end or cron is required")
+	}
+	if w.DurationMinutes <= 0 {
+		return fmt.Errorf("durationMinutes must be positive with cron, got %d", w.DurationMinutes)
+	}
+	_, _, err := maintenanceSchedule(w)
+	return err
+}
+
+// maintenanceSchedule parses the schedule of a recurring window. @every
+// descriptors are refused, they do not recur at fixed times.
+func maintenanceSchedule(w models.MaintenanceWindow) (cron.Schedule, *time.Location, error) {
+	if strings.HasPrefix(w.Cron, "@every") {
+		return nil, nil, fmt.Errorf("cron %q does not recur at fixed times", w.Cron)
+	}
+	schedule, err := cron.ParseStandard(w.Cron)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cron %q: %w", w.Cron, err)
+	}
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("timeZone %q: %w", w.TimeZone, err)
+	}
+	return schedule, loc, nil
+}
+
+// maintenanceWindowsOf returns the windows applying to kit.
+func maintenanceWindowsOf(windows []models.MaintenanceWindow, kit string) []models.MaintenanceWindow {
+	var selected []models.MaintenanceWindow
+	for _, w := range windows {
+		if w.Kit == "" || w.Kit == kit {
+			selected = append(selected, w)
+		}
+	}
+	return selected
+}
+
+// activeMaintenance returns the window of vpn in force at now, suppress
+// windows before approval ones, or nil.
+func activeMaintenance(windows []models.MaintenanceWindow, vpn string, now time.Time) (*models.MaintenanceWindow, error) {
+	var approval *models.MaintenanceWindow
+	for i, w := range windows {
+		if w.VPN != "" && w.VPN != vpn {
+			continue
+		}
+		active, err := maintenanceActive(w, now)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance %s: %w", w.Name, err)
+		}
+		if !active {
+			continue
+		}
+		if w.Mode == models.MaintenanceApproval {
+			if approval == nil {
+				approval = &windows[i]
+			}
+			continue
+		}
+		return &windows[i], nil
+	}
+	return approval, nil
+}
+
+// maintenanceActive reports whether now falls within the window: within
+// [Start, End) or less than DurationMinutes after a time of the schedule.
+// The time zone comes from the tzdata embedded in the worker, a window whose
+// schedule no longer loads is an error rather than inactive.
+func maintenanceActive(w models.MaintenanceWindow, now time.Time) (bool, error) {
+	if w.Cron == "" {
+		return !now.Before(w.Start) && now.Before(w.End), nil
+	}
+	schedule, loc, err := maintenanceSchedule(w)
+	if err != nil {
+		return false, err
+	}
+	// the first start after now-duration, schedules have minute precision
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	start := schedule.Next(now.In(loc).Add(-duration))
+	return !start.IsZero() && !start.After(now), nil
+}
@@ -0,0 +1,178 @@
+package workflows
+
+import (
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceActive(t *testing.T) {
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	ranged := models.MaintenanceWindow{Name: "re-ip", Start: at("2026-03-01T22:00:00Z"), End: at("2026-03-02T02:00:00Z")}
+	// Sundays 02:00 to 04:00 UTC
+	weekly := models.MaintenanceWindow{Name: "patching", Cron: "0 2 * * SUN", DurationMinutes: 120}
+	// daily 23:30 for an hour, across midnight, in UTC+1
+	daily := models.MaintenanceWindow{Name: "nightly", Cron: "30 23 * * *", DurationMinutes: 60, TimeZone: "Etc/GMT-1"}
+
+	cases := []struct {
+		w      models.MaintenanceWindow
+		now    string
+		active bool
+	}{
+		{ranged, "2026-03-01T21:59:59Z", false},
+		{ranged, "2026-03-01T22:00:00Z", true},
+		{ranged, "2026-03-02T01:59:59Z", true},
+		{ranged, "2026-03-02T02:00:00Z", false},
+		{weekly, "2026-03-01T01:59:59Z", false},
+		{weekly, "2026-03-01T02:00:00Z", true},
+		{weekly, "2026-03-01T03:59:59Z", true},
+		{weekly, "2026-03-01T04:00:00Z", false},
+		{weekly, "2026-03-02T02:30:00Z", false},
+		{daily, "2026-03-01T22:29:00Z", false},
+		{daily, "2026-03-01T22:30:00Z", true},
+		{daily, "2026-03-01T23:10:00Z", true},
+		{daily, "2026-03-01T23:30:00Z", false},
+	}
+	for _, c := range cases {
+		got, err := maintenanceActive(c.w, at(c.now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.active {
+			t.Errorf("%s at %s: expected active %v, got %v", c.w.Name, c.now, c.active, got)
+		}
+	}
+}
+
+func TestActiveMaintenance(t *testing.T) {
+	now := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	windows := []models.MaintenanceWindow{
+		{Name: "billing", VPN: "billing", Cron: "@daily", DurationMinutes: 240},
+		{Name: "approval", Cron: "0 2 * * *", DurationMinutes: 120, Mode: models.MaintenanceApproval},
+		{Name: "later", Cron: "0 4 * * *", DurationMinutes: 60},
+	}
+
+	if w, err := activeMaintenance(windows, "billing", now); err != nil || w == nil || w.Name != "billing" {
+		t.Errorf("expected the suppress window of billing first, got %+v", w)
+	}
+	if w, err := activeMaintenance(windows, "orders", now); err != nil || w == nil || w.Name != "approval" {
+		t.Errorf("expected the approval window for orders, got %+v", w)
+	}
+	if w, err := activeMaintenance(windows, "orders", now.Add(2*time.Hour)); err != nil || w != nil {
+		t.Errorf("expected no window at 05:00, got %+v", w)
+	}
+
+	windows = append(windows, models.MaintenanceWindow{Name: "moved", Cron: "0 1 * * *", DurationMinutes: 60, TimeZone: "Nowhere/Gone"})
+	if _, err := activeMaintenance(windows, "orders", now.Add(2*time.Hour)); err == nil {
+		t.Errorf("expected a window whose time zone does not load to fail")
+	}
+}
+
+func TestValidateMaintenanceWindows(t *testing.T) {
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)
+	valid := []models.MaintenanceWindow{
+		{Name: "re-ip", Start: start, End: start.Add(4 * time.Hour), Mode: models.MaintenanceApproval},
+		{Name: "patching", Kit: "kit1", Cron: "0 2 * * SUN", DurationMinutes: 120, TimeZone: "UTC"},
+	}
+	if err := validateMaintenanceWindows(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		w   models.MaintenanceWindow
+		err string
+	}{
+		{models.MaintenanceWindow{Cron: "@daily", DurationMinutes: 60}, "name is required"},
+		{models.MaintenanceWindow{Name: "w"}, "start/end or cron is required"},
+		{models.MaintenanceWindow{Name: "w", Start: start, End: start}, "must be after start"},
+		{models.MaintenanceWindow{Name: "w", Start: start, End: start.Add(time.Hour), Cron: "@daily"}, "exclusive"},
+		{models.MaintenanceWindow{Name: "w", Cron: "@daily"}, "durationMinutes"},
+		{models.MaintenanceWindow{Name: "w", Cron: "0 2 * *", DurationMinutes: 60}, "cron"},
+		{models.MaintenanceWindow{Name: "w", Cron: "@every 1h", DurationMinutes: 60}, "fixed times"},
+		{models.MaintenanceWindow{Name: "w", Cron: "@daily", DurationMinutes: 60, TimeZone: "Mars/Olympus"}, "timeZone"},
+		{models.MaintenanceWindow{Name: "w", Cron: "@daily", DurationMinutes: 60, Mode: "skip"}, "mode"},
+	} {
+		if err := validateMaintenanceWindows([]models.MaintenanceWindow{c.w}); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%+v: expected %q, got %v", c.w, c.err, err)
+		}
+	}
+}
+
+func TestVPNDNSWatch_MaintenanceSuppress(t *testing.T) {
+	env := newDRTestEnv(t)
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)
+	env.SetStartTime(start)
+	mockDNS(env, "orders", []string{"10.20.1.1"}, []string{"10.20.1.2"})
+	started := mockFailovers(env)
+
+	var rejected error
+	control(env, 5*time.Second, UpdateApprove, models.ControlRequest{}, &rejected)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{
+			SiteAddresses: testSites,
+			DNSQuorum:     models.DNSQuorumOptions{StablePolls: 2},
+			Maintenance: []models.MaintenanceWindow{
+				{Name: "re-ip", Start: start, End: start.Add(35 * time.Second)},
+				{Name: "billing", VPN: "billing", Start: start, End: start.Add(time.Hour)},
+			},
+		})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if rejected == nil {
+		t.Error("expected the approval without held failover to be rejected")
+	}
+	// polls up to 30s are suppressed, the change is stable at 50s
+	if started["orders"] != 50*time.Second {
+		t.Errorf("expected the failover after 50s, got %s", started["orders"])
+	}
+}
+
+func TestVPNDNSWatch_MaintenanceApproval(t *testing.T) {
+	env := newDRTestEnv(t)
+	start := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+	env.SetStartTime(start)
+	mockDNS(env, "orders", []string{"10.20.1.1"}, []string{"10.20.1.2"})
+	started := mockFailovers(env)
+
+	var rejected error
+	var held bool
+	env.RegisterDelayedCallback(func() {
+		held = watcherState(t, env).AwaitingApproval
+	}, 12*time.Second)
+	control(env, 15*time.Second, UpdateApprove, models.ControlRequest{Reason: "planned"}, &rejected)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{
+			SiteAddresses: testSites,
+			Maintenance: []models.MaintenanceWindow{
+				{Name: "patching", Cron: "0 2 * * SUN", DurationMinutes: 120, Mode: models.MaintenanceApproval},
+			},
+		})
+
+	var result models.VPNFailoverResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if rejected != nil {
+		t.Fatal(rejected)
+	}
+	if !held {
+		t.Error("expected the failover held awaiting approval")
+	}
+	if started["orders"] != 15*time.Second {
+		t.Errorf("expected the failover as soon as approved, got %s", started["orders"])
+	}
+	if result.Approval == nil || result.Approval.Reason != "planned" || result.DNSTrigger == nil {
+		t.Errorf("expected the approved DNS failover reported, got %+v", result)
+	}
+}
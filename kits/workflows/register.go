@@ -0,0 +1,60 @@
+package workflows
+
+import (
+	"kits-worker/kits/activities"
+)
+
+// Registry is the part of a Temporal worker, or of a test environment,
+// workflows and activities are registered with.
+type Registry interface {
+	RegisterWorkflow(w interface{})
+	RegisterActivity(a interface{})
+}
+
+// Register registers the workflows and activities served on the worker task
+// queue. The worker and the chaos runner share it so that scenarios run with
+// the activities deployed.
+func Register(r Registry) {
+	for _, w := range []interface{}{
+		KitsDRWorkflow,
+		KitDRWorkflow,
+		VPNFailoverWorkflow,
+		VPNDNSWatchAndExecuteVPNFailoverWorkflow,
+		VPNLockWorkflow,
+		KitsSolaceAPIWorkflow,
+		KitSolaceAPIWorkflow,
+		KitsInventoryWorkflow,
+		KitInventoryWorkflow,
+		AuditTrailWorkflow,
+	} {
+		r.RegisterWorkflow(w)
+	}
+
+	for _, a := range []interface{}{
+		activities.DownloadAndParseKitsActivity,
+		activities.CheckTopologyVersionActivity,
+		activities.CheckMateStatusActivity,
+		activities.GetRoleVPNsActivity,
+		activities.ResolveDNSActivity,
+		activities.ResolveDNSQuorumActivity,
+		activities.ResolveSiteAddressesActivity,
+		activities.SetVPNReplicationRoleActivity,
+		activities.WaitForReplicationDrainActivity,
+		activities.VerifyVPNRoleActivity,
+		activities.ValidateFinalRolesActivity,
+		activities.BrokerSEMPApiAboutActivity,
+		activities.BrokerInventoryActivity,
+		activities.CheckSEMPCompatibilityActivity,
+		activities.ExportFleetInventoryActivity,
+		activities.FailoverPreflightActivity,
+		activities.AcquireVPNLockActivity,
+		activities.ReleaseVPNLockActivity,
+		activities.RenewVPNLockActivity,
+		activities.VPNConnectionsActivity,
+		activities.PublishSubscribeProbeActivity,
+		activities.CaptureVPNConfigSnapshotActivity,
+		activities.DiffVPNConfigSnapshotsActivity,
+	} {
+		r.RegisterActivity(a)
+	}
+}
@@ -0,0 +1,41 @@
+package workflows
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.temporal.io/sdk/worker"
+)
+
+// TestReplayHistories replays the histories under testdata/histories
+// against the current workflow code. A failure means a change is not
+// deterministic for executions already running: guard it with
+// workflow.GetVersion instead of editing the history.
+//
+// The histories are recorded from runs of the baseline worker on a cluster
+// and checked in unchanged, never written or regenerated by hand:
+//
+//	temporal workflow show --workflow-id <id> --run-id <run> --output json > testdata/histories/<name>.json
+func TestReplayHistories(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "histories", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no recorded history under testdata/histories")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(KitsDRWorkflow)
+			replayer.RegisterWorkflow(KitDRWorkflow)
+			replayer.RegisterWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow)
+			replayer.RegisterWorkflow(VPNFailoverWorkflow)
+
+			if err := replayer.ReplayWorkflowHistoryFromJSONFile(nil, file); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package workflows
+
+import (
+	"fmt"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+)
+
+// resolveFailureThreshold applies the default and checks the range.
+func resolveFailureThreshold(threshold *float64) (float64, error) {
+	if threshold == nil {
+		return config.DEFAULT_FAILURE_THRESHOLD, nil
+	}
+	if *threshold < 0 || *threshold > 1 {
+		return 0, fmt.Errorf("failureThreshold must be between 0 and 1, got %v", *threshold)
+	}
+	return *threshold, nil
+}
+
+// summarizeMates fills the success/failure counts and status of a kit from
+// its mate results and failovers: all mates reachable is SUCCESS, none is
+// FAILED, and so is a kit with a failed or refused failover.
+func summarizeMates(outcome *models.KitOutcome) {
+	outcome.SuccessCount, outcome.FailureCount = 0, 0
+	for _, m := range outcome.Mates {
+		if m.Error == "" {
+			outcome.SuccessCount++
+		} else {
+			outcome.FailureCount++
+		}
+	}
+	failovers := 0
+	for _, f := range outcome.Failovers {
+		if f.Error != "" {
+			failovers++
+		}
+	}
+	outcome.FailureCount += failovers
+
+	switch {
+	case outcome.Error != "" || outcome.SuccessCount == 0 || failovers > 0:
+		outcome.Status = models.RunFailed
+	case outcome.FailureCount > 0:
+		outcome.Status = models.RunPartial
+	default:
+		outcome.Status = models.RunSuccess
+	}
+}
+
+// aggregateRunStatus is SUCCESS when every kit succeeded, FAILED when every
+// kit failed or the fraction of failed kits exceeds threshold, and PARTIAL
+// otherwise.
+func aggregateRunStatus(outcomes []models.KitOutcome, threshold float64) models.RunStatus {
+	failed, degraded := 0, 0
+	for _, o := range outcomes {
+		switch o.Status {
+		case models.RunFailed:
+			failed++
+		case models.RunPartial:
+			degraded++
+		}
+	}
+
+	switch {
+	case failed == 0 && degraded == 0:
+		return models.RunSuccess
+	case failed == len(outcomes):
+		return models.RunFailed
+	case float64(failed)/float64(len(outcomes)) > threshold:
+		return models.RunFailed
+	default:
+		return models.RunPartial
+	}
+}
@@ -0,0 +1,56 @@
+package workflows
+
+import (
+	"kits-worker/kits/models"
+	"testing"
+)
+
+func TestAggregateRunStatus(t *testing.T) {
+	kits := func(statuses ...models.RunStatus) []models.KitOutcome {
+		var out []models.KitOutcome
+		for _, s := range statuses {
+			out = append(out, models.KitOutcome{Status: s})
+		}
+		return out
+	}
+
+	cases := []struct {
+		outcomes  []models.KitOutcome
+		threshold float64
+		want      models.RunStatus
+	}{
+		{kits(models.RunSuccess, models.RunSuccess), 0.5, models.RunSuccess},
+		{kits(models.RunSuccess, models.RunPartial), 0, models.RunPartial},
+		{kits(models.RunSuccess, models.RunFailed), 0.5, models.RunPartial},
+		{kits(models.RunSuccess, models.RunFailed), 0.25, models.RunFailed},
+		{kits(models.RunFailed, models.RunFailed), 1, models.RunFailed},
+		{nil, 0.5, models.RunSuccess},
+	}
+	for i, c := range cases {
+		if got := aggregateRunStatus(c.outcomes, c.threshold); got != c.want {
+			t.Errorf("case %d: expected %s, got %s", i, c.want, got)
+		}
+	}
+}
+
+func TestSummarizeMates(t *testing.T) {
+	outcome := models.KitOutcome{Mates: []models.MateResult{{}, {Error: "down"}, {}, {}}}
+	summarizeMates(&outcome)
+	if outcome.Status != models.RunPartial || outcome.SuccessCount != 3 || outcome.FailureCount != 1 {
+		t.Fatalf("unexpected outcome: %+v", outcome)
+	}
+
+	// a refused failover fails the kit although its mates are reachable
+	outcome.Failovers = []models.VPNFailoverResult{{VPN: "orders"}, {VPN: "payments", Error: "pre-flight is no-go"}}
+	summarizeMates(&outcome)
+	if outcome.Status != models.RunFailed || outcome.SuccessCount != 3 || outcome.FailureCount != 2 {
+		t.Fatalf("expected the failed failover counted, got %+v", outcome)
+	}
+
+	outcome.Failovers = nil
+	outcome.Error = "no active mates"
+	summarizeMates(&outcome)
+	if outcome.Status != models.RunFailed {
+		t.Fatalf("expected FAILED, got %s", outcome.Status)
+	}
+}
@@ -0,0 +1,90 @@
+package workflows
+
+import (
+	"fmt"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// configSnapshotsVersion marks failovers snapshotting the VPN config before
+// and after the role changes.
+const configSnapshotsVersion = "config-snapshots"
+
+// captureConfigSnapshots takes a config snapshot of the VPN on every present
+// mate. A broker that cannot be read is reported with the error of its
+// snapshot, snapshots must never block a failover.
+func captureConfigSnapshots(
+	ctx workflow.Context,
+	vpn string,
+	phase string,
+	mates []*models.MateResult,
+	auth models.BasicAuth,
+) []models.ConfigSnapshotRef {
+
+	var refs []models.ConfigSnapshotRef
+	for _, m := range mates {
+		if m == nil {
+			continue
+		}
+		var ref models.ConfigSnapshotRef
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.CaptureVPNConfigSnapshotActivity,
+			vpn,
+			m,
+			phase,
+			auth,
+		).Get(ctx, &ref); err != nil {
+			workflow.GetLogger(ctx).Warn("vpn config snapshot failed", "vpn", vpn, "host", m.Host, "phase", phase, "error", err)
+			ref = models.ConfigSnapshotRef{VPN: vpn, Host: m.Host, Port: m.Port, DC: m.DC, Phase: phase, Error: err.Error()}
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// diffConfigSnapshots diffs the before and after snapshots of each broker.
+// A broker whose snapshots or diff failed is reported with the error.
+func diffConfigSnapshots(
+	ctx workflow.Context,
+	before []models.ConfigSnapshotRef,
+	after []models.ConfigSnapshotRef,
+) []models.ConfigDiff {
+
+	key := func(r models.ConfigSnapshotRef) string { return fmt.Sprintf("%s:%d", r.Host, r.Port) }
+	afterByBroker := make(map[string]models.ConfigSnapshotRef, len(after))
+	for _, r := range after {
+		afterByBroker[key(r)] = r
+	}
+
+	var diffs []models.ConfigDiff
+	for _, b := range before {
+		a, ok := afterByBroker[key(b)]
+		diff := models.ConfigDiff{VPN: b.VPN, Host: b.Host, DC: b.DC, Before: b.Path, After: a.Path}
+		switch {
+		case b.Error != "":
+			diff.Error = "before snapshot failed: " + b.Error
+		case !ok:
+			diff.Error = "no after snapshot"
+		case a.Error != "":
+			diff.Error = "after snapshot failed: " + a.Error
+		}
+		if diff.Error != "" {
+			diffs = append(diffs, diff)
+			continue
+		}
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.DiffVPNConfigSnapshotsActivity,
+			b,
+			a,
+		).Get(ctx, &diff); err != nil {
+			workflow.GetLogger(ctx).Warn("vpn config diff failed", "vpn", b.VPN, "host", b.Host, "error", err)
+			diff.Error = "diff failed: " + err.Error()
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
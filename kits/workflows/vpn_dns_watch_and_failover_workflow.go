@@ -6,22 +6,248 @@ import (
 	"kits-worker/kits/models"
 	"time"
 
-	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-func VPNDNSWatchAndExecuteVPNFailoverWorkflow(ctx workflow.Context, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth) error {
+// dnsWatchVersion versions the DNS watcher: DefaultVersion compares the
+// first IPv4 of each record, 1 checks which DC the record sets agreed by the
+// resolver quorum point to.
+const dnsWatchVersion = "dns-record-sets"
+
+func VPNDNSWatchAndExecuteVPNFailoverWorkflow(ctx workflow.Context, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth, opts models.FailoverOptions) (*models.VPNFailoverResult, error) {
+	// the policy in force, operators may change it while the watcher runs
+	opts.Policy = drPolicy(opts.Policy)
+	if err := setDRPolicyUpdateHandler(ctx, &opts.Policy); err != nil {
+		return nil, err
+	}
+	ctl, err := setWatcherControlHandlers(ctx, vpn, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *models.VPNFailoverResult
+	switch {
+	case workflow.GetVersion(ctx, dnsWatchVersion, workflow.DefaultVersion, 1) == workflow.DefaultVersion:
+		result, err = watchFirstIPv4(ctx, ctl, vpn, active, standby, auth, &opts)
+	case standby == nil || len(opts.SiteAddresses[standby.DC]) == 0:
+		workflow.GetLogger(ctx).Warn("VPN addresses of the standby site unknown, failing over on record changes", "vpn", vpn)
+		result, err = watchQuorum(ctx, ctl, vpn, active, standby, auth, &opts)
+	default:
+		result, err = watchSites(ctx, ctl, vpn, active, standby, auth, &opts)
+	}
+	// the kit workflow learns the outcome of its last actions
+	ctl.flush(ctx)
+	return result, err
+}
+
+// watchSites polls both VPN FQDNs on the resolver quorum and fails over
+// once both agreed sets point to the standby DC for
+// opts.DNSQuorum.StablePolls consecutive polls, whether or not they were
+// seen changing. Records pointing to the active DC, to addresses of no DC or
+// to both DCs are left alone.
+func watchSites(ctx workflow.Context, ctl *watcherControl, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth, opts *models.FailoverOptions) (*models.VPNFailoverResult, error) {
 	logger := workflow.GetLogger(ctx)
 
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: 15 * time.Second,
-		RetryPolicy: &temporal.RetryPolicy{
-			MaximumAttempts:    5,
-			InitialInterval:    time.Second * 5,
-			BackoffCoefficient: 2.0,
-		},
+	dnsNameA := vpn + config.VPN_PRIMARY_BROKER_FQDN_GLOBAL_DOMAIN
+	dnsNameB := vpn + config.VPN_BACKUP_BROKER_FQDN_GLOBAL_DOMAIN
+	stablePolls := max(opts.DNSQuorum.StablePolls, 1)
+	target := standby.DC
+
+	var lastSiteA, lastSiteB string
+	var pending *models.DNSTrigger
+
+	for {
+		if ctl.interrupted() {
+			return ctl.finish(ctx, vpn, active, standby, auth)
+		}
+		// activity options follow policy updates
+		actx := workflow.WithActivityOptions(ctx, watchActivityOptions(opts.Policy))
+		var currentA, currentB models.DNSQuorumAnswer
+
+		if err := workflow.ExecuteActivity(actx, activities.ResolveDNSQuorumActivity, dnsNameA, opts.DNSQuorum.Quorum).Get(ctx, &currentA); err != nil {
+			logger.Error("DNS resolve failed", "dnsName", dnsNameA, "error", err)
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := workflow.ExecuteActivity(actx, activities.ResolveDNSQuorumActivity, dnsNameB, opts.DNSQuorum.Quorum).Get(ctx, &currentB); err != nil {
+			logger.Error("DNS resolve failed", "dnsName", dnsNameB, "error", err)
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if currentA.Agreed == nil || currentB.Agreed == nil {
+			logger.Warn("No DNS quorum, records considered unchanged", "vpn", vpn,
+				"votesA", currentA.Votes, "votesB", currentB.Votes, "quorum", currentA.Quorum)
+			pending = nil
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		siteA := models.SiteOf(*currentA.Agreed, opts.SiteAddresses)
+		siteB := models.SiteOf(*currentB.Agreed, opts.SiteAddresses)
+		if siteA != lastSiteA || siteB != lastSiteB {
+			logger.Info("DNS resolved", "vpn", vpn, "a", currentA.Agreed.Addresses, "siteA", siteA,
+				"b", currentB.Agreed.Addresses, "siteB", siteB, "standbySite", target)
+			if siteA == "" || siteB == "" {
+				logger.Warn("DNS points to addresses of no site or of both, ignored", "vpn", vpn, "siteA", siteA, "siteB", siteB)
+			}
+			lastSiteA, lastSiteB = siteA, siteB
+		}
+
+		suppressed, err := ctl.suppressed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case siteA == target && siteB == target && suppressed:
+			logger.Info("DNS points to the standby site, failover suppressed", "vpn", vpn, "paused", ctl.state.Paused, "maintenance", ctl.state.Maintenance)
+			pending = nil
+		case siteA == target && siteB == target:
+			if pending == nil {
+				pending = &models.DNSTrigger{Site: target}
+			}
+			pending.A, pending.B = currentA, currentB
+			pending.StablePolls++
+			if pending.StablePolls < stablePolls {
+				logger.Info("DNS points to the standby site, not stable yet", "vpn", vpn, "polls", pending.StablePolls, "stablePolls", stablePolls)
+			} else if held, err := ctl.held(ctx); err != nil {
+				return nil, err
+			} else if !held {
+				result, err := executeFailover(ctx, ctl, vpn, active, standby, auth)
+				if err != nil {
+					return nil, err
+				}
+				result.DNSTrigger = pending
+				return result, nil
+			}
+		default:
+			pending = nil
+		}
+
+		if err := ctl.wait(ctx, dnsPollInterval(opts.Policy, *currentA.Agreed, *currentB.Agreed)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// watchQuorum polls both VPN FQDNs on the resolver quorum at their TTL,
+// for kits without site addresses. A
+// record only counts as changed when the quorum agrees on a new set, and
+// the failover starts once both records kept their new sets for
+// opts.DNSQuorum.StablePolls consecutive polls. Polls without agreement
+// leave the records as they were.
+func watchQuorum(ctx workflow.Context, ctl *watcherControl, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth, opts *models.FailoverOptions) (*models.VPNFailoverResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	dnsNameA := vpn + config.VPN_PRIMARY_BROKER_FQDN_GLOBAL_DOMAIN
+	dnsNameB := vpn + config.VPN_BACKUP_BROKER_FQDN_GLOBAL_DOMAIN
+	stablePolls := max(opts.DNSQuorum.StablePolls, 1)
+
+	// last agreed sets, and the change of both records waiting to be stable
+	var lastA, lastB *models.DNSRecordSet
+	var pending *models.DNSTrigger
+
+	for {
+		if ctl.interrupted() {
+			return ctl.finish(ctx, vpn, active, standby, auth)
+		}
+		// activity options follow policy updates
+		actx := workflow.WithActivityOptions(ctx, watchActivityOptions(opts.Policy))
+		var currentA, currentB models.DNSQuorumAnswer
+
+		if err := workflow.ExecuteActivity(actx, activities.ResolveDNSQuorumActivity, dnsNameA, opts.DNSQuorum.Quorum).Get(ctx, &currentA); err != nil {
+			logger.Error("DNS resolve failed", "dnsName", dnsNameA, "error", err)
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := workflow.ExecuteActivity(actx, activities.ResolveDNSQuorumActivity, dnsNameB, opts.DNSQuorum.Quorum).Get(ctx, &currentB); err != nil {
+			logger.Error("DNS resolve failed", "dnsName", dnsNameB, "error", err)
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if currentA.Agreed == nil || currentB.Agreed == nil {
+			logger.Warn("No DNS quorum, records considered unchanged", "vpn", vpn,
+				"votesA", currentA.Votes, "votesB", currentB.Votes, "quorum", currentA.Quorum)
+			pending = nil
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		logger.Info("DNS resolved", "vpn", vpn, "a", currentA.Agreed.Addresses, "b", currentB.Agreed.Addresses,
+			"votesA", currentA.Votes, "votesB", currentB.Votes, "quorum", currentA.Quorum)
+
+		dnsAChanged := lastA != nil && !currentA.Agreed.SameAddresses(*lastA)
+		dnsBChanged := lastB != nil && !currentB.Agreed.SameAddresses(*lastB)
+
+		if dnsAChanged || dnsBChanged {
+			logger.Info("DNS changes detected", "vpn", vpn, "dnsAChanged", dnsAChanged, "dnsBChanged", dnsBChanged)
+		}
+
+		suppressed, err := ctl.suppressed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if dnsAChanged && dnsBChanged && suppressed {
+			logger.Info("DNS change ignored, failover suppressed", "vpn", vpn, "paused", ctl.state.Paused, "maintenance", ctl.state.Maintenance)
+		}
+
+		if dnsAChanged && dnsBChanged && !suppressed {
+			if pending != nil && pending.A.Agreed.SameAddresses(*currentA.Agreed) && pending.B.Agreed.SameAddresses(*currentB.Agreed) {
+				pending.A, pending.B = currentA, currentB
+				pending.StablePolls++
+			} else {
+				pending = &models.DNSTrigger{A: currentA, B: currentB, StablePolls: 1}
+			}
+			if pending.StablePolls < stablePolls {
+				logger.Info("DNS change not stable yet", "vpn", vpn, "polls", pending.StablePolls, "stablePolls", stablePolls)
+			} else if held, err := ctl.held(ctx); err != nil {
+				return nil, err
+			} else if !held {
+				result, err := executeFailover(ctx, ctl, vpn, active, standby, auth)
+				if err != nil {
+					return nil, err
+				}
+				result.DNSTrigger = pending
+				return result, nil
+			}
+		} else {
+			// the baseline only moves while no change is pending
+			pending = nil
+			lastA, lastB = currentA.Agreed, currentB.Agreed
+		}
+
+		if err := ctl.wait(ctx, dnsPollInterval(opts.Policy, *currentA.Agreed, *currentB.Agreed)); err != nil {
+			return nil, err
+		}
 	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
+}
+
+// dnsPollInterval is the smallest TTL of the sets within the polling bounds
+// of the policy. Sets without TTL are polled at the lower bound.
+func dnsPollInterval(policy models.DRPolicy, sets ...models.DNSRecordSet) time.Duration {
+	interval := seconds(policy.DNSPollMaxSeconds)
+	for _, s := range sets {
+		interval = min(interval, time.Duration(s.TTL)*time.Second)
+	}
+	return max(interval, seconds(policy.DNSPollMinSeconds))
+}
+
+// watchFirstIPv4 is the watcher of runs started before record sets were
+// compared: it only follows the first IPv4 of each record.
+func watchFirstIPv4(ctx workflow.Context, ctl *watcherControl, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth, opts *models.FailoverOptions) (*models.VPNFailoverResult, error) {
+	logger := workflow.GetLogger(ctx)
 
 	dnsNameA := vpn + config.VPN_PRIMARY_BROKER_FQDN_GLOBAL_DOMAIN
 	dnsNameB := vpn + config.VPN_BACKUP_BROKER_FQDN_GLOBAL_DOMAIN
@@ -29,20 +255,29 @@ func VPNDNSWatchAndExecuteVPNFailoverWorkflow(ctx workflow.Context, vpn string,
 	var lastIPA, lastIPB string
 
 	for {
+		if ctl.interrupted() {
+			return ctl.finish(ctx, vpn, active, standby, auth)
+		}
+		// activity options follow policy updates
+		actx := workflow.WithActivityOptions(ctx, watchActivityOptions(opts.Policy))
 		var currentIPA, currentIPB string
 
 		// Resolve first DNS
-		if err := workflow.ExecuteActivity(ctx, activities.ResolveDNSActivity, dnsNameA).Get(ctx, &currentIPA); err != nil {
+		if err := workflow.ExecuteActivity(actx, activities.ResolveDNSActivity, dnsNameA).Get(ctx, &currentIPA); err != nil {
 			logger.Error("DNS resolve failed", "dnsName", dnsNameA, "error", err)
-			workflow.Sleep(ctx, 10*time.Second)
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		logger.Info("DNS resolved", "dnsName", dnsNameA, "ip", currentIPA)
 
 		// Resolve second DNS
-		if err := workflow.ExecuteActivity(ctx, activities.ResolveDNSActivity, dnsNameB).Get(ctx, &currentIPB); err != nil {
+		if err := workflow.ExecuteActivity(actx, activities.ResolveDNSActivity, dnsNameB).Get(ctx, &currentIPB); err != nil {
 			logger.Error("DNS resolve failed", "dnsName", dnsNameB, "error", err)
-			workflow.Sleep(ctx, 10*time.Second)
+			if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		logger.Info("DNS resolved", "dnsName", dnsNameB, "ip", currentIPB)
@@ -55,22 +290,35 @@ func VPNDNSWatchAndExecuteVPNFailoverWorkflow(ctx workflow.Context, vpn string,
 			logger.Info("DNS changes detected", "vpn", vpn, "dnsAChanged", dnsAChanged, "dnsBChanged", dnsBChanged)
 		}
 
-		dnsChanged := dnsAChanged && dnsBChanged
+		suppressed, err := ctl.suppressed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		dnsChanged := dnsAChanged && dnsBChanged && !suppressed
 
 		if dnsChanged {
-			if err := workflow.ExecuteChildWorkflow(ctx, VPNFailoverWorkflow, vpn, active, standby, auth).Get(ctx, nil); err != nil {
-				return err
-			}
-
-			logger.Info("VPN failover completed, stopping DNS watcher")
-			break
+			return executeFailover(ctx, ctl, vpn, active, standby, auth)
 		}
 
 		lastIPA = currentIPA
 		lastIPB = currentIPB
 
-		workflow.Sleep(ctx, 10*time.Second)
+		// a canceled watcher stops here instead of spinning
+		if err := ctl.wait(ctx, seconds(opts.Policy.DNSPollMinSeconds)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func executeFailover(ctx workflow.Context, ctl *watcherControl, vpn string, active *models.MateResult, standby *models.MateResult, auth models.BasicAuth) (*models.VPNFailoverResult, error) {
+	ctl.state.FailoverStarted = true
+	var result models.VPNFailoverResult
+	cctx := withChildWorkflowID(ctx, vpnFailoverWorkflowID(ctl.opts.Kit, vpn))
+	if err := workflow.ExecuteChildWorkflow(cctx, VPNFailoverWorkflow, vpn, active, standby, auth, *ctl.opts).Get(ctx, &result); err != nil {
+		return nil, err
 	}
 
-	return nil
+	result.Approval = ctl.approval
+	workflow.GetLogger(ctx).Info("VPN failover completed, stopping DNS watcher")
+	return &result, nil
 }
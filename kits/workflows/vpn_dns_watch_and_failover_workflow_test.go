@@ -0,0 +1,267 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// mockDNS answers ResolveDNSQuorumActivity with successive IPs per record,
+// agreed by a single resolver; the last IP of a record is repeated once
+// exhausted. An empty IP is an answer without quorum.
+func mockDNS(env *testsuite.TestWorkflowEnvironment, vpn string, ipsA, ipsB []string) {
+	answers := map[string][]string{
+		vpn + config.VPN_PRIMARY_BROKER_FQDN_GLOBAL_DOMAIN: ipsA,
+		vpn + config.VPN_BACKUP_BROKER_FQDN_GLOBAL_DOMAIN:  ipsB,
+	}
+	env.OnActivity(activities.ResolveDNSQuorumActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, name string, quorum int) (*models.DNSQuorumAnswer, error) {
+			ips, ok := answers[name]
+			if !ok {
+				return nil, errors.New("no such host " + name)
+			}
+			ip := ips[0]
+			if len(ips) > 1 {
+				answers[name] = ips[1:]
+			}
+			answer := &models.DNSQuorumAnswer{Name: name, Quorum: 1}
+			if ip != "" {
+				answer.Agreed = &models.DNSRecordSet{Name: name, Addresses: strings.Split(ip, ",")}
+				answer.Votes = 1
+			}
+			return answer, nil
+		})
+}
+
+func TestVPNDNSWatch_FailoverWhenBothRecordsChange(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders",
+		[]string{"10.0.1.1", "10.0.2.1", "10.0.2.1", "10.0.3.1"},
+		[]string{"10.0.1.2", "10.0.1.2", "10.0.2.2", "10.0.3.2"},
+	)
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, "orders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.VPNFailoverResult{VPN: "orders"}, nil)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result models.VPNFailoverResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.VPN != "orders" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	// polls 2 and 3 change a single record, poll 4 changes both
+	env.AssertActivityNumberOfCalls(t, "ResolveDNSQuorumActivity", 8)
+	env.AssertWorkflowNumberOfCalls(t, "VPNFailoverWorkflow", 1)
+}
+
+func TestVPNDNSWatch_PartialChangeDoesNotFailover(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders",
+		[]string{"10.0.1.1", "10.0.2.1"},
+		[]string{"10.0.1.2"},
+	)
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.VPNFailoverResult{}, nil)
+	env.RegisterDelayedCallback(env.CancelWorkflow, 10*time.Minute)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	if err := env.GetWorkflowError(); !temporal.IsCanceledError(err) {
+		t.Fatalf("expected the watcher to run until canceled, got %v", err)
+	}
+	env.AssertWorkflowNotCalled(t, "VPNFailoverWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestVPNDNSWatch_FailoverErrorFailsWatcher(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders",
+		[]string{"10.0.1.1", "10.0.2.1"},
+		[]string{"10.0.1.2", "10.0.2.2"},
+	)
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, temporal.NewApplicationError("vpn failover failed for vpn orders", "VPNFailoverFailed", models.VPNFailoverResult{VPN: "orders"}))
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	if err := env.GetWorkflowError(); err == nil {
+		t.Fatal("expected the failover error to fail the watcher")
+	}
+}
+
+func TestVPNDNSWatch_ComparesRecordSets(t *testing.T) {
+	env := newDRTestEnv(t)
+	// the first address of A stays, the set loses a member
+	mockDNS(env, "orders",
+		[]string{"10.0.1.1,10.0.1.3", "10.0.1.1"},
+		[]string{"10.0.1.2", "10.0.2.2"},
+	)
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, "orders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.VPNFailoverResult{VPN: "orders"}, nil)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	env.AssertActivityNumberOfCalls(t, "ResolveDNSQuorumActivity", 4)
+	env.AssertWorkflowNumberOfCalls(t, "VPNFailoverWorkflow", 1)
+}
+
+func TestVPNDNSWatch_WaitsForStableChange(t *testing.T) {
+	env := newDRTestEnv(t)
+	// the change flaps after two polls, then holds; a poll without quorum
+	// restarts the count
+	mockDNS(env, "orders",
+		[]string{"10.0.1.1", "10.0.2.1", "10.0.2.1", "10.0.1.1", "10.0.2.1", "", "10.0.2.1", "10.0.2.1", "10.0.2.1"},
+		[]string{"10.0.1.2", "10.0.2.2", "10.0.2.2", "10.0.1.2", "10.0.2.2"},
+	)
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, "orders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&models.VPNFailoverResult{VPN: "orders"}, nil)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{DNSQuorum: models.DNSQuorumOptions{StablePolls: 3}})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result models.VPNFailoverResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.DNSTrigger == nil || result.DNSTrigger.StablePolls != 3 || result.DNSTrigger.A.Agreed.String() != "10.0.2.1" {
+		t.Errorf("expected the trigger of the stable change, got %+v", result.DNSTrigger)
+	}
+	env.AssertActivityNumberOfCalls(t, "ResolveDNSQuorumActivity", 18)
+	env.AssertWorkflowNumberOfCalls(t, "VPNFailoverWorkflow", 1)
+}
+
+func TestVPNDNSWatch_FollowsSites(t *testing.T) {
+	sites := models.FailoverOptions{SiteAddresses: testSites, DNSQuorum: models.DNSQuorumOptions{StablePolls: 2}}
+
+	t.Run("records already on the standby site", func(t *testing.T) {
+		env := newDRTestEnv(t)
+		mockDNS(env, "orders", []string{"10.20.1.1"}, []string{"10.20.1.2"})
+		env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, "orders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(&models.VPNFailoverResult{VPN: "orders"}, nil)
+
+		env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, sites)
+
+		var result models.VPNFailoverResult
+		if err := env.GetWorkflowResult(&result); err != nil {
+			t.Fatal(err)
+		}
+		if result.DNSTrigger == nil || result.DNSTrigger.Site != "dc2" || result.DNSTrigger.StablePolls != 2 {
+			t.Errorf("expected a failover toward dc2 after 2 polls, got %+v", result.DNSTrigger)
+		}
+		env.AssertActivityNumberOfCalls(t, "ResolveDNSQuorumActivity", 4)
+	})
+
+	t.Run("re-IP and unknown addresses are ignored", func(t *testing.T) {
+		env := newDRTestEnv(t)
+		// dc1 re-IP, unknown addresses, then a record set spanning both sites
+		mockDNS(env, "orders",
+			[]string{"10.10.1.1", "10.10.9.1", "192.168.0.1", "10.20.1.1,10.10.1.1", "10.20.1.1"},
+			[]string{"10.10.1.2", "10.10.9.2", "192.168.0.2", "10.20.1.2", "10.10.1.2"},
+		)
+		env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(&models.VPNFailoverResult{}, nil)
+		env.RegisterDelayedCallback(env.CancelWorkflow, 10*time.Minute)
+
+		env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, sites)
+
+		if err := env.GetWorkflowError(); !temporal.IsCanceledError(err) {
+			t.Fatalf("expected the watcher to run until canceled, got %v", err)
+		}
+		env.AssertWorkflowNotCalled(t, "VPNFailoverWorkflow", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDNSPollInterval(t *testing.T) {
+	cases := []struct {
+		ttls []uint32
+		want time.Duration
+	}{
+		{ttls: []uint32{0, 0}, want: config.DNS_WATCH_MIN_INTERVAL},
+		{ttls: []uint32{30, 20}, want: 20 * time.Second},
+		{ttls: []uint32{5, 300}, want: config.DNS_WATCH_MIN_INTERVAL},
+		{ttls: []uint32{3600, 300}, want: config.DNS_WATCH_MAX_INTERVAL},
+	}
+	for _, c := range cases {
+		var sets []models.DNSRecordSet
+		for _, ttl := range c.ttls {
+			sets = append(sets, models.DNSRecordSet{TTL: ttl})
+		}
+		if got := dnsPollInterval(drPolicy(models.DRPolicy{}), sets...); got != c.want {
+			t.Errorf("ttls %v: expected %s, got %s", c.ttls, c.want, got)
+		}
+	}
+}
+
+func TestVPNDNSWatch_UpdatePolicy(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockDNS(env, "orders", []string{"10.20.1.1"}, []string{"10.20.1.2"})
+	start := env.Now()
+	var failoverOpts models.FailoverOptions
+	var failoverAt time.Duration
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, "orders", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ workflow.Context, vpn string, _, _ *models.MateResult, _ models.BasicAuth, opts models.FailoverOptions) (*models.VPNFailoverResult, error) {
+			failoverOpts, failoverAt = opts, env.Now().Sub(start)
+			return &models.VPNFailoverResult{VPN: vpn}, nil
+		})
+
+	var updated models.DRPolicy
+	var rejected error
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(UpdateDRPolicy, "slower", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { t.Errorf("unexpected rejection %v", err) },
+			OnAccept: func() {},
+			OnComplete: func(v interface{}, err error) {
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				updated = v.(models.DRPolicy)
+			},
+		}, models.DRPolicy{DNSPollMinSeconds: 30, DNSPollMaxSeconds: 30, FailoverMaxAttempts: 7})
+		env.UpdateWorkflow(UpdateDRPolicy, "invalid", &testsuite.TestUpdateCallback{
+			OnReject:   func(err error) { rejected = err },
+			OnAccept:   func() { t.Error("expected the update to be rejected") },
+			OnComplete: func(interface{}, error) {},
+		}, models.DRPolicy{DNSPollMinSeconds: 120})
+	}, 5*time.Second)
+
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{SiteAddresses: testSites, DNSQuorum: models.DNSQuorumOptions{StablePolls: 3}})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if updated.FailoverMaxAttempts != 7 || updated.WatchMaxAttempts != config.DNS_WATCH_ACTIVITY_MAX_ATTEMPTS {
+		t.Errorf("expected the update merged over the defaults, got %+v", updated)
+	}
+	if rejected == nil || !strings.Contains(rejected.Error(), "dnsPollMinSeconds") {
+		t.Errorf("expected the empty polling range to be rejected, got %v", rejected)
+	}
+	if failoverOpts.Policy != updated {
+		t.Errorf("expected the failover to get the updated policy, got %+v", failoverOpts.Policy)
+	}
+	// polls at 0s, 10s and, once updated, 40s
+	if failoverAt != 40*time.Second {
+		t.Errorf("expected the failover after 40s, got %s", failoverAt)
+	}
+}
@@ -3,30 +3,138 @@ package workflows
 import (
 	"kits-worker/kits/activities"
 	"kits-worker/kits/models"
-	"time"
 
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+const (
+	// sempCompatVersion marks runs checking the SEMP version of the brokers,
+	// before discovery in KitDRWorkflow and before a failover.
+	sempCompatVersion = "semp-compat"
+	// failoverPreflightVersion marks failovers running the pre-flight checks.
+	failoverPreflightVersion = "failover-preflight"
+	// canaryProbeVersion marks failovers able to probe the new active VPN.
+	canaryProbeVersion = "canary-probe"
+)
+
 func VPNFailoverWorkflow(
 	ctx workflow.Context,
 	vpn string,
 	active *models.MateResult,
 	standby *models.MateResult,
 	auth models.BasicAuth,
-) error {
+	opts models.FailoverOptions,
+) (*models.VPNFailoverResult, error) {
+
+	result := &models.VPNFailoverResult{VPN: vpn}
+
+	// only one failover of the VPN is in flight across the namespace
+	var lock *vpnLock
+	if workflow.GetVersion(ctx, vpnLockVersion, workflow.DefaultVersion, 1) == 1 {
+		var unlock func()
+		var err error
+		if lock, unlock, err = lockVPN(ctx, vpn, opts); err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	policy := drPolicy(opts.Policy)
+	ctx = workflow.WithActivityOptions(ctx, failoverActivityOptions(policy))
+
+	// 0) Refuse to fail over against unsupported or mismatched brokers
+	if workflow.GetVersion(ctx, sempCompatVersion, workflow.DefaultVersion, 1) == 1 {
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.CheckSEMPCompatibilityActivity,
+			activities.SEMPOperationFailover,
+			[]*models.MateResult{active, standby},
+			auth,
+		).Get(ctx, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// 0b) Pre-flight: replication bridge, config-sync and standby spool
+	if workflow.GetVersion(ctx, failoverPreflightVersion, workflow.DefaultVersion, 1) == 1 {
+		var preflight models.FailoverPreflight
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.FailoverPreflightActivity,
+			vpn,
+			active,
+			standby,
+			auth,
+		).Get(ctx, &preflight); err != nil {
+			return nil, err
+		}
+		if !preflight.Go && opts.Force {
+			preflight.Overridden = true
+			workflow.GetLogger(ctx).Warn("failover pre-flight is no-go, forced failover proceeds", "vpn", vpn, "preflight", preflight)
+		}
+		result.Preflight = preflight
+		if !preflight.Go && !opts.Force {
+			workflow.GetLogger(ctx).Error("failover pre-flight is no-go", "vpn", vpn, "reasons", preflight.Reasons)
+			return result, temporal.NewNonRetryableApplicationError(
+				"failover pre-flight is no-go for vpn "+vpn,
+				"FailoverPreflightNoGo",
+				nil,
+				result,
+			)
+		}
+		// a forced failover cannot demote an active site that does not answer
+		if opts.Force && preflight.ActiveUnreachable && active != nil {
+			workflow.GetLogger(ctx).Warn("active site unreachable, forced failover skips its demotion and drain", "vpn", vpn, "dc", active.DC)
+			result.ActiveSkipped = true
+			active = nil
+		}
+	}
 
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: 30 * time.Second,
-		RetryPolicy: &temporal.RetryPolicy{
-			MaximumAttempts: 3,
-		},
+	// 0b') Config snapshot of both sites before anything is mutated
+	mates := []*models.MateResult{active, standby}
+	snapshotted := workflow.GetVersion(ctx, configSnapshotsVersion, workflow.DefaultVersion, 1) == 1
+	var snapshots []models.ConfigSnapshotRef
+	if snapshotted {
+		snapshots = captureConfigSnapshots(ctx, vpn, "before", mates, auth)
+	}
+
+	// failed reports what changed on the brokers up to the failing step
+	failed := func(err error) (*models.VPNFailoverResult, error) {
+		if snapshotted {
+			result.ConfigDiffs = diffConfigSnapshots(ctx, snapshots, captureConfigSnapshots(ctx, vpn, "after", mates, auth))
+		}
+		return result, temporal.NewApplicationErrorWithCause(
+			"vpn failover failed for vpn "+vpn,
+			"VPNFailoverFailed",
+			err,
+			result,
+		)
+	}
+
+	// lockLost stops the failover before its next change, another failover
+	// of the VPN may hold the lock
+	lockLost := func(err error) (*models.VPNFailoverResult, error) {
+		if snapshotted {
+			result.ConfigDiffs = diffConfigSnapshots(ctx, snapshots, captureConfigSnapshots(ctx, vpn, "after", mates, auth))
+		}
+		return result, err
+	}
+
+	// 0c) Baseline of the clients on both sites for the reconnection check
+	reconnectionCheck := opts.ReconnectionCheck != nil &&
+		workflow.GetVersion(ctx, reconnectionCheckVersion, workflow.DefaultVersion, 1) == 1
+	var before, newBefore *models.VPNConnections
+	if reconnectionCheck {
+		before = vpnConnections(ctx, vpn, active, auth)
+		newBefore = vpnConnections(ctx, vpn, standby, auth)
 	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
 
 	// 1) ACTIVE → STANDBY
 	if active != nil {
+		if err := lock.check(result); err != nil {
+			return lockLost(err)
+		}
 		if err := workflow.ExecuteActivity(
 			ctx,
 			activities.SetVPNReplicationRoleActivity,
@@ -36,25 +144,19 @@ func VPNFailoverWorkflow(
 			"standby",
 			auth,
 		).Get(ctx, nil); err != nil {
-			return err
+			return failed(err)
 		}
 
-		// 2) Monitor replication queue (12 retries, 5s)
+		// 2) Monitor replication queue (12 retries, 5s by default)
 		if err := workflow.ExecuteActivity(
-			workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
-				StartToCloseTimeout: 70 * time.Second,
-				RetryPolicy: &temporal.RetryPolicy{
-					MaximumAttempts: 12,
-					InitialInterval: 5 * time.Second,
-				},
-			}),
+			workflow.WithActivityOptions(ctx, drainActivityOptions(policy)),
 			activities.WaitForReplicationDrainActivity,
 			active.Host,
 			active.Port,
 			vpn,
 			auth,
 		).Get(ctx, nil); err != nil {
-			return err
+			return failed(err)
 		}
 	} else {
 		workflow.GetLogger(ctx).Warn(
@@ -63,6 +165,9 @@ func VPNFailoverWorkflow(
 	}
 
 	// 3) Verify VPN is standby on other DC
+	if err := lock.check(result); err != nil {
+		return lockLost(err)
+	}
 	if err := workflow.ExecuteActivity(
 		ctx,
 		activities.VerifyVPNRoleActivity,
@@ -72,10 +177,13 @@ func VPNFailoverWorkflow(
 		"standby",
 		auth,
 	).Get(ctx, nil); err != nil {
-		return err
+		return failed(err)
 	}
 
 	// 4) STANDBY → ACTIVE (other DC)
+	if err := lock.check(result); err != nil {
+		return lockLost(err)
+	}
 	if err := workflow.ExecuteActivity(
 		ctx,
 		activities.SetVPNReplicationRoleActivity,
@@ -85,7 +193,7 @@ func VPNFailoverWorkflow(
 		"active",
 		auth,
 	).Get(ctx, nil); err != nil {
-		return err
+		return failed(err)
 	}
 
 	// 5) Final validation that vpn is standby on previously main dc and is now active on previously standby dc
@@ -97,10 +205,40 @@ func VPNFailoverWorkflow(
 		standby,
 		auth,
 	).Get(ctx, nil); err != nil {
-		return err
+		return failed(err)
+	}
+
+	if snapshotted {
+		result.ConfigDiffs = diffConfigSnapshots(ctx, snapshots, captureConfigSnapshots(ctx, vpn, "after", mates, auth))
+	}
+
+	// 6) Optional: did the clients follow the failover?
+	if reconnectionCheck {
+		result.Reconnection = verifyReconnection(ctx, vpn, active, standby, before, newBefore, *opts.ReconnectionCheck, auth)
+	}
+
+	// 7) Optional: does the new active VPN actually carry messages?
+	if opts.Probe != nil && workflow.GetVersion(ctx, canaryProbeVersion, workflow.DefaultVersion, 1) == 1 {
+		var probe models.ProbeResult
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.PublishSubscribeProbeActivity,
+			vpn,
+			standby,
+			*opts.Probe,
+			auth,
+		).Get(ctx, &probe); err != nil {
+			probe = models.ProbeResult{VPN: vpn, Host: standby.Host, Error: err.Error()}
+		}
+		if !probe.Consumed {
+			workflow.GetLogger(ctx).Error("ALERT: canary not consumed on new active vpn", "vpn", vpn, "error", probe.Error)
+		} else if !probe.Verified {
+			workflow.GetLogger(ctx).Warn("canary matched by size only on new active vpn", "vpn", vpn, "topic", probe.Topic)
+		}
+		result.Probe = &probe
 	}
 
 	//todo check if any error and return it as failed
 
-	return nil
+	return result, nil
 }
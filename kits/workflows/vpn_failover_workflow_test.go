@@ -0,0 +1,145 @@
+package workflows
+
+import (
+	"errors"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestVPNFailoverWorkflow_Success(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result models.VPNFailoverResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Preflight.Go || len(result.ConfigDiffs) != 2 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	env.AssertActivityCalled(t, "SetVPNReplicationRoleActivity", mock.Anything, "dc1-mate1", 8080, "orders", "standby", mock.Anything)
+	env.AssertActivityCalled(t, "SetVPNReplicationRoleActivity", mock.Anything, "dc2-mate1", 8080, "orders", "active", mock.Anything)
+}
+
+func TestVPNFailoverWorkflow_ActiveSiteDown(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", (*models.MateResult)(nil), testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	env.AssertActivityNumberOfCalls(t, "WaitForReplicationDrainActivity", 0)
+	env.AssertActivityNumberOfCalls(t, "SetVPNReplicationRoleActivity", 1)
+}
+
+func TestVPNFailoverWorkflow_PreflightNoGo(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.FailoverPreflightActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(models.FailoverPreflight{VPN: "orders", Reasons: []string{"replication bridge down"}}, nil)
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != "FailoverPreflightNoGo" {
+		t.Fatalf("expected FailoverPreflightNoGo, got %v", err)
+	}
+	env.AssertActivityNumberOfCalls(t, "SetVPNReplicationRoleActivity", 0)
+}
+
+func TestVPNFailoverWorkflow_SnapshotFailuresReported(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.CaptureVPNConfigSnapshotActivity, mock.Anything, mock.Anything, testDC2Active, "after", mock.Anything).
+		Return(models.ConfigSnapshotRef{}, errors.New("503 Service Unavailable"))
+	env.OnActivity(activities.DiffVPNConfigSnapshotsActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(models.ConfigDiff{}, errors.New("corrupt snapshot"))
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	var result models.VPNFailoverResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ConfigDiffs) != 2 {
+		t.Fatalf("expected a diff report per site, got %+v", result.ConfigDiffs)
+	}
+	if d := result.ConfigDiffs[0]; d.DC != "dc1" || !strings.Contains(d.Error, "corrupt snapshot") {
+		t.Errorf("expected the failed diff of dc1 reported, got %+v", d)
+	}
+	if d := result.ConfigDiffs[1]; d.DC != "dc2" || !strings.Contains(d.Error, "after snapshot failed") {
+		t.Errorf("expected the failed snapshot of dc2 reported, got %+v", d)
+	}
+}
+
+func TestVPNFailoverWorkflow_DrainTimeout(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.WaitForReplicationDrainActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("replication still in progress"))
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	result := failoverFailure(t, env.GetWorkflowError())
+	if len(result.ConfigDiffs) != 2 {
+		t.Errorf("expected config diffs of both sites in the partial report, got %+v", result)
+	}
+	env.AssertActivityNumberOfCalls(t, "WaitForReplicationDrainActivity", 12)
+	env.AssertActivityNotCalled(t, "SetVPNReplicationRoleActivity", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "active", mock.Anything)
+}
+
+func TestVPNFailoverWorkflow_DrainPolicy(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.WaitForReplicationDrainActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("replication still in progress"))
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{Policy: models.DRPolicy{DrainMaxAttempts: 3}})
+
+	failoverFailure(t, env.GetWorkflowError())
+	env.AssertActivityNumberOfCalls(t, "WaitForReplicationDrainActivity", 3)
+}
+
+func TestVPNFailoverWorkflow_RoleChangeFailure(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.SetVPNReplicationRoleActivity, mock.Anything, "dc2-mate1", 8080, "orders", "active", mock.Anything).
+		Return(errors.New("setting replicationRole active on vpn orders failed: 400 Bad Request"))
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{})
+
+	failoverFailure(t, env.GetWorkflowError())
+	env.AssertActivityNumberOfCalls(t, "ValidateFinalRolesActivity", 0)
+}
+
+// failoverFailure checks err is a VPNFailoverFailed error and returns the
+// partial report it carries.
+func failoverFailure(t *testing.T, err error) models.VPNFailoverResult {
+	t.Helper()
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != "VPNFailoverFailed" {
+		t.Fatalf("expected VPNFailoverFailed, got %v", err)
+	}
+	var result models.VPNFailoverResult
+	if err := appErr.Details(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
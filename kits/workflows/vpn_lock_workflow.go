@@ -0,0 +1,245 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"slices"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// vpnLockVersion marks failovers holding the lock of their VPN, its lease
+// renewed until they complete.
+const vpnLockVersion = "vpn-lock"
+
+// VPNLockWorkflow is the lock serializing the failovers of a VPN, started
+// with the acquire update by activities.TemporalVPNLocker under the
+// workflow ID activities.VPNLockID(key).
+//
+// The acquire update of an owner completes once it holds the lock, owners
+// are served in order and an owner already holding the lock gets it at once.
+// A waiting owner gives up after config.VPN_LOCK_WAIT_TIMEOUT with a
+// VPNLockBusy error. The release update frees the lock held by an owner, or
+// gives up its wait. The renew update of the holder extends its lease and
+// fails with a VPNLockLost error for any other owner. A lock neither
+// released nor renewed within config.VPN_LOCK_LEASE is taken back. The
+// "holder" query returns the models.VPNLockState.
+//
+// The workflow completes once nobody holds or waits for the lock, or when
+// nobody asked for it within config.VPN_LOCK_WAIT_TIMEOUT.
+func VPNLockWorkflow(ctx workflow.Context, key string) error {
+	state := models.VPNLockState{Key: key}
+	// grants counts the acquisitions and renewals the lease renewals, both
+	// start a new lease
+	grants, renewals := 0, 0
+
+	validOwner := func(ctx workflow.Context, owner string) error {
+		if owner == "" {
+			return errors.New("vpn lock owner is required")
+		}
+		return nil
+	}
+
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, activities.VPNLockAcquire,
+		func(ctx workflow.Context, owner string) error {
+			if state.Holder == owner {
+				return nil
+			}
+			if !slices.Contains(state.Waiting, owner) {
+				state.Waiting = append(state.Waiting, owner)
+			}
+			ok, err := workflow.AwaitWithTimeout(ctx, config.VPN_LOCK_WAIT_TIMEOUT, func() bool {
+				return !slices.Contains(state.Waiting, owner) || state.Holder == "" && state.Waiting[0] == owner
+			})
+			if err != nil {
+				return err
+			}
+			if !ok || !slices.Contains(state.Waiting, owner) {
+				state.Waiting = slices.DeleteFunc(state.Waiting, func(w string) bool { return w == owner })
+				return temporal.NewNonRetryableApplicationError(
+					fmt.Sprintf("vpn lock %s held by %s", key, state.Holder), activities.VPNLockBusy, nil)
+			}
+			state.Waiting = state.Waiting[1:]
+			state.Holder = owner
+			state.Since = workflow.Now(ctx)
+			grants++
+			workflow.GetLogger(ctx).Info("VPN lock acquired", "key", key, "owner", owner)
+			return nil
+		},
+		workflow.UpdateHandlerOptions{Validator: validOwner},
+	); err != nil {
+		return err
+	}
+
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, activities.VPNLockRelease,
+		func(ctx workflow.Context, owner string) error {
+			state.Waiting = slices.DeleteFunc(state.Waiting, func(w string) bool { return w == owner })
+			if state.Holder == owner {
+				state.Holder = ""
+				state.Since = time.Time{}
+				state.Renewed = time.Time{}
+				workflow.GetLogger(ctx).Info("VPN lock released", "key", key, "owner", owner)
+			}
+			return nil
+		},
+		workflow.UpdateHandlerOptions{Validator: validOwner},
+	); err != nil {
+		return err
+	}
+
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, activities.VPNLockRenew,
+		func(ctx workflow.Context, owner string) error {
+			if state.Holder != owner {
+				return temporal.NewNonRetryableApplicationError(
+					fmt.Sprintf("vpn lock %s not held by %s", key, owner), activities.VPNLockLost, nil)
+			}
+			renewals++
+			state.Renewed = workflow.Now(ctx)
+			return nil
+		},
+		workflow.UpdateHandlerOptions{Validator: validOwner},
+	); err != nil {
+		return err
+	}
+
+	if err := workflow.SetQueryHandler(ctx, activities.VPNLockQuery, func() (models.VPNLockState, error) {
+		return state, nil
+	}); err != nil {
+		return err
+	}
+
+	idle := func() bool {
+		return state.Holder == "" && len(state.Waiting) == 0 && workflow.AllHandlersFinished(ctx)
+	}
+	for {
+		if err := workflow.Await(ctx, func() bool { return state.Holder != "" || idle() }); err != nil {
+			return err
+		}
+		if idle() {
+			if grants > 0 {
+				return nil
+			}
+			// started without acquisition, an owner may still come
+			requested, err := workflow.AwaitWithTimeout(ctx, config.VPN_LOCK_WAIT_TIMEOUT, func() bool { return !idle() })
+			if err != nil || !requested {
+				return err
+			}
+			continue
+		}
+
+		held, renewed := grants, renewals
+		released, err := workflow.AwaitWithTimeout(ctx, config.VPN_LOCK_LEASE, func() bool {
+			return state.Holder == "" || grants != held || renewals != renewed
+		})
+		if err != nil {
+			return err
+		}
+		if !released {
+			workflow.GetLogger(ctx).Warn("VPN lock lease expired, lock taken back", "key", key, "owner", state.Holder)
+			state.Holder = ""
+			state.Since = time.Time{}
+			state.Renewed = time.Time{}
+		}
+	}
+}
+
+// vpnLockKey identifies a VPN across the namespace: the failovers of the
+// VPN of a kit are serialized whichever run starts them.
+func vpnLockKey(kit, vpn string) string {
+	if kit == "" {
+		return vpn
+	}
+	return kit + "/" + vpn
+}
+
+// vpnLock is the lock of a VPN held by a failover.
+type vpnLock struct {
+	key   string
+	owner string
+	held  bool
+	// lost is the VPNLockLost error of a renewal, the lock is held by
+	// another failover from then on
+	lost error
+}
+
+// check fails with a non-retryable VPNLockLost error carrying details once
+// the lock is lost, before each change on the brokers. A failover started
+// before the lock has none and always passes.
+func (l *vpnLock) check(details ...interface{}) error {
+	if l == nil || l.lost == nil {
+		return nil
+	}
+	return temporal.NewNonRetryableApplicationError(
+		fmt.Sprintf("vpn lock %s lost by %s", l.key, l.owner), activities.VPNLockLost, l.lost, details...)
+}
+
+// lockVPN waits until the failover holds the lock of its VPN and returns it
+// with the function releasing it, which also runs once the failover is
+// canceled. The lease is renewed until the lock is released or lost.
+func lockVPN(ctx workflow.Context, vpn string, opts models.FailoverOptions) (*vpnLock, func(), error) {
+	info := workflow.GetInfo(ctx)
+	lock := &vpnLock{
+		key:   vpnLockKey(opts.Kit, vpn),
+		owner: info.WorkflowExecution.ID + "/" + info.WorkflowExecution.RunID,
+	}
+
+	release := func() {
+		lock.held = false
+		ctx, _ := workflow.NewDisconnectedContext(ctx)
+		if err := workflow.ExecuteActivity(
+			workflow.WithActivityOptions(ctx, failoverActivityOptions(drPolicy(opts.Policy))),
+			activities.ReleaseVPNLockActivity,
+			lock.key,
+			lock.owner,
+		).Get(ctx, nil); err != nil {
+			workflow.GetLogger(ctx).Warn("VPN lock not released, it is taken back after its lease", "key", lock.key, "error", err)
+		}
+	}
+
+	ao := failoverActivityOptions(drPolicy(opts.Policy))
+	ao.StartToCloseTimeout = config.VPN_LOCK_WAIT_TIMEOUT + time.Minute
+	if err := workflow.ExecuteActivity(
+		workflow.WithActivityOptions(ctx, ao),
+		activities.AcquireVPNLockActivity,
+		lock.key,
+		lock.owner,
+	).Get(ctx, nil); err != nil {
+		// an acquisition granted after the activity gave up is not kept
+		release()
+		return nil, nil, err
+	}
+
+	lock.held = true
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		renewVPNLock(ctx, lock, drPolicy(opts.Policy))
+	})
+	return lock, release, nil
+}
+
+// renewVPNLock renews the lease of the lock every
+// config.VPN_LOCK_RENEW_INTERVAL until it is released, so that a failover
+// outlasting config.VPN_LOCK_LEASE keeps it. It stops once the lock is lost.
+func renewVPNLock(ctx workflow.Context, lock *vpnLock, policy models.DRPolicy) {
+	ctx = workflow.WithActivityOptions(ctx, failoverActivityOptions(policy))
+	for {
+		released, err := workflow.AwaitWithTimeout(ctx, config.VPN_LOCK_RENEW_INTERVAL, func() bool { return !lock.held })
+		if err != nil || released {
+			return
+		}
+		err = workflow.ExecuteActivity(ctx, activities.RenewVPNLockActivity, lock.key, lock.owner).Get(ctx, nil)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) && appErr.Type() == activities.VPNLockLost {
+			workflow.GetLogger(ctx).Error("VPN lock lost, the failover stops before its next change", "key", lock.key, "error", err)
+			lock.lost = err
+			return
+		}
+		if err != nil {
+			workflow.GetLogger(ctx).Warn("VPN lock lease not renewed", "key", lock.key, "error", err)
+		}
+	}
+}
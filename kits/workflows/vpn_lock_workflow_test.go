@@ -0,0 +1,252 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// lockResult is when a VPN lock update completed, or why it was rejected or
+// failed.
+type lockResult struct {
+	at  time.Duration
+	err error
+}
+
+// lockUpdate sends a VPN lock update for owner at delay.
+func lockUpdate(env *testsuite.TestWorkflowEnvironment, delay time.Duration, update, owner string) *lockResult {
+	start := env.Now()
+	res := &lockResult{at: -1}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(update, update+owner+delay.String(), &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { res.err = err },
+			OnAccept: func() {},
+			OnComplete: func(_ interface{}, err error) {
+				res.at, res.err = env.Now().Sub(start), err
+			},
+		}, owner)
+	}, delay)
+	return res
+}
+
+func TestVPNLockWorkflow(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	first := lockUpdate(env, time.Second, activities.VPNLockAcquire, "run-1")
+	second := lockUpdate(env, 2*time.Second, activities.VPNLockAcquire, "run-2")
+	again := lockUpdate(env, 3*time.Second, activities.VPNLockAcquire, "run-1")
+	noOwner := lockUpdate(env, 4*time.Second, activities.VPNLockAcquire, "")
+	var state models.VPNLockState
+	env.RegisterDelayedCallback(func() {
+		val, err := env.QueryWorkflow(activities.VPNLockQuery)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err := val.Get(&state); err != nil {
+			t.Error(err)
+		}
+	}, 5*time.Second)
+	lockUpdate(env, 10*time.Second, activities.VPNLockRelease, "run-1")
+	lockUpdate(env, 20*time.Second, activities.VPNLockRelease, "run-2")
+
+	env.ExecuteWorkflow(VPNLockWorkflow, "kit1/orders")
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if first.err != nil || first.at != time.Second {
+		t.Errorf("expected the free lock acquired at once, got %+v", first)
+	}
+	if again.err != nil || again.at != 3*time.Second {
+		t.Errorf("expected the holder to get the lock again at once, got %+v", again)
+	}
+	if second.err != nil || second.at != 10*time.Second {
+		t.Errorf("expected the second owner to get the lock once released, got %+v", second)
+	}
+	if noOwner.err == nil {
+		t.Error("expected an acquisition without owner rejected")
+	}
+	if state.Holder != "run-1" || len(state.Waiting) != 1 || state.Waiting[0] != "run-2" {
+		t.Errorf("unexpected lock state %+v", state)
+	}
+}
+
+func TestVPNLockWorkflow_BusyAndLease(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	lockUpdate(env, time.Second, activities.VPNLockAcquire, "run-1")
+	busy := lockUpdate(env, 2*time.Second, activities.VPNLockAcquire, "run-2")
+	start := env.Now()
+
+	env.ExecuteWorkflow(VPNLockWorkflow, "kit1/orders")
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var appErr *temporal.ApplicationError
+	if !errors.As(busy.err, &appErr) || appErr.Type() != activities.VPNLockBusy || busy.at != 2*time.Second+config.VPN_LOCK_WAIT_TIMEOUT {
+		t.Errorf("expected the wait to give up with VPNLockBusy, got %+v", busy)
+	}
+	// the lock never released is taken back after its lease
+	if got := env.Now().Sub(start); got != time.Second+config.VPN_LOCK_LEASE {
+		t.Errorf("expected the lock workflow to complete once the lease expired, got %s", got)
+	}
+}
+
+func TestVPNLockWorkflow_Renew(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	lockUpdate(env, time.Second, activities.VPNLockAcquire, "run-1")
+	renewed := lockUpdate(env, 20*time.Minute, activities.VPNLockRenew, "run-1")
+	other := lockUpdate(env, 21*time.Minute, activities.VPNLockRenew, "run-2")
+	start := env.Now()
+
+	env.ExecuteWorkflow(VPNLockWorkflow, "kit1/orders")
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if renewed.err != nil {
+		t.Errorf("expected the holder to renew its lease, got %v", renewed.err)
+	}
+	var appErr *temporal.ApplicationError
+	if !errors.As(other.err, &appErr) || appErr.Type() != activities.VPNLockLost {
+		t.Errorf("expected another owner to fail with VPNLockLost, got %v", other.err)
+	}
+	// the lease runs from the renewal
+	if got := env.Now().Sub(start); got != 20*time.Minute+config.VPN_LOCK_LEASE {
+		t.Errorf("expected the lock taken back a lease after its renewal, got %s", got)
+	}
+}
+
+func TestVPNFailoverWorkflow_RenewsVPNLock(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.AcquireVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(activities.ReleaseVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(activities.RenewVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	// a drain outlasting the lease
+	env.OnActivity(activities.WaitForReplicationDrainActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		After(config.VPN_LOCK_LEASE + time.Minute).Return(nil)
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{Kit: "kit1"})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	renewals := int((config.VPN_LOCK_LEASE + time.Minute) / config.VPN_LOCK_RENEW_INTERVAL)
+	env.AssertActivityNumberOfCalls(t, "RenewVPNLockActivity", renewals)
+}
+
+func TestVPNFailoverWorkflow_VPNLockLost(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.AcquireVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(activities.ReleaseVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(activities.RenewVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(temporal.NewNonRetryableApplicationError("vpn lock kit1/orders not held", activities.VPNLockLost, nil))
+	// the lease is lost while the active site drains
+	env.OnActivity(activities.WaitForReplicationDrainActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		After(config.VPN_LOCK_RENEW_INTERVAL + time.Minute).Return(nil)
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{Kit: "kit1"})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != activities.VPNLockLost || !appErr.NonRetryable() {
+		t.Fatalf("expected a non-retryable VPNLockLost, got %v", err)
+	}
+	// demoted before the loss, never promoted after it
+	env.AssertActivityNumberOfCalls(t, "SetVPNReplicationRoleActivity", 1)
+	env.AssertActivityNumberOfCalls(t, "VerifyVPNRoleActivity", 0)
+	env.AssertActivityNumberOfCalls(t, "RenewVPNLockActivity", 1)
+}
+
+func TestVPNFailoverWorkflow_LocksVPN(t *testing.T) {
+	env := newDRTestEnv(t)
+	var calls []string
+	env.OnActivity(activities.AcquireVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, key, owner string) error {
+			calls = append(calls, "acquire "+key+" "+owner)
+			return nil
+		})
+	env.OnActivity(activities.ReleaseVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, key, owner string) error {
+			calls = append(calls, "release "+key+" "+owner)
+			return nil
+		})
+	env.OnActivity(activities.SetVPNReplicationRoleActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("semp down"))
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{Kit: "kit1"})
+
+	if env.GetWorkflowError() == nil {
+		t.Fatal("expected the failover to fail")
+	}
+	owner := "default-test-workflow-id/default-test-run-id"
+	if len(calls) != 2 || calls[0] != "acquire kit1/orders "+owner || calls[1] != "release kit1/orders "+owner {
+		t.Errorf("expected the lock of the VPN held during the failover, got %v", calls)
+	}
+}
+
+func TestVPNFailoverWorkflow_VPNLockBusy(t *testing.T) {
+	env := newDRTestEnv(t)
+	env.OnActivity(activities.AcquireVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(temporal.NewNonRetryableApplicationError("vpn lock kit1/orders still held by another failover", activities.VPNLockBusy, nil))
+	env.OnActivity(activities.ReleaseVPNLockActivity, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockFailoverActivities(env)
+
+	env.ExecuteWorkflow(VPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{}, models.FailoverOptions{Kit: "kit1"})
+
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != activities.VPNLockBusy {
+		t.Fatalf("expected VPNLockBusy, got %v", err)
+	}
+	env.AssertActivityNumberOfCalls(t, "FailoverPreflightActivity", 0)
+	env.AssertActivityNumberOfCalls(t, "ReleaseVPNLockActivity", 1)
+}
+
+func TestDRChildWorkflowIDs(t *testing.T) {
+	env := newDRTestEnv(t)
+	mockKit(env, []string{"orders"})
+	var watchers []string
+	env.OnWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx workflow.Context, vpn string, _, _ *models.MateResult, _ models.BasicAuth, opts models.FailoverOptions) (*models.VPNFailoverResult, error) {
+			watchers = append(watchers, workflow.GetInfo(ctx).WorkflowExecution.ID, opts.Kit)
+			return &models.VPNFailoverResult{VPN: vpn}, nil
+		})
+	runKitDR(t, env)
+	if len(watchers) != 2 || watchers[0] != "vpn-dns-watch/kit1/orders" || watchers[1] != "kit1" {
+		t.Errorf("expected the watcher named after the kit and VPN, got %v", watchers)
+	}
+
+	env = newDRTestEnv(t)
+	mockDNS(env, "orders", []string{"10.10.1.1", "10.20.1.1"}, []string{"10.10.1.2", "10.20.1.2"})
+	var failovers []string
+	env.OnWorkflow(VPNFailoverWorkflow, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(ctx workflow.Context, vpn string, _, _ *models.MateResult, _ models.BasicAuth, _ models.FailoverOptions) (*models.VPNFailoverResult, error) {
+			failovers = append(failovers, workflow.GetInfo(ctx).WorkflowExecution.ID)
+			return &models.VPNFailoverResult{VPN: vpn}, nil
+		})
+	env.ExecuteWorkflow(VPNDNSWatchAndExecuteVPNFailoverWorkflow, "orders", testDC1Active, testDC2Active, models.BasicAuth{},
+		models.FailoverOptions{Kit: "kit1", SiteAddresses: testSites})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if len(failovers) != 1 || failovers[0] != "vpn-failover/kit1/orders" {
+		t.Errorf("expected the failover named after the kit and VPN, got %v", failovers)
+	}
+}
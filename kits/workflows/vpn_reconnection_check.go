@@ -0,0 +1,153 @@
+package workflows
+
+import (
+	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// reconnectionCheckVersion marks failovers able to verify that the clients
+// followed the failover.
+const reconnectionCheckVersion = "reconnection-check"
+
+// reconnectionWindow returns the polling window and interval, applying the
+// config defaults to unset values.
+func reconnectionWindow(check models.ReconnectionCheck) (time.Duration, time.Duration) {
+	window := config.RECONNECTION_CHECK_DEFAULT_WINDOW
+	if check.WindowSeconds > 0 {
+		window = time.Duration(check.WindowSeconds) * time.Second
+	}
+	interval := config.RECONNECTION_CHECK_DEFAULT_INTERVAL
+	if check.IntervalSeconds > 0 {
+		interval = time.Duration(check.IntervalSeconds) * time.Second
+	}
+	return window, interval
+}
+
+// evaluateReconnection updates the verdict from the latest snapshots. Traffic
+// has resumed once clients moved to the new active site, counted from its
+// baseline before the promotion, with queue consumers bound again if the old
+// site had any. The old site is drained once no client is left on it (or it
+// is unreachable).
+func evaluateReconnection(report *models.ReconnectionReport) {
+	report.TrafficResumed = false
+	if n := report.NewActive; n != nil {
+		report.ClientsMoved = n.Clients
+		if b := report.NewActiveBefore; b != nil {
+			report.ClientsMoved = max(n.Clients-b.Clients, 0)
+		}
+		report.TrafficResumed = report.ClientsMoved > 0 &&
+			(report.Before == nil || report.Before.QueueBinds == 0 || n.QueueBinds > 0)
+	}
+	report.OldSiteDrained = report.OldActive == nil || report.OldActive.Clients == 0
+}
+
+// vpnConnections snapshots the clients of the VPN on a site, nil when the
+// site is absent or unreachable.
+func vpnConnections(ctx workflow.Context, vpn string, m *models.MateResult, auth models.BasicAuth) *models.VPNConnections {
+	if m == nil {
+		return nil
+	}
+	var snap models.VPNConnections
+	if err := workflow.ExecuteActivity(
+		ctx,
+		activities.VPNConnectionsActivity,
+		m.Host,
+		m.Port,
+		vpn,
+		auth,
+	).Get(ctx, &snap); err != nil {
+		workflow.GetLogger(ctx).Warn("site connections unavailable", "vpn", vpn, "dc", m.DC, "error", err)
+		return nil
+	}
+	return &snap
+}
+
+// verifyReconnection polls the client connections of both sites until
+// traffic resumed on the new active site and drained from the old one, or
+// until the window expires. It never fails the failover, it only reports.
+func verifyReconnection(
+	ctx workflow.Context,
+	vpn string,
+	oldActive *models.MateResult,
+	newActive *models.MateResult,
+	before *models.VPNConnections,
+	newBefore *models.VPNConnections,
+	check models.ReconnectionCheck,
+	auth models.BasicAuth,
+) *models.ReconnectionReport {
+
+	logger := workflow.GetLogger(ctx)
+	window, interval := reconnectionWindow(check)
+	deadline := workflow.Now(ctx).Add(window)
+
+	report := &models.ReconnectionReport{Before: before, NewActiveBefore: newBefore}
+
+	for {
+		if err := workflow.Sleep(ctx, interval); err != nil {
+			break
+		}
+		report.Polls++
+
+		var snap models.VPNConnections
+		if err := workflow.ExecuteActivity(
+			ctx,
+			activities.VPNConnectionsActivity,
+			newActive.Host,
+			newActive.Port,
+			vpn,
+			auth,
+		).Get(ctx, &snap); err != nil {
+			logger.Warn("new active site connections unavailable", "vpn", vpn, "error", err)
+		} else {
+			report.NewActive = &snap
+		}
+
+		report.OldActive = nil
+		if oldActive != nil {
+			var old models.VPNConnections
+			if err := workflow.ExecuteActivity(
+				ctx,
+				activities.VPNConnectionsActivity,
+				oldActive.Host,
+				oldActive.Port,
+				vpn,
+				auth,
+			).Get(ctx, &old); err != nil {
+				logger.Warn("old active site connections unavailable", "vpn", vpn, "error", err)
+			} else {
+				report.OldActive = &old
+			}
+		}
+
+		evaluateReconnection(report)
+		if report.TrafficResumed && report.OldSiteDrained {
+			break
+		}
+		if !workflow.Now(ctx).Before(deadline) {
+			break
+		}
+	}
+
+	if report.TrafficResumed && report.OldSiteDrained {
+		logger.Info(
+			"clients followed the failover",
+			"vpn", vpn,
+			"clientsMoved", report.ClientsMoved,
+			"polls", report.Polls,
+		)
+	} else {
+		logger.Error(
+			"ALERT: clients did not follow the failover",
+			"vpn", vpn,
+			"window", window,
+			"trafficResumed", report.TrafficResumed,
+			"oldSiteDrained", report.OldSiteDrained,
+			"report", report,
+		)
+	}
+	return report
+}
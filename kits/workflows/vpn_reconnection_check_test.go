@@ -0,0 +1,85 @@
+package workflows
+
+import (
+	"kits-worker/kits/config"
+	"kits-worker/kits/models"
+	"testing"
+	"time"
+)
+
+func TestEvaluateReconnection(t *testing.T) {
+	cases := []struct {
+		name             string
+		before           *models.VPNConnections
+		newBefore        *models.VPNConnections
+		newActive        *models.VPNConnections
+		oldActive        *models.VPNConnections
+		moved            int
+		resumed, drained bool
+	}{
+		{
+			name:      "clients moved",
+			before:    &models.VPNConnections{Clients: 10, QueueBinds: 4},
+			newBefore: &models.VPNConnections{Clients: 1},
+			newActive: &models.VPNConnections{Clients: 11, QueueBinds: 4},
+			oldActive: &models.VPNConnections{},
+			moved:     10,
+			resumed:   true, drained: true,
+		},
+		{
+			name:      "only the clients already on the new site",
+			before:    &models.VPNConnections{Clients: 10},
+			newBefore: &models.VPNConnections{Clients: 2},
+			newActive: &models.VPNConnections{Clients: 2},
+			oldActive: &models.VPNConnections{},
+			resumed:   false, drained: true,
+		},
+		{
+			name:      "publishers back but consumers not bound",
+			before:    &models.VPNConnections{Clients: 10, QueueBinds: 4},
+			newActive: &models.VPNConnections{Clients: 6},
+			oldActive: &models.VPNConnections{},
+			moved:     6,
+			resumed:   false, drained: true,
+		},
+		{
+			name:      "clients still on old site",
+			before:    &models.VPNConnections{Clients: 10},
+			newActive: &models.VPNConnections{Clients: 3},
+			oldActive: &models.VPNConnections{Clients: 7},
+			moved:     3,
+			resumed:   true, drained: false,
+		},
+		{
+			name:      "old site unreachable",
+			newActive: &models.VPNConnections{Clients: 3, QueueBinds: 1},
+			moved:     3,
+			resumed:   true, drained: true,
+		},
+		{
+			name:    "new site unreachable",
+			resumed: false, drained: true,
+		},
+	}
+	for _, c := range cases {
+		r := &models.ReconnectionReport{Before: c.before, NewActiveBefore: c.newBefore, NewActive: c.newActive, OldActive: c.oldActive}
+		evaluateReconnection(r)
+		if r.TrafficResumed != c.resumed || r.OldSiteDrained != c.drained {
+			t.Errorf("%s: expected resumed=%v drained=%v, got %+v", c.name, c.resumed, c.drained, r)
+		}
+		if r.ClientsMoved != c.moved {
+			t.Errorf("%s: expected %d clients moved, got %d", c.name, c.moved, r.ClientsMoved)
+		}
+	}
+}
+
+func TestReconnectionWindow(t *testing.T) {
+	window, interval := reconnectionWindow(models.ReconnectionCheck{})
+	if window != config.RECONNECTION_CHECK_DEFAULT_WINDOW || interval != config.RECONNECTION_CHECK_DEFAULT_INTERVAL {
+		t.Errorf("expected defaults, got %s/%s", window, interval)
+	}
+	window, interval = reconnectionWindow(models.ReconnectionCheck{WindowSeconds: 60, IntervalSeconds: 5})
+	if window != time.Minute || interval != 5*time.Second {
+		t.Errorf("expected 1m/5s, got %s/%s", window, interval)
+	}
+}
@@ -0,0 +1,55 @@
+package workflows
+
+import (
+	"context"
+	"kits-worker/kits/activities"
+	"kits-worker/kits/models"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// newDRTestEnv returns a time-skipping workflow environment with the
+// workflows and activities of the worker registered. Activities must be
+// mocked by the test.
+func newDRTestEnv(t *testing.T) *testsuite.TestWorkflowEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	Register(env)
+	return env
+}
+
+// mockFailoverActivities makes every step of VPNFailoverWorkflow succeed.
+// Mocks registered before take precedence, which is how tests inject
+// failures.
+func mockFailoverActivities(env *testsuite.TestWorkflowEnvironment) {
+	env.OnActivity(activities.CheckSEMPCompatibilityActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]models.AboutResult{}, nil)
+	env.OnActivity(activities.FailoverPreflightActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, vpn string, _, _ *models.MateResult, _ models.BasicAuth) (models.FailoverPreflight, error) {
+			return models.FailoverPreflight{VPN: vpn, Go: true}, nil
+		})
+	env.OnActivity(activities.CaptureVPNConfigSnapshotActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, vpn string, m *models.MateResult, phase string, _ models.BasicAuth) (models.ConfigSnapshotRef, error) {
+			return models.ConfigSnapshotRef{VPN: vpn, Host: m.Host, Port: m.Port, DC: m.DC, Phase: phase}, nil
+		})
+	env.OnActivity(activities.DiffVPNConfigSnapshotsActivity, mock.Anything, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, before, after models.ConfigSnapshotRef) (models.ConfigDiff, error) {
+			return models.ConfigDiff{VPN: before.VPN, Host: before.Host, DC: before.DC}, nil
+		})
+	env.OnActivity(activities.SetVPNReplicationRoleActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	env.OnActivity(activities.WaitForReplicationDrainActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	env.OnActivity(activities.VerifyVPNRoleActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	env.OnActivity(activities.ValidateFinalRolesActivity, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+}
+
+var (
+	testDC1Active = &models.MateResult{Kit: "kit1", DC: "dc1", Mate: "mate1", Host: "dc1-mate1", Port: 8080, Status: models.Active}
+	testDC2Active = &models.MateResult{Kit: "kit1", DC: "dc2", Mate: "mate1", Host: "dc2-mate1", Port: 8080, Status: models.Active}
+)
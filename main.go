@@ -2,8 +2,13 @@ package main
 
 import (
 	"kits-worker/kits/activities"
+	"kits-worker/kits/config"
 	"kits-worker/kits/workflows"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	_ "time/tzdata" // maintenance windows load their time zone in workflow code, the same on every worker
 
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
@@ -17,32 +22,115 @@ func main() {
 	}
 	defer c.Close()
 
+	// Trusted keys for topology signatures (optional)
+	if path := os.Getenv(config.TOPOLOGY_PUBLIC_KEYS_ENV); path != "" {
+		keys, err := activities.LoadTopologySigningKeys(path)
+		if err != nil {
+			log.Fatal("unable to load topology public keys:", err)
+		}
+		activities.SetTopologySigningKeys(keys)
+		log.Printf("loaded %d topology public key(s), signatures are required", len(keys))
+	}
+
+	// Local cache of downloaded topology artifacts
+	cacheDir := os.Getenv(config.TOPOLOGY_CACHE_DIR_ENV)
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), config.TOPOLOGY_CACHE_DEFAULT_DIR)
+	}
+	if cache, err := activities.NewTopologyCache(cacheDir); err != nil {
+		log.Println("topology cache disabled:", err)
+	} else {
+		activities.SetTopologyCache(cache)
+	}
+
+	// Where reports are exported
+	if dir := os.Getenv(config.REPORT_DIR_ENV); dir != "" {
+		activities.SetReportDir(dir)
+	}
+
+	// VPN config snapshots are diffed by any worker, they live on shared storage
+	snapshotDir := os.Getenv(config.SNAPSHOT_DIR_ENV)
+	if snapshotDir == "" {
+		log.Fatalf("%s is required: set it to the snapshot directory shared by the workers", config.SNAPSHOT_DIR_ENV)
+	}
+	activities.SetSnapshotDir(snapshotDir)
+
+	// Append-only audit trail of broker mutations, shared by all workers
+	auditLog := os.Getenv(config.AUDIT_LOG_ENV)
+	if auditLog == "" {
+		log.Fatalf("%s is required: set it to the audit log shared by the workers", config.AUDIT_LOG_ENV)
+	}
+	activities.SetAuditSinks(activities.NewJSONLAuditSink(auditLog))
+
+	// DNS servers and record types the VPN watchers resolve with
+	resolver, err := newDNSResolver()
+	if err != nil {
+		log.Fatal("invalid DNS resolver settings:", err)
+	}
+	activities.SetDNSResolver(resolver)
+	if spec := os.Getenv(config.DNS_RESOLVERS_ENV); spec != "" {
+		types, err := activities.ParseDNSRecordTypes(os.Getenv(config.DNS_RECORD_TYPES_ENV))
+		if err != nil {
+			log.Fatal("invalid DNS resolver settings:", err)
+		}
+		resolvers, err := activities.ParseDNSQuorumResolvers(spec, types)
+		if err != nil {
+			log.Fatal("invalid DNS resolvers:", err)
+		}
+		activities.SetDNSQuorumResolvers(resolvers)
+		log.Printf("DNS watchers ask a quorum of %d resolvers", len(resolvers))
+	}
+
+	// Failovers of a VPN are serialized by a lock workflow on the task queue
+	activities.SetVPNLocker(&activities.TemporalVPNLocker{Client: c, TaskQueue: "KITS_TASK_QUEUE"})
+
 	// 2 Create worker listening on "KITS_TASK_QUEUE"
 	w := worker.New(c, "KITS_TASK_QUEUE", worker.Options{})
 
-	// 3 Register workflows
-	w.RegisterWorkflow(workflows.KitsDRWorkflow)
-	w.RegisterWorkflow(workflows.KitDRWorkflow)
-	w.RegisterWorkflow(workflows.VPNFailoverWorkflow)
-	w.RegisterWorkflow(workflows.VPNDNSWatchAndExecuteVPNFailoverWorkflow)
-	w.RegisterWorkflow(workflows.KitsSolaceAPIWorkflow)
-	w.RegisterWorkflow(workflows.KitSolaceAPIWorkflow)
-
-	// 4 Register activities
-	w.RegisterActivity(activities.DownloadAndParseKitsActivity)
-	w.RegisterActivity(activities.CheckMateStatusActivity)
-	w.RegisterActivity(activities.GetRoleVPNsActivity)
-	w.RegisterActivity(activities.ResolveDNSActivity)
-	w.RegisterActivity(activities.SetVPNReplicationRoleActivity)
-	w.RegisterActivity(activities.WaitForReplicationDrainActivity)
-	w.RegisterActivity(activities.VerifyVPNRoleActivity)
-	w.RegisterActivity(activities.ValidateFinalRolesActivity)
-	w.RegisterActivity(activities.BrokerSEMPApiAboutActivity)
-
-	log.Println("KITS worker started, listening on KITS_TASK_QUEUE...")
+	// 3 Register workflows and activities
+	workflows.Register(w)
+
+	// Audit queries are served from the shared audit log
+	auditWorker := worker.New(c, config.AUDIT_TASK_QUEUE, worker.Options{})
+	auditWorker.RegisterActivity(activities.QueryAuditTrailActivity)
+	if err := auditWorker.Start(); err != nil {
+		log.Fatal("unable to start the audit worker:", err)
+	}
+	defer auditWorker.Stop()
+
+	log.Println("KITS worker started, listening on KITS_TASK_QUEUE and", config.AUDIT_TASK_QUEUE+"...")
 
 	// 5 Run worker
 	if err := w.Run(worker.InterruptCh()); err != nil {
 		log.Fatal("worker stopped with error:", err)
 	}
 }
+
+// newDNSResolver builds the resolver of the DNS watchers from the
+// environment: the hosts file first, then the configured DNS servers or the
+// system resolver.
+func newDNSResolver() (activities.DNSResolver, error) {
+	types, err := activities.ParseDNSRecordTypes(os.Getenv(config.DNS_RECORD_TYPES_ENV))
+	if err != nil {
+		return nil, err
+	}
+
+	var chain activities.ChainResolver
+	hostsFile, ok := os.LookupEnv(config.DNS_HOSTS_FILE_ENV)
+	if !ok {
+		hostsFile = config.DNS_HOSTS_FILE_DEFAULT
+	}
+	if hostsFile != "" {
+		chain = append(chain, &activities.HostsResolver{Path: hostsFile, Types: types})
+	}
+
+	if servers := os.Getenv(config.DNS_SERVERS_ENV); servers != "" {
+		r, err := activities.NewServerResolver(strings.Split(servers, ","), types)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("resolving VPN FQDNs on %s", strings.Join(r.Servers, ", "))
+		return append(chain, r), nil
+	}
+	return append(chain, &activities.SystemResolver{Types: types}), nil
+}